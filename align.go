@@ -0,0 +1,144 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Align
+ *
+ * The "align" action pads the selected lines so that a delimiter, such as =,
+ * : or |, or a regular expression written as /re/, starts in the same column
+ * on all of them, or when no line has space before the delimiter, so that the
+ * text after it does, as for "key: value". When a line has the delimiter more
+ * than once it asks whether to align every occurrence, as for the columns of
+ * a table. Lines without the delimiter are left alone.
+ */
+
+/* delimiterRx returns the regular expression for the delimiter given to align. */
+func delimiterRx(s string) (*regexp.Regexp, error) {
+	if len(s) > 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		return regexp.Compile(s[1 : len(s)-1])
+	}
+	if s == "" {
+		return nil, fmt.Errorf("a delimiter such as = or /re/")
+	}
+	return regexp.MustCompile(regexp.QuoteMeta(s)), nil
+}
+
+/* alignLines pads the fields before the matches of re in lines, the first match or all of them, to the same width. */
+func (e *Editor) alignLines(lines []string, re *regexp.Regexp, all bool) []string {
+	n := 1
+	if all {
+		n = -1
+	}
+	type split struct {
+		fields []string // the text before each delimiter, then the rest
+		delims []string
+	}
+	splits := make([]split, len(lines))
+	widths := []int{}
+	spaced := []bool{} // some line has space before the delimiter
+	for i, l := range lines {
+		start := 0
+		for j, m := range re.FindAllStringIndex(l, n) {
+			if m[1] == m[0] {
+				continue // empty matches would not line anything up
+			}
+			d := l[m[0]:m[1]]
+			lead := len(d) - len(strings.TrimLeft(d, " \t")) // space matched by a regular expression
+			field := l[start : m[0]+lead]
+			trimmed := strings.TrimRight(field, " \t")
+			if j == len(widths) {
+				widths = append(widths, 0)
+				spaced = append(spaced, false)
+			}
+			if w := len(e.updateRow([]rune(trimmed))); w > widths[j] {
+				widths[j] = w
+			}
+			spaced[j] = spaced[j] || trimmed != field
+			splits[i].fields = append(splits[i].fields, trimmed)
+			splits[i].delims = append(splits[i].delims, d[lead:])
+			start = m[1]
+		}
+		splits[i].fields = append(splits[i].fields, l[start:])
+	}
+
+	out := make([]string, len(lines))
+	for i, s := range splits {
+		var sb strings.Builder
+		for j, d := range s.delims {
+			f := s.fields[j]
+			pad := strings.Repeat(" ", widths[j]-len(e.updateRow([]rune(f))))
+			sb.WriteString(f)
+			if !all && !spaced[j] {
+				sb.WriteString(d + pad) // line up the text after the delimiter
+				continue
+			}
+			sb.WriteString(pad)
+			if spaced[j] && widths[j] > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(d)
+		}
+		sb.WriteString(s.fields[len(s.fields)-1])
+		out[i] = sb.String()
+	}
+	return out
+}
+
+func (e *Editor) align() {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	first, last, ok := e.buf.selectedLines()
+	if !ok {
+		e.setStatusMsg("Select the lines first, set_mark starts a selection")
+		e.bell()
+		return
+	}
+	if !e.canEdit(first, last) {
+		return
+	}
+
+	input, ok := e.Prompt("Align on (=, :, | or /re/): ", func(s string) error {
+		_, err := delimiterRx(s)
+		return err
+	}, nil)
+	if !ok {
+		return
+	}
+	re, _ := delimiterRx(input)
+
+	lines := make([]string, 0, last-first+1)
+	repeated := false
+	for y := first; y <= last; y++ {
+		l := string(e.buf.lines[y].chars)
+		lines = append(lines, l)
+		repeated = repeated || len(re.FindAllStringIndex(l, 2)) > 1
+	}
+	all := repeated && e.Confirm("Align every occurrence?")
+
+	changed := 0
+	for i, l := range e.alignLines(lines, re, all) {
+		if l == lines[i] {
+			continue
+		}
+		y := first + i
+		e.buf.lines[y].chars = []rune(l)
+		e.buf.lines[y].render = e.updateRow(e.buf.lines[y].chars)
+		changed++
+	}
+	if changed == 0 {
+		e.setStatusMsg("Already aligned")
+		return
+	}
+	e.buf.dirty = true
+	e.changes++
+	e.snapCursor()
+	e.setStatusMsg("Aligned %d lines", changed)
+}
@@ -0,0 +1,72 @@
+package editor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Audit log
+ *
+ * With an audit log set, every file opened or saved is recorded as a line of
+ * JSON appended to it: when, by whom on which host, the file and the SHA-256
+ * of its text. The log is only ever appended to, so it can answer who changed
+ * what and when without version control.
+ */
+
+type auditRecord struct {
+	Time   string `json:"time"`
+	User   string `json:"user"`
+	Host   string `json:"host"`
+	Event  string `json:"event"` // "open" or "save"
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+	Bytes  int    `json:"bytes"`
+}
+
+// WithAuditLog appends a record of every file opened or saved to the file path.
+func WithAuditLog(path string) Option {
+	return func(e *Editor) { e.auditLog = path }
+}
+
+/* audit appends a record of event on the current buffer to the audit log, if there is one. */
+func (e *Editor) audit(event string) {
+	if e.auditLog == "" || e.secure {
+		return
+	}
+
+	text := e.buf.text()
+	sum := sha256.Sum256([]byte(text))
+	rec := auditRecord{
+		Time:   time.Now().Format(time.RFC3339),
+		Event:  event,
+		File:   e.buf.fileName,
+		SHA256: hex.EncodeToString(sum[:]),
+		Bytes:  len(text),
+	}
+	if u, err := user.Current(); err == nil {
+		rec.User = u.Username
+	}
+	rec.Host, _ = os.Hostname()
+	if _, remote := parseRemotePath(rec.File); !remote && !isURL(rec.File) && rec.File != "" {
+		if abs, err := filepath.Abs(rec.File); err == nil {
+			rec.File = abs
+		}
+	}
+
+	data, _ := json.Marshal(rec)
+	f, err := os.OpenFile(e.auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		e.setStatusMsg("audit log: %s", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil { // one write, so records are not interleaved
+		e.setStatusMsg("audit log: %s", err)
+	}
+}
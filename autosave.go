@@ -0,0 +1,47 @@
+package editor
+
+/*-----------------------------------------------------------------------------
+ * Autosave
+ *
+ * With the autosave setting, the modified buffers are saved when the terminal
+ * loses focus, when the editor is suspended and when no key has been pressed
+ * for autosaveDelay, the moments the user may close the laptop lid and
+ * forget about them. Buffers without a file to save to, such as those of data
+ * the editor was run on without a save writer, are left alone, as are
+ * read-only ones, and secure and read-only modes never autosave.
+ */
+
+// WithAutosave saves the modified buffers when the terminal loses focus, when
+// the editor is suspended and when no key has been pressed for a while.
+func WithAutosave() Option {
+	return func(e *Editor) { e.autosave = true }
+}
+
+func (e *Editor) autosaveBuffers() {
+	if !e.autosave || e.secure || e.readonly {
+		return
+	}
+	unnamed := e.saveModified()
+	if unnamed > 0 {
+		e.setStatusMsg("autosave: %d buffers without a file were not saved", unnamed)
+	}
+}
+
+/* saveModified saves the modified buffers that have somewhere to be saved without asking, and returns the number of those that do not. */
+func (e *Editor) saveModified() int {
+	unnamed := 0
+	cur := e.buf
+	for _, b := range e.buffers {
+		if !b.dirty || b.scratch || b.readonly {
+			continue
+		}
+		if b.sink == nil && (b.fileName == "" || b.data || isURL(b.fileName)) {
+			unnamed++
+			continue
+		}
+		e.buf = b
+		e.save()
+	}
+	e.buf = cur
+	return unnamed
+}
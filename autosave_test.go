@@ -0,0 +1,34 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAutosaveSkipsData(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	e := newEditor(NewHeadless(8, 40))
+	e.addBuffer()
+	e.autosave = true
+	if err := e.openData([]byte("text\n")); err != nil {
+		t.Fatal(err)
+	}
+	e.insertRow(0, "more")
+	e.autosaveBuffers()
+	if _, err := os.Stat(filepath.Join(dir, "memory")); err == nil {
+		t.Error("autosave wrote the data to a file named memory")
+	}
+	if !e.buf.dirty {
+		t.Error("the buffer was marked saved")
+	}
+}
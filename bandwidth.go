@@ -0,0 +1,93 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Low bandwidth
+ *
+ * Normally every refresh repaints the whole screen, which is slow over a poor
+ * connection. In low bandwidth mode the rows of the last frame are kept and
+ * only the rows that changed are written, each after moving the cursor to it.
+ * Overlays are written again only when they, or a row under them, changed.
+ * The "low-bandwidth" profile turns the mode on together with a longer escape
+ * timeout, since escape sequences arrive slowly over such connections.
+ */
+
+const lowBandwidthEscTimeout = 200 * time.Millisecond
+
+// WithLowBandwidth only writes the rows of the screen that changed since the
+// last refresh, for editing over slow connections.
+func WithLowBandwidth() Option {
+	return func(e *Editor) { e.lowBandwidth = true }
+}
+
+/* setProfile applies the settings of the named profile. */
+func (e *Editor) setProfile(name string) error {
+	switch name {
+	case "low-bandwidth":
+		e.lowBandwidth = true
+		if e.escTimeout < lowBandwidthEscTimeout {
+			e.escTimeout = lowBandwidthEscTimeout
+		}
+	case "default":
+		e.lowBandwidth = false
+		e.escTimeout = defaultEscTimeout
+	default:
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	e.invalidateScreen()
+	return nil
+}
+
+/* invalidateScreen makes the next refresh write the whole screen, after something else has written to it. */
+func (e *Editor) invalidateScreen() {
+	e.lastRows = nil
+	e.lastTop = ""
+}
+
+var cursorPosition = regexp.MustCompile(`\x1b\[(\d+);\d+H`)
+
+/* overlayRows returns the screen rows, zero based, that the overlays drawn by top are on. */
+func overlayRows(top string) map[int]bool {
+	rows := map[int]bool{}
+	for _, m := range cursorPosition.FindAllStringSubmatch(top, -1) {
+		if y, err := strconv.Atoi(m[1]); err == nil {
+			rows[y-1] = true
+		}
+	}
+	return rows
+}
+
+/*
+drawChanged writes the rows, separated by \r\n which the text of a line can
+not contain, that differ from the last frame, followed by top, what is drawn
+over them, if it or a row under it changed.
+*/
+func (e *Editor) drawChanged(scrBuf *bytes.Buffer, rows, top []byte) {
+	cur := strings.SplitAfter(string(rows), "\r\n")
+	topChanged := string(top) != e.lastTop
+	under := overlayRows(e.lastTop)
+	over := overlayRows(string(top))
+
+	redrawTop := topChanged
+	for y, row := range cur {
+		if y < len(e.lastRows) && row == e.lastRows[y] && !(topChanged && under[y]) {
+			continue
+		}
+		fmt.Fprintf(scrBuf, "\x1b[%d;1H%s", y+1, strings.TrimSuffix(row, "\r\n"))
+		if over[y] {
+			redrawTop = true
+		}
+	}
+	if redrawTop {
+		scrBuf.Write(top)
+	}
+	e.lastRows, e.lastTop = cur, string(top)
+}
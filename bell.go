@@ -0,0 +1,50 @@
+package editor
+
+import (
+	"fmt"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Bell
+ */
+
+// BellMode selects how the editor signals an action that can not be done,
+// such as deleting at the start of the file or a search without matches.
+// The zero value, BellOff, keeps the editor silent unless a bell is asked for.
+type BellMode int
+
+const (
+	BellOff     BellMode = iota // stay silent
+	BellVisual                  // flash the screen
+	BellAudible                 // ring the terminal bell
+)
+
+var bellModes = map[string]BellMode{
+	"audible": BellAudible,
+	"visual":  BellVisual,
+	"off":     BellOff,
+}
+
+func parseBellMode(name string) (BellMode, error) {
+	if m, ok := bellModes[name]; ok {
+		return m, nil
+	}
+	return BellOff, fmt.Errorf("unknown bell mode %q", name)
+}
+
+// WithBell sets how the editor signals actions that can not be done.
+func WithBell(mode BellMode) Option {
+	return func(e *Editor) { e.bellMode = mode }
+}
+
+func (e *Editor) bell() {
+	switch e.bellMode {
+	case BellAudible:
+		e.term.Write([]byte("\a"))
+	case BellVisual:
+		e.term.Write([]byte("\x1b[?5h")) // reverse video
+		time.Sleep(100 * time.Millisecond)
+		e.term.Write([]byte("\x1b[?5l"))
+	}
+}
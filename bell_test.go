@@ -0,0 +1,12 @@
+package editor
+
+import "testing"
+
+func TestBellOffByDefault(t *testing.T) {
+	if e := New(); e.bellMode != BellOff {
+		t.Errorf("the bell mode is %d by default, want BellOff", e.bellMode)
+	}
+	if e := New(WithBell(BellAudible)); e.bellMode != BellAudible {
+		t.Errorf("the bell mode is %d with WithBell(BellAudible)", e.bellMode)
+	}
+}
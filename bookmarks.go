@@ -0,0 +1,215 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+/*-----------------------------------------------------------------------------
+ * Bookmarks
+ *
+ * The "toggle_bookmark" action bookmarks the line of the cursor, or removes
+ * its bookmark, and "next_bookmark" and "prev_bookmark" go to the bookmarked
+ * lines after and before it, wrapping around. A buffer with bookmarks gets a
+ * gutter left of the text marking them. Bookmarks move with the lines as
+ * lines are inserted and deleted above them, and go away with their line.
+ *
+ * The bookmarks of a file are kept in bookmarks.json in the data directory
+ * when it is saved, or when they change while it has no unsaved changes, so
+ * that they line up with the file on disk, and come back when it is opened.
+ */
+
+const (
+	bookmarkGutter = 2 // columns of the gutter
+	bookmarkSign   = '●'
+	bookmarkStyle  = "\x1b[36m"
+)
+
+func bookmarksFile() string {
+	dir := dataDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "bookmarks.json")
+}
+
+/* keepsBookmarks reports whether the bookmarks of b are kept in the data directory. */
+func (e *Editor) keepsBookmarks(b *buffer) bool {
+	return !e.isolated && !e.secure && b.fileName != "" && !b.data && b.sink == nil && !isURL(b.fileName) && bookmarksFile() != ""
+}
+
+/* readBookmarks returns the bookmarks of all files by absolute path, one based. */
+func readBookmarks() map[string][]int {
+	marks := map[string][]int{}
+	if data, err := os.ReadFile(bookmarksFile()); err == nil {
+		json.Unmarshal(data, &marks)
+	}
+	return marks
+}
+
+/* loadBookmarks restores the bookmarks kept for the file of the current buffer. */
+func (e *Editor) loadBookmarks() {
+	b := e.buf
+	b.bookmarks = nil
+	if !e.keepsBookmarks(b) {
+		return
+	}
+	abs, err := filepath.Abs(b.fileName)
+	if err != nil {
+		return
+	}
+	n := len(b.allLines())
+	for _, y := range readBookmarks()[abs] {
+		if y >= 1 && y <= n {
+			b.bookmarks = append(b.bookmarks, y-1)
+		}
+	}
+	sort.Ints(b.bookmarks)
+}
+
+/* saveBookmarks keeps the bookmarks of the current buffer for its file. */
+func (e *Editor) saveBookmarks() {
+	b := e.buf
+	if !e.keepsBookmarks(b) {
+		return
+	}
+	abs, err := filepath.Abs(b.fileName)
+	if err != nil {
+		return
+	}
+	marks := readBookmarks()
+	if len(marks[abs]) == 0 && len(b.bookmarks) == 0 {
+		return
+	}
+	delete(marks, abs)
+	for _, y := range b.bookmarks {
+		marks[abs] = append(marks[abs], y+1)
+	}
+	path := bookmarksFile()
+	data, err := json.MarshalIndent(marks, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(path, append(data, '\n'), 0600)
+	}
+	if err != nil {
+		e.setStatusMsg("saving bookmarks: %s", err)
+	}
+}
+
+/* shiftBookmarks moves the bookmarks after row by n lines when lines are inserted or deleted at row. */
+func (b *buffer) shiftBookmarks(row, n int) {
+	row += b.hiddenAbove()
+	kept := b.bookmarks[:0]
+	for _, y := range b.bookmarks {
+		switch {
+		case n < 0 && y == row:
+			continue // the line is deleted
+		case y >= row:
+			y += n
+		}
+		kept = append(kept, y)
+	}
+	b.bookmarks = kept
+}
+
+/* dropLostBookmarks drops the bookmarks past the last line, after the lines of b were replaced wholesale. */
+func (b *buffer) dropLostBookmarks() {
+	n := len(b.allLines())
+	i := sort.SearchInts(b.bookmarks, n)
+	b.bookmarks = b.bookmarks[:i]
+}
+
+/* gutterWidth returns the columns left of the text taken by the gutter. */
+func (e *Editor) gutterWidth() int {
+	if len(e.buf.bookmarks) > 0 && e.termCols > bookmarkGutter {
+		return bookmarkGutter
+	}
+	return 0
+}
+
+/* textCols returns the columns of the screen the text is drawn in. */
+func (e *Editor) textCols() int {
+	return e.termCols - e.gutterWidth()
+}
+
+/* drawGutter draws the gutter of the screen row showing line y. */
+func (e *Editor) drawGutter(scrBuf *bytes.Buffer, y int) {
+	if e.gutterWidth() == 0 {
+		return
+	}
+	if y < len(e.buf.lines) && e.buf.hasBookmark(y) {
+		scrBuf.WriteString(bookmarkStyle + string(bookmarkSign) + " " + sgr(e.theme.Text))
+		return
+	}
+	scrBuf.WriteString("  ")
+}
+
+/* hasBookmark reports whether line y of the shown lines is bookmarked. */
+func (b *buffer) hasBookmark(y int) bool {
+	y += b.hiddenAbove()
+	i := sort.SearchInts(b.bookmarks, y)
+	return i < len(b.bookmarks) && b.bookmarks[i] == y
+}
+
+func (e *Editor) toggleBookmark() {
+	b := e.buf
+	if b.cursor.y >= len(b.lines) {
+		e.bell()
+		return
+	}
+	y := b.cursor.y + b.hiddenAbove()
+	i := sort.SearchInts(b.bookmarks, y)
+	if i < len(b.bookmarks) && b.bookmarks[i] == y {
+		b.bookmarks = append(b.bookmarks[:i], b.bookmarks[i+1:]...)
+		e.setStatusMsg("Bookmark removed, %d left", len(b.bookmarks))
+	} else {
+		b.bookmarks = append(b.bookmarks, 0)
+		copy(b.bookmarks[i+1:], b.bookmarks[i:])
+		b.bookmarks[i] = y
+		e.setStatusMsg("Bookmark %d of %d set", i+1, len(b.bookmarks))
+	}
+	if !b.dirty {
+		e.saveBookmarks()
+	}
+}
+
+/* gotoBookmark moves to the next bookmarked line after the cursor, or before it if back is set. */
+func (e *Editor) gotoBookmark(back bool) {
+	b := e.buf
+	shown := []int{} // bookmarks of the shown lines
+	for _, y := range b.bookmarks {
+		if y -= b.hiddenAbove(); y >= 0 && y < len(b.lines) {
+			shown = append(shown, y)
+		}
+	}
+	if len(shown) == 0 {
+		e.setStatusMsg("No bookmarks, toggle_bookmark sets one")
+		e.bell()
+		return
+	}
+	i := sort.SearchInts(shown, b.cursor.y) // first at or after the cursor
+	if back {
+		i--
+		if i < 0 {
+			i = len(shown) - 1
+		}
+	} else {
+		if i < len(shown) && shown[i] == b.cursor.y {
+			i++
+		}
+		if i == len(shown) {
+			i = 0
+		}
+	}
+	e.setCursor(point{y: shown[i]})
+	e.snapCursor()
+	e.setStatusMsg("Bookmark %d of %d", i+1, len(shown))
+}
+
+func (e *Editor) nextBookmark() { e.gotoBookmark(false) }
+func (e *Editor) prevBookmark() { e.gotoBookmark(true) }
@@ -0,0 +1,32 @@
+package editor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUndoReplaceRestoresBookmarks(t *testing.T) {
+	e, _ := runTask(t, "a\nb\nb\nc\n", func(e *Editor) {
+		e.buf.bookmarks = []int{0, 3}
+		e.uniqLinesAction()
+		if e.buf.text() != "xa\nb\nc\n" {
+			t.Fatalf("uniq_lines left %q", e.buf.text())
+		}
+		e.undoReplace()
+	})
+	if want := []int{0, 3}; !reflect.DeepEqual(e.buf.bookmarks, want) {
+		t.Errorf("bookmarks after undo_replace: got %v, want %v", e.buf.bookmarks, want)
+	}
+}
+
+func TestCollabRebuildDropsLostBookmarks(t *testing.T) {
+	e, _ := runTask(t, "a\nb\nc\n", func(e *Editor) {
+		e.buf.bookmarks = []int{0, 2}
+		s := newCollabSession(e, e.buf, 1)
+		s.apply(collabOp{ID: charID{Clock: 1, Site: 1}, After: rootID, Ch: 'x'})
+		s.rebuild(rootID)
+	})
+	if want := []int{0}; !reflect.DeepEqual(e.buf.bookmarks, want) {
+		t.Errorf("bookmarks after rebuild: got %v, want %v", e.buf.bookmarks, want)
+	}
+}
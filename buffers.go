@@ -0,0 +1,403 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Buffers
+ */
+
+/* addBuffer appends an empty buffer and makes it the current buffer. */
+func (e *Editor) addBuffer() *buffer {
+	b := &buffer{}
+	e.buffers = append(e.buffers, b)
+	e.buf = b
+	return b
+}
+
+func (e *Editor) removeBuffer(b *buffer) {
+	for i, o := range e.buffers {
+		if o == b {
+			e.buffers = append(e.buffers[:i], e.buffers[i+1:]...)
+			break
+		}
+	}
+	if len(e.buffers) == 0 {
+		e.addBuffer()
+	}
+	if e.buf == b {
+		e.buf = e.buffers[0]
+	}
+}
+
+func (e *Editor) bufferIndex(b *buffer) int {
+	for i, o := range e.buffers {
+		if o == b {
+			return i
+		}
+	}
+	return -1
+}
+
+/* findBuffer returns the buffer editing the file name, or nil. */
+func (e *Editor) findBuffer(name string) *buffer {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil
+	}
+	for _, b := range e.buffers {
+		if b.fileName == "" {
+			continue
+		}
+		if babs, err := filepath.Abs(b.fileName); err == nil && babs == abs {
+			return b
+		}
+	}
+	return nil
+}
+
+func (e *Editor) nextBuffer() {
+	i := (e.bufferIndex(e.buf) + 1) % len(e.buffers)
+	e.buf = e.buffers[i]
+}
+
+func (e *Editor) prevBuffer() {
+	i := e.bufferIndex(e.buf) - 1
+	if i < 0 {
+		i = len(e.buffers) - 1
+	}
+	e.buf = e.buffers[i]
+}
+
+func (e *Editor) anyBufferDirty() bool {
+	for _, b := range e.buffers {
+		if b.dirty {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+openBuffer makes a buffer editing the file name current. An already open
+buffer is reused, otherwise the file is read into a new buffer. A file that
+does not exist gives an empty buffer and is created on save.
+*/
+func (e *Editor) openBuffer(name string) error {
+	if b := e.findBuffer(name); b != nil {
+		e.buf = b
+		return nil
+	}
+
+	prev := e.buf
+	if prev.fileName != "" || prev.dirty || len(prev.lines) != 0 {
+		e.addBuffer()
+	}
+
+	if err := e.openFile(name); errors.Is(err, fs.ErrNotExist) {
+		e.newFile(name)
+	} else if err != nil {
+		if e.buf != prev {
+			e.removeBuffer(e.buf)
+			e.buf = prev
+		}
+		return err
+	}
+	return nil
+}
+
+/* newFile makes the current buffer hold the file name that does not exist yet, filled from its template if there is one. */
+func (e *Editor) newFile(name string) {
+	e.buf.lines = []line{}
+	e.buf.narrow = nil
+	e.buf.mark = nil
+	e.buf.fileName = name
+	e.buf.compression = compressionFor(name)
+	e.applyTemplate()
+	e.buf.dirty = false
+	e.runHook(BufOpen)
+}
+
+/* saveAll saves every dirty buffer and reports whether all of them were saved. */
+func (e *Editor) saveAll() bool {
+	cur := e.buf
+	defer func() { e.buf = cur }()
+
+	for _, b := range e.buffers {
+		if b.dirty && !b.scratch {
+			e.buf = b
+			e.save()
+			if b.dirty {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/*-----------------------------------------------------------------------------
+ * Buffer API
+ *
+ * The methods of Buffer and Editor.Buffer must be called on the editor's main
+ * loop: from a hook, an action or a function passed to Editor.Do.
+ */
+
+// Point is a position in a buffer. Both fields are zero based and Col counts
+// runes.
+type Point struct {
+	Line int
+	Col  int
+}
+
+// Buffer is a handle to a buffer of an editor. Edits made through it mark
+// the buffer as modified just like edits made from the keyboard.
+type Buffer struct {
+	e *Editor
+	b *buffer
+}
+
+var (
+	// ErrReadOnly is returned when editing a read-only editor or buffer.
+	ErrReadOnly = errors.New("buffer is read-only")
+	// ErrOutOfRange is returned for lines and points outside the buffer.
+	ErrOutOfRange = errors.New("position out of range")
+)
+
+// Buffer returns the current buffer.
+func (e *Editor) Buffer() *Buffer {
+	return &Buffer{e: e, b: e.buf}
+}
+
+// Buffers returns the open buffers.
+func (e *Editor) Buffers() []*Buffer {
+	bs := make([]*Buffer, len(e.buffers))
+	for i, b := range e.buffers {
+		bs[i] = &Buffer{e: e, b: b}
+	}
+	return bs
+}
+
+// Do runs fn on the editor's main loop. It is safe to call from any goroutine.
+func (e *Editor) Do(fn func()) {
+	e.queueTask(fn)
+}
+
+/* with runs fn with b as the current buffer. */
+func (b *Buffer) with(fn func()) {
+	cur := b.e.buf
+	b.e.buf = b.b
+	defer func() { b.e.buf = cur }()
+	fn()
+}
+
+func (b *Buffer) FileName() string { return b.b.fileName }
+func (b *Buffer) Modified() bool   { return b.b.dirty }
+func (b *Buffer) LineCount() int   { return len(b.b.lines) }
+func (b *Buffer) Text() string     { return b.b.text() }
+
+// Line returns line i, or "" if there is no such line.
+func (b *Buffer) Line(i int) string {
+	if i < 0 || i >= len(b.b.lines) {
+		return ""
+	}
+	return string(b.b.lines[i].chars)
+}
+
+func (b *Buffer) SetLine(i int, s string) error {
+	if i < 0 || i >= len(b.b.lines) {
+		return ErrOutOfRange
+	}
+	return b.replace(point{y: i}, point{y: i, x: len(b.b.lines[i].chars)}, s)
+}
+
+// InsertAt inserts s, which may hold several lines, at p.
+func (b *Buffer) InsertAt(p Point, s string) error {
+	return b.replace(point{y: p.Line, x: p.Col}, point{y: p.Line, x: p.Col}, s)
+}
+
+// DeleteRange deletes the text from a up to, but not including, c.
+func (b *Buffer) DeleteRange(a, c Point) error {
+	return b.replace(point{y: a.Line, x: a.Col}, point{y: c.Line, x: c.Col}, "")
+}
+
+func (b *Buffer) Cursor() Point {
+	return Point{Line: b.b.cursor.y, Col: b.b.cursor.x}
+}
+
+// SetCursor moves the cursor to p, or as close to it as the text allows.
+func (b *Buffer) SetCursor(p Point) {
+	b.with(func() {
+		b.e.setCursor(point{y: p.Line, x: p.Col})
+		b.e.snapCursor()
+	})
+}
+
+/* valid reports whether p is in the buffer, the point after the last line included. */
+func (b *Buffer) valid(p point) bool {
+	if p.y == len(b.b.lines) {
+		return p.x == 0
+	}
+	return p.y >= 0 && p.y < len(b.b.lines) && p.x >= 0 && p.x <= len(b.b.lines[p.y].chars)
+}
+
+func (b *Buffer) replace(a, c point, s string) error {
+	if b.e.readonly || b.b.readonly {
+		return ErrReadOnly
+	}
+	if !b.valid(a) || !b.valid(c) || c.y < a.y || (c.y == a.y && c.x < a.x) {
+		return ErrOutOfRange
+	}
+	for y := a.y; y <= c.y; y++ {
+		if b.b.lineProtected(y) {
+			return ErrProtected
+		}
+	}
+	b.with(func() {
+		b.e.replaceRange(a, c, s)
+		b.e.snapCursor()
+	})
+	return nil
+}
+
+/* replaceRange replaces the text from a up to c with s. */
+func (e *Editor) replaceRange(a, c point, s string) {
+	atEnd := c.y == len(e.buf.lines)
+	head, tail := "", ""
+	if a.y < len(e.buf.lines) {
+		head = string(e.buf.lines[a.y].chars[:a.x])
+	}
+	if c.y < len(e.buf.lines) {
+		tail = string(e.buf.lines[c.y].chars[c.x:])
+	}
+
+	for y := c.y; y >= a.y; y-- {
+		e.deleteRow(y)
+	}
+
+	lines := strings.Split(head+s+tail, "\n")
+	if atEnd && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1] // the text ends with a newline
+	}
+	for i, l := range lines {
+		e.insertRow(a.y+i, l)
+	}
+	e.buf.dirty = true
+	e.changes++
+}
+
+/* insertText inserts s at the cursor and puts the cursor after it. */
+func (e *Editor) insertText(s string) {
+	c := e.buf.cursor
+	if !e.canEdit(c.y, c.y) {
+		return
+	}
+	e.replaceRange(c, c, s)
+	lines := strings.Split(s, "\n")
+	end := point{y: c.y + len(lines) - 1, x: len([]rune(lines[len(lines)-1]))}
+	if len(lines) == 1 {
+		end.x += c.x
+	}
+	e.setCursor(end)
+	e.snapCursor()
+}
+
+/* isReadonly reports whether the current buffer can not be edited. */
+func (e *Editor) isReadonly() bool {
+	return e.readonly || e.buf.readonly
+}
+
+/* toggleReadonly makes the buffers editable when the current one is read-only, itself included, and read-only when it is not. */
+func (e *Editor) toggleReadonly() {
+	if e.isReadonly() {
+		e.readonly, e.buf.readonly = false, false
+	} else {
+		e.readonly = true
+	}
+	if e.readonly {
+		e.setStatusMsg("Read-only. Press ctrl+q to exit.")
+	} else {
+		e.setStatusMsg("Editable. Press ctrl+s to save.")
+	}
+}
+
+/* revert reads the file of the current buffer again, dropping unsaved changes after confirmation. */
+func (e *Editor) revert() {
+	if e.buf.fileName == "" || e.buf.sink != nil {
+		e.setStatusMsg("buffer has no file to revert to")
+		e.bell()
+		return
+	}
+	if e.buf.dirty && !e.Confirm(fmt.Sprintf("Discard changes to %s?", e.buf.fileName)) {
+		return
+	}
+
+	if err := e.reload(); err != nil {
+		e.setStatusMsg("revert: %s", err)
+		return
+	}
+	e.setStatusMsg("reverted %s", e.buf.fileName)
+}
+
+/* reload reads the file of the current buffer again, keeping the cursor where it was. */
+func (e *Editor) reload() error {
+	e.widen()
+	cursor, fileY, fileX := e.buf.cursor, e.buf.fileY, e.buf.fileX
+	if err := e.openFile(e.buf.fileName); err != nil {
+		return err
+	}
+	e.buf.cursor, e.buf.fileY, e.buf.fileX = cursor, fileY, fileX
+	e.snapCursor()
+	return nil
+}
+
+/* renameFile renames the file of the current buffer, with "git mv" when git tracks it. */
+func (e *Editor) renameFile() {
+	if e.buf.fileName == "" || e.buf.sink != nil {
+		e.setStatusMsg("buffer has no file to rename")
+		e.bell()
+		return
+	}
+
+	name, ok := e.Prompt("Rename to: ", nil, nil)
+	if !ok || name == "" || name == e.buf.fileName {
+		return
+	}
+	if _, err := os.Stat(name); err == nil && !e.Confirm(fmt.Sprintf("%s exists, overwrite it?", name)) {
+		return
+	}
+
+	if _, err := os.Stat(e.buf.fileName); err == nil {
+		if err := renameOnDisk(e.buf.fileName, name); err != nil {
+			e.setStatusMsg("rename: %s", err)
+			return
+		}
+	}
+	e.buf.fileName = name
+	e.setStatusMsg("renamed to %s", name)
+}
+
+func renameOnDisk(from, to string) error {
+	absFrom, err := filepath.Abs(from)
+	if err != nil {
+		return err
+	}
+	absTo, err := filepath.Abs(to)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(absFrom)
+	if exec.Command("git", "-C", dir, "ls-files", "--error-unmatch", absFrom).Run() == nil {
+		if exec.Command("git", "-C", dir, "mv", "-f", absFrom, absTo).Run() == nil {
+			return nil
+		}
+	}
+	return os.Rename(absFrom, absTo)
+}
@@ -0,0 +1,24 @@
+package editor
+
+import "testing"
+
+func TestToggleReadonly(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		editor, buffer bool
+		want           bool
+	}{
+		{"editable", false, false, true},
+		{"read-only editor", true, false, false},
+		{"read-only buffer", false, true, false},
+		{"both read-only", true, true, false},
+	} {
+		e := newEditor(NewHeadless(8, 40))
+		e.addBuffer()
+		e.readonly, e.buf.readonly = tc.editor, tc.buffer
+		e.toggleReadonly()
+		if e.isReadonly() != tc.want {
+			t.Errorf("%s: read-only is %v after toggling, want %v", tc.name, e.isReadonly(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package editor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Calculator
+ *
+ * The "calc" action prompts for an arithmetic expression and shows its value
+ * in the status bar, where pressing i inserts it at the cursor. Expressions
+ * have the operators of Go, + - * / % & | ^ << >> and unary - and ^, with **
+ * for powers, parentheses, and decimal, hex (0x1f), octal (0o17) and binary
+ * (0b101) literals. Values stay integers until a float or a division that
+ * does not come out even turns up.
+ */
+
+type calcValue struct {
+	i       int64
+	f       float64
+	isFloat bool
+}
+
+func (v calcValue) float() float64 {
+	if v.isFloat {
+		return v.f
+	}
+	return float64(v.i)
+}
+
+func (v calcValue) String() string {
+	if !v.isFloat {
+		return strconv.FormatInt(v.i, 10)
+	}
+	return strconv.FormatFloat(v.f, 'g', -1, 64)
+}
+
+type calcParser struct {
+	s   string
+	pos int
+}
+
+/* calc evaluates the expression s. */
+func calc(s string) (calcValue, error) {
+	p := &calcParser{s: s}
+	v, err := p.binary(0)
+	if err != nil {
+		return calcValue{}, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return calcValue{}, fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+	return v, nil
+}
+
+/* calcLevels are the binary operators by precedence, lowest first. */
+var calcLevels = [][]string{{"|"}, {"^"}, {"&"}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%"}}
+
+func (p *calcParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+/* operator returns the operator of ops at the position, not mistaking ** for *. */
+func (p *calcParser) operator(ops []string) string {
+	p.skipSpace()
+	for _, op := range ops {
+		if strings.HasPrefix(p.s[p.pos:], op) && !strings.HasPrefix(p.s[p.pos:], "**") {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *calcParser) binary(level int) (calcValue, error) {
+	if level == len(calcLevels) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return left, err
+	}
+	for {
+		op := p.operator(calcLevels[level])
+		if op == "" {
+			return left, nil
+		}
+		p.pos += len(op)
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return left, err
+		}
+		if left, err = calcApply(op, left, right); err != nil {
+			return left, err
+		}
+	}
+}
+
+func (p *calcParser) unary() (calcValue, error) {
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		switch c := p.s[p.pos]; c {
+		case '-', '+', '^':
+			p.pos++
+			v, err := p.unary()
+			if err != nil {
+				return v, err
+			}
+			switch {
+			case c == '+':
+			case c == '^' && v.isFloat:
+				return v, fmt.Errorf("^ needs an integer")
+			case c == '^':
+				v.i = ^v.i
+			case v.isFloat:
+				v.f = -v.f
+			default:
+				v.i = -v.i
+			}
+			return v, nil
+		}
+	}
+	return p.power()
+}
+
+func (p *calcParser) power() (calcValue, error) {
+	base, err := p.primary()
+	if err != nil {
+		return base, err
+	}
+	p.skipSpace()
+	if !strings.HasPrefix(p.s[p.pos:], "**") {
+		return base, nil
+	}
+	p.pos += 2
+	exp, err := p.unary() // right associative
+	if err != nil {
+		return exp, err
+	}
+	f := math.Pow(base.float(), exp.float())
+	if base.isFloat || exp.isFloat || exp.i < 0 || math.Abs(f) >= 1<<63 {
+		return calcValue{f: f, isFloat: true}, nil
+	}
+	r, b := int64(1), base.i
+	for n := exp.i; n > 0; n >>= 1 { // by squaring, so 1 ** 100000000000 takes no time
+		if n&1 == 1 {
+			r *= b
+		}
+		b *= b
+	}
+	return calcValue{i: r}, nil
+}
+
+func (p *calcParser) primary() (calcValue, error) {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return calcValue{}, fmt.Errorf("incomplete expression")
+	}
+	if p.s[p.pos] == '(' {
+		p.pos++
+		v, err := p.binary(0)
+		if err != nil {
+			return v, err
+		}
+		p.skipSpace()
+		if p.pos == len(p.s) || p.s[p.pos] != ')' {
+			return v, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return v, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && (strings.IndexByte("0123456789abcdefABCDEFxXoO._", p.s[p.pos]) >= 0 ||
+		(p.pos > start && strings.IndexByte("eE", p.s[p.pos-1]) >= 0 && strings.IndexByte("+-", p.s[p.pos]) >= 0 &&
+			!strings.HasPrefix(strings.ToLower(p.s[start:]), "0x"))) {
+		p.pos++
+	}
+	lit := p.s[start:p.pos]
+	if lit == "" {
+		return calcValue{}, fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+	if i, err := strconv.ParseInt(lit, 0, 64); err == nil {
+		return calcValue{i: i}, nil
+	}
+	if f, err := strconv.ParseFloat(lit, 64); err == nil {
+		return calcValue{f: f, isFloat: true}, nil
+	}
+	return calcValue{}, fmt.Errorf("%q is not a number", lit)
+}
+
+/* calcApply applies the binary operator op. */
+func calcApply(op string, a, b calcValue) (calcValue, error) {
+	if !a.isFloat && !b.isFloat {
+		switch op {
+		case "+":
+			return calcValue{i: a.i + b.i}, nil
+		case "-":
+			return calcValue{i: a.i - b.i}, nil
+		case "*":
+			return calcValue{i: a.i * b.i}, nil
+		case "/", "%":
+			if b.i == 0 {
+				return a, fmt.Errorf("division by zero")
+			}
+			if op == "%" {
+				return calcValue{i: a.i % b.i}, nil
+			}
+			if a.i%b.i == 0 {
+				return calcValue{i: a.i / b.i}, nil
+			}
+		case "&":
+			return calcValue{i: a.i & b.i}, nil
+		case "|":
+			return calcValue{i: a.i | b.i}, nil
+		case "^":
+			return calcValue{i: a.i ^ b.i}, nil
+		case "<<", ">>":
+			if b.i < 0 || b.i > 63 {
+				return a, fmt.Errorf("shift by %d", b.i)
+			}
+			if op == "<<" {
+				return calcValue{i: a.i << b.i}, nil
+			}
+			return calcValue{i: a.i >> b.i}, nil
+		}
+	}
+
+	x, y := a.float(), b.float()
+	switch op {
+	case "+":
+		return calcValue{f: x + y, isFloat: true}, nil
+	case "-":
+		return calcValue{f: x - y, isFloat: true}, nil
+	case "*":
+		return calcValue{f: x * y, isFloat: true}, nil
+	case "/":
+		if y == 0 {
+			return a, fmt.Errorf("division by zero")
+		}
+		return calcValue{f: x / y, isFloat: true}, nil
+	case "%":
+		if y == 0 {
+			return a, fmt.Errorf("division by zero")
+		}
+		return calcValue{f: math.Mod(x, y), isFloat: true}, nil
+	}
+	return a, fmt.Errorf("%s needs integers", op)
+}
+
+func (e *Editor) calc() {
+	input, ok := e.Prompt("Calc: ", func(s string) error {
+		_, err := calc(s)
+		return err
+	}, nil)
+	if !ok || strings.TrimSpace(input) == "" {
+		return
+	}
+	v, _ := calc(input)
+	shown := v.String()
+	if !v.isFloat && (v.i < 0 || v.i > 9) {
+		shown += fmt.Sprintf(" (%#x)", v.i)
+	}
+	e.setStatusMsg("%s = %s  (i inserts it)", strings.TrimSpace(input), shown)
+	e.refreshScreen()
+
+	k, err := e.readKey()
+	if err != nil {
+		return
+	}
+	if k != 'i' || e.isReadonly() {
+		e.setStatusMsg("%s = %s", strings.TrimSpace(input), shown)
+		e.replayKeys = append([]int{k}, e.replayKeys...) // handled as usual
+		return
+	}
+	e.insertText(v.String())
+	e.setStatusMsg("")
+}
@@ -0,0 +1,49 @@
+package editor
+
+import "testing"
+
+func TestCalc(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want string // the value, or "error"
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"10 - 4 - 3", "3"},
+		{"2 ** 3 ** 2", "512"},
+		{"(2 ** 3) ** 2", "64"},
+		{"-2 ** 2", "-4"},
+		{"2 ** -1", "0.5"},
+		{"2 ** 62", "4611686018427387904"},
+		{"2 ** 64", "1.8446744073709552e+19"},
+		{"1 ** 100000000000", "1"},
+		{"(-1) ** 100000000001", "-1"},
+		{"0x1f + 0o17 + 0b101", "51"},
+		{"1 << 4", "16"},
+		{"256 >> 4", "16"},
+		{"1 + 1 << 2", "8"},
+		{"1 << 64", "error"},
+		{"6 & 3 | 8", "10"},
+		{"5 ^ 1", "4"},
+		{"^0", "-1"},
+		{"7 / 2", "3.5"},
+		{"6 / 3", "2"},
+		{"7 % 4", "3"},
+		{"1 / 0", "error"},
+		{"5 % 0", "error"},
+		{"1.5 / 0", "error"},
+		{"1.5 * 2", "3"},
+		{"(1 + 2", "error"},
+		{"1 +", "error"},
+		{"1 2", "error"},
+	} {
+		v, err := calc(tc.expr)
+		got := v.String()
+		if err != nil {
+			got = "error"
+		}
+		if got != tc.want {
+			t.Errorf("calc(%q) = %s (%v), want %s", tc.expr, got, err, tc.want)
+		}
+	}
+}
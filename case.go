@@ -0,0 +1,109 @@
+package editor
+
+import "unicode"
+
+/*-----------------------------------------------------------------------------
+ * Change case
+ *
+ * The "upcase", "downcase" and "capitalize" actions change the case of the
+ * selection, or else of the word at the cursor, as one change that
+ * undo_replace undoes, and leave the cursor after it.
+ */
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+/* wordAt returns the start and end of the word at or just before x in chars. */
+func wordAt(chars []rune, x int) (int, int, bool) {
+	if x >= len(chars) || !isWordChar(chars[x]) {
+		if x == 0 || x > len(chars) || !isWordChar(chars[x-1]) {
+			return 0, 0, false
+		}
+		x--
+	}
+	from, to := x, x+1
+	for from > 0 && isWordChar(chars[from-1]) {
+		from--
+	}
+	for to < len(chars) && isWordChar(chars[to]) {
+		to++
+	}
+	return from, to, true
+}
+
+/* caseRange returns the text changeCase works on: the selection or else the word at the cursor. */
+func (e *Editor) caseRange() (point, point, bool) {
+	if from, to, ok := e.buf.selection(); ok {
+		return from, to, true
+	}
+	c := e.buf.cursor
+	if c.y >= len(e.buf.lines) {
+		return point{}, point{}, false
+	}
+	from, to, ok := wordAt(e.buf.lines[c.y].chars, c.x)
+	return point{x: from, y: c.y}, point{x: to, y: c.y}, ok
+}
+
+/* changeCase maps the characters of the selection or the word at the cursor with convert, which gets whether a character starts a word. */
+func (e *Editor) changeCase(convert func(r rune, start bool) rune) {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	from, to, ok := e.caseRange()
+	if !ok {
+		e.setStatusMsg("No word at the cursor")
+		e.bell()
+		return
+	}
+	last := to.y
+	if last >= len(e.buf.lines) {
+		last = len(e.buf.lines) - 1
+	}
+	if !e.canEdit(from.y, last) {
+		return
+	}
+
+	b := e.buf
+	u := replaceSnapshot(b)
+	for y := from.y; y <= last; y++ {
+		chars := append([]rune{}, e.buf.lines[y].chars...) // other copies of the line may share its characters
+		lo, hi := 0, len(chars)
+		if y == from.y {
+			lo = from.x
+		}
+		if y == to.y {
+			hi = to.x
+		}
+		for x := lo; x < hi; x++ {
+			start := x == 0 || !isWordChar(chars[x-1])
+			chars[x] = convert(chars[x], start)
+		}
+		e.buf.lines[y].chars = chars
+		e.buf.lines[y].render = e.updateRow(chars)
+	}
+	e.buf.dirty = true
+	e.keepReplaceUndo(u)
+	e.changes++
+	e.setCursor(to)
+	e.snapCursor()
+}
+
+func (e *Editor) upcase() {
+	e.changeCase(func(r rune, _ bool) rune { return unicode.ToUpper(r) })
+}
+
+func (e *Editor) downcase() {
+	e.changeCase(func(r rune, _ bool) rune { return unicode.ToLower(r) })
+}
+
+func (e *Editor) capitalize() {
+	e.changeCase(func(r rune, start bool) rune {
+		if start {
+			return unicode.ToTitle(r)
+		}
+		return unicode.ToLower(r)
+	})
+}
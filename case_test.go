@@ -0,0 +1,20 @@
+package editor
+
+import "testing"
+
+func TestChangeCaseUndo(t *testing.T) {
+	bindings := `{"ctrl+b": "upcase", "ctrl+w": "capitalize", "ctrl+o": "undo_replace"}`
+	for _, tc := range []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"\x02"}, "HELLO world\n"},
+		{[]string{"\x02", "\x0f"}, "hello world\n"},
+		{[]string{"\x00", "\x05", "\x17"}, "Hello World\n"},
+		{[]string{"\x00", "\x05", "\x17", "\x0f"}, "hello world\n"},
+	} {
+		if text := runKeys(t, "hello world\n", bindings, tc.keys...); text != tc.want {
+			t.Errorf("%q: text is %q, want %q", tc.keys, text, tc.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Checkpoints
+ *
+ * Checkpoints are named copies of the text of a buffer kept for the session.
+ * The "checkpoint" action asks for a name, such as before-refactor, and keeps
+ * the text under it, replacing a checkpoint of the same name. The
+ * "checkpoints" action lists those of the current buffer to view, diff with
+ * the buffer, restore or delete. Restoring keeps the text it replaces as the
+ * checkpoint "before restoring <name>", so it can be undone. Secure mode
+ * keeps no checkpoints.
+ */
+
+type checkpoint struct {
+	name string
+	text string
+	at   time.Time
+}
+
+/* setCheckpoint keeps the text of b as the checkpoint name. */
+func (b *buffer) setCheckpoint(name string) {
+	cp := checkpoint{name: name, text: b.text(), at: time.Now()}
+	for i, c := range b.checkpoints {
+		if c.name == name {
+			b.checkpoints[i] = cp
+			return
+		}
+	}
+	b.checkpoints = append(b.checkpoints, cp)
+}
+
+func (e *Editor) checkpoint() {
+	if e.secure {
+		e.setStatusMsg("checkpoints are off in secure mode")
+		e.bell()
+		return
+	}
+	name, ok := e.Prompt("Checkpoint name: ", nil, nil)
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return
+	}
+	e.buf.setCheckpoint(name)
+	e.setStatusMsg("Checkpoint %s set, checkpoints lists them", name)
+}
+
+func (e *Editor) checkpoints() {
+	b := e.buf
+	if len(b.checkpoints) == 0 {
+		e.setStatusMsg("No checkpoints, checkpoint sets one")
+		e.bell()
+		return
+	}
+	items := make([]string, len(b.checkpoints))
+	for i, c := range b.checkpoints {
+		items[i] = fmt.Sprintf("%s  %s", c.at.Format("15:04:05"), c.name)
+	}
+	i, ok := e.Pick("Checkpoints of "+bufferTitle(b), items)
+	if !ok {
+		return
+	}
+	cp := b.checkpoints[i]
+	lines := strings.Split(strings.TrimSuffix(cp.text, "\n"), "\n")
+	if cp.text == "" {
+		lines = nil
+	}
+
+	what, ok := e.Pick(cp.name, []string{"View", "Diff with buffer", "Restore", "Delete"})
+	if !ok {
+		return
+	}
+	tab := strings.Repeat(" ", e.tabStop)
+	switch what {
+	case 0:
+		view := make([]string, len(lines))
+		for j, l := range lines {
+			view[j] = strings.ReplaceAll(l, "\t", tab)
+		}
+		e.ShowPopup(cp.name, view)
+
+	case 1:
+		diff := unifiedDiff(cp.name, "buffer", lines, b.textLines(), 3)
+		if diff == nil {
+			e.setStatusMsg("The buffer is the same as %s", cp.name)
+			return
+		}
+		for j, l := range diff {
+			diff[j] = strings.ReplaceAll(l, "\t", tab)
+		}
+		e.ShowPopup("Changes since "+cp.name, diff)
+
+	case 2:
+		if e.isReadonly() || len(b.protected) > 0 || b.narrow != nil {
+			e.setStatusMsg("buffer can not be replaced")
+			e.bell()
+			return
+		}
+		if b.text() == cp.text {
+			e.setStatusMsg("The buffer is the same as %s", cp.name)
+			return
+		}
+		b.setCheckpoint("before restoring " + cp.name)
+		e.replaceRange(point{}, point{y: len(b.lines)}, cp.text)
+		e.snapCursor()
+		e.setStatusMsg("restored %s, checkpoints has the text it replaced", cp.name)
+
+	case 3:
+		b.checkpoints = append(b.checkpoints[:i], b.checkpoints[i+1:]...)
+		e.setStatusMsg("deleted checkpoint %s", cp.name)
+	}
+}
@@ -0,0 +1,54 @@
+package editor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Clipboard
+ *
+ * Text is put on the system clipboard with pbcopy on macOS, wl-copy under
+ * Wayland or xclip under X, which are given the type of the data. Without
+ * them, and over ssh, the terminal is asked to do it with OSC 52, which only
+ * takes plain text and which some terminals ignore.
+ */
+
+/* clipboardCommand returns the command that puts data of the MIME type on the clipboard, nil if there is none. */
+func clipboardCommand(mime string) *exec.Cmd {
+	look := func(name string, args ...string) *exec.Cmd {
+		if _, err := exec.LookPath(name); err != nil {
+			return nil
+		}
+		return exec.Command(name, args...)
+	}
+	if os.Getenv("SSH_CONNECTION") != "" {
+		return nil // the clipboard of the machine the user sits at
+	}
+	switch {
+	case runtime.GOOS == "darwin":
+		return look("pbcopy") // takes RTF as RTF by its header
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return look("wl-copy", "--type", mime)
+	case os.Getenv("DISPLAY") != "":
+		return look("xclip", "-selection", "clipboard", "-t", mime)
+	}
+	return nil
+}
+
+/* copyToClipboard puts data of the MIME type on the clipboard and tells how. */
+func (e *Editor) copyToClipboard(data, mime string) (string, error) {
+	if cmd := clipboardCommand(mime); cmd != nil {
+		cmd.Stdin = strings.NewReader(data)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("%s: %s %s", cmd.Args[0], err, strings.TrimSpace(string(out)))
+		}
+		return cmd.Args[0], nil
+	}
+	fmt.Fprintf(e.term, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(data)))
+	return "the terminal", nil
+}
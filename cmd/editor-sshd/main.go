@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/pergus/editor"
+	"golang.org/x/crypto/ssh"
+)
+
+func main() {
+	home, _ := os.UserHomeDir()
+	addr := flag.String("addr", ":2222", "address to listen on")
+	hostKey := flag.String("hostkey", "", "path to the private host key")
+	authorizedKeys := flag.String("authorized-keys", filepath.Join(home, ".ssh", "authorized_keys"), "path to the keys allowed to log in")
+	flag.Parse()
+
+	if err := serve(*addr, *hostKey, *authorizedKeys); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serve(addr, hostKey, authorizedKeys string) error {
+	if hostKey == "" {
+		return fmt.Errorf("a host key is required, see -hostkey")
+	}
+	keyData, err := os.ReadFile(hostKey)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		return err
+	}
+
+	authData, err := os.ReadFile(authorizedKeys)
+	if err != nil {
+		return err
+	}
+	allowed := [][]byte{}
+	for len(authData) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(authData)
+		if err != nil {
+			break
+		}
+		allowed = append(allowed, key.Marshal())
+		authData = rest
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, k := range allowed {
+				if bytes.Equal(k, key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown public key for %s", conn.User())
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return ServeSSH(l, config)
+}
@@ -1,23 +1,97 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	. "github.com/pergus/editor"
 )
 
 func main() {
-	var err error
-	readonly := false
+	readonly := flag.Bool("readonly", false, "do not allow the files to be edited")
+	keymap := flag.String("keymap", "", "load key bindings from this file instead of keymap.json")
+	tabStop := flag.Int("tab-stop", 0, "number of columns a tab is drawn as")
+	theme := flag.String("theme", "", "colour theme: "+strings.Join(themeNames(), ", ")+" or one in the themes directory")
+	line := flag.Int("line", 0, "put the cursor on this line of the first file")
+	version := flag.Bool("version", false, "print the version and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *version {
+		fmt.Println(Version)
+		return
+	}
+
+	opts := []Option{}
+	if *readonly {
+		opts = append(opts, WithReadOnly())
+	}
+	if *keymap != "" {
+		opts = append(opts, WithKeymapFile(*keymap))
+	}
+	if *tabStop != 0 {
+		if *tabStop < 0 {
+			fail(fmt.Errorf("-tab-stop must be positive"))
+		}
+		opts = append(opts, WithTabStop(*tabStop))
+	}
+	if *theme != "" {
+		t, err := LoadTheme(*theme)
+		if err != nil {
+			fail(err)
+		}
+		opts = append(opts, WithTheme(t))
+	}
+	if *line != 0 {
+		opts = append(opts, WithLine(*line))
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		if err := Edit("", opts...); err != nil {
+			fail(err)
+		}
+		return
+	}
 
-	if len(os.Args) == 2 {
-		err = Editor(os.Args[1], readonly)
+	if flag.NFlag() == 0 {
+		/* hand the files over to an editor that is already running */
+		err := OpenRemote(files[0])
+		for _, name := range files[1:] {
+			if err != nil {
+				break
+			}
+			err = OpenRemote(name)
+		}
+		if err == nil {
+			return
+		}
+		if err != ErrNoServer {
+			fail(err)
+		}
+	}
 
-	} else {
-		err = Editor("", readonly)
+	if err := Edit(files[0], append(opts, WithFiles(files[1:]...))...); err != nil {
+		fail(err)
 	}
-	if err != nil {
-		fmt.Printf("%v", err)
+}
+
+func themeNames() []string {
+	names := []string{}
+	for name := range Themes {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	os.Exit(1)
 }
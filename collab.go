@@ -0,0 +1,515 @@
+package editor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Collaborative editing
+ *
+ * One editor hosts a session with the "collab_host" action and others join it
+ * with "collab_join". The text is kept in a replicated growable array (RGA),
+ * a sequence CRDT where every character has a unique id and is inserted after
+ * the character it was typed after, so concurrent edits merge the same way on
+ * every peer. The host relays operations between the peers.
+ *
+ * Messages are JSON objects, one per line:
+ *
+ *	{"type": "welcome", "site": 2, "elems": [...]}  host to a new peer
+ *	{"type": "ops", "ops": [...]}                    inserted and deleted characters
+ *	{"type": "cursor", "site": 2, "id": {...}}       a peer moved its cursor
+ *	{"type": "bye", "site": 2}                       a peer left the session
+ */
+
+type charID struct {
+	Clock int `json:"c"` // lamport clock of the insert
+	Site  int `json:"s"` // the peer that inserted the character
+}
+
+func (a charID) less(b charID) bool {
+	return a.Clock < b.Clock || (a.Clock == b.Clock && a.Site < b.Site)
+}
+
+/* rootID is the id of the virtual character before the start of the text. */
+var rootID = charID{}
+
+type crdtElem struct {
+	ID      charID `json:"id"`
+	Ch      rune   `json:"ch"`
+	Deleted bool   `json:"d,omitempty"`
+}
+
+type collabOp struct {
+	Delete bool   `json:"del,omitempty"`
+	ID     charID `json:"id"`
+	After  charID `json:"after,omitempty"`
+	Ch     rune   `json:"ch,omitempty"`
+}
+
+type collabMsg struct {
+	Type  string     `json:"type"`
+	Site  int        `json:"site,omitempty"`
+	Elems []crdtElem `json:"elems,omitempty"`
+	Ops   []collabOp `json:"ops,omitempty"`
+	ID    charID     `json:"id,omitempty"`
+}
+
+type collabPeer struct {
+	site int
+	out  chan collabMsg
+	conn net.Conn
+}
+
+type collabSession struct {
+	e        *Editor
+	buf      *buffer
+	site     int
+	clock    int
+	elems    []crdtElem
+	host     bool
+	listener net.Listener        // set when hosting
+	peers    map[int]*collabPeer // peers connected to the host, or the host when joined
+	nextSite int                 // next site number handed out by the host
+	cursors  map[int]charID      // remote cursors
+}
+
+/*
+ * CRDT
+ */
+
+func (s *collabSession) indexOf(id charID) int {
+	if id == rootID {
+		return -1
+	}
+	for i := range s.elems {
+		if s.elems[i].ID == id {
+			return i
+		}
+	}
+	return -2
+}
+
+func (s *collabSession) apply(op collabOp) {
+	if op.ID.Clock > s.clock {
+		s.clock = op.ID.Clock
+	}
+
+	i := s.indexOf(op.ID)
+	if op.Delete {
+		if i >= 0 {
+			s.elems[i].Deleted = true
+		}
+		return
+	}
+	if i >= 0 {
+		return // already integrated
+	}
+
+	i = s.indexOf(op.After)
+	if i == -2 {
+		return // unknown anchor
+	}
+
+	/* skip characters inserted concurrently after the same anchor with a higher id */
+	i++
+	for i < len(s.elems) && op.ID.less(s.elems[i].ID) {
+		i++
+	}
+
+	s.elems = append(s.elems, crdtElem{})
+	copy(s.elems[i+1:], s.elems[i:])
+	s.elems[i] = crdtElem{ID: op.ID, Ch: op.Ch}
+}
+
+/* visible returns the visible characters and the index in elems of each of them. */
+func (s *collabSession) visible() ([]rune, []int) {
+	text := []rune{}
+	index := []int{}
+	for i, e := range s.elems {
+		if !e.Deleted {
+			text = append(text, e.Ch)
+			index = append(index, i)
+		}
+	}
+	return text, index
+}
+
+/*
+ * Buffer mapping
+ */
+
+func bufferText(b *buffer) []rune {
+	text := []rune{}
+	for i, l := range b.lines {
+		if i > 0 {
+			text = append(text, '\n')
+		}
+		text = append(text, l.chars...)
+	}
+	return text
+}
+
+func bufferOffset(b *buffer, p point) int {
+	off := 0
+	for y := 0; y < p.y && y < len(b.lines); y++ {
+		off += len(b.lines[y].chars) + 1
+	}
+	return off + p.x
+}
+
+func textPoint(text []rune, off int) point {
+	p := point{}
+	for _, r := range text[:off] {
+		if r == '\n' {
+			p.y++
+			p.x = 0
+		} else {
+			p.x++
+		}
+	}
+	return p
+}
+
+/* anchor returns the id of the visible character before offset off. */
+func (s *collabSession) anchor(off int) charID {
+	_, index := s.visible()
+	if off <= 0 || len(index) == 0 {
+		return rootID
+	}
+	if off > len(index) {
+		off = len(index)
+	}
+	return s.elems[index[off-1]].ID
+}
+
+/* anchorOffset returns the offset just after the character id, or after the nearest visible character before it. */
+func (s *collabSession) anchorOffset(id charID) int {
+	i := s.indexOf(id)
+	off := 0
+	for j := 0; j <= i && j < len(s.elems); j++ {
+		if !s.elems[j].Deleted {
+			off++
+		}
+	}
+	return off
+}
+
+/* diff turns the changes made to the buffer into operations. */
+func (s *collabSession) diff() []collabOp {
+	old, index := s.visible()
+	cur := bufferText(s.buf)
+
+	prefix := 0
+	for prefix < len(old) && prefix < len(cur) && old[prefix] == cur[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(cur)-prefix &&
+		old[len(old)-1-suffix] == cur[len(cur)-1-suffix] {
+		suffix++
+	}
+
+	ops := []collabOp{}
+	for k := prefix; k < len(old)-suffix; k++ {
+		ops = append(ops, collabOp{Delete: true, ID: s.elems[index[k]].ID})
+	}
+
+	after := rootID
+	if prefix > 0 {
+		after = s.elems[index[prefix-1]].ID
+	}
+	for _, r := range cur[prefix : len(cur)-suffix] {
+		s.clock++
+		id := charID{Clock: s.clock, Site: s.site}
+		ops = append(ops, collabOp{ID: id, After: after, Ch: r})
+		after = id
+	}
+	return ops
+}
+
+/* rebuild replaces the lines of the buffer with the text of the document, keeping the cursor in place. */
+func (s *collabSession) rebuild(cursor charID) {
+	text, _ := s.visible()
+
+	s.buf.lines = []line{}
+	if len(text) > 0 {
+		for _, l := range strings.Split(string(text), "\n") {
+			rns := []rune(l)
+			s.buf.lines = append(s.buf.lines, line{chars: rns, render: s.e.updateRow(rns)})
+		}
+	}
+	s.buf.dirty = true
+	s.buf.dropLostBookmarks()
+	s.e.changes++
+
+	s.buf.cursor = textPoint(text, s.anchorOffset(cursor))
+}
+
+/*
+ * Session
+ */
+
+func (e *Editor) collabHost() {
+	if e.collab != nil {
+		e.setStatusMsg("Already in a collaboration session")
+		return
+	}
+	addr := e.prompt("Host on address (e.g. :7070): %s")
+	if addr == "" {
+		return
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		e.setStatusMsg("collab: %s", err)
+		return
+	}
+
+	e.widen()
+	s := newCollabSession(e, e.buf, 1)
+	s.host = true
+	s.listener = l
+	s.nextSite = 2
+	for _, r := range bufferText(s.buf) {
+		s.clock++
+		s.elems = append(s.elems, crdtElem{ID: charID{Clock: s.clock, Site: s.site}, Ch: r})
+	}
+	e.startCollab(s)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			e.queueTask(func() { s.addPeer(conn) })
+		}
+	}()
+
+	e.setStatusMsg("Hosting collaboration session on %s", l.Addr())
+}
+
+func (e *Editor) collabJoin() {
+	if e.collab != nil {
+		e.setStatusMsg("Already in a collaboration session")
+		return
+	}
+	addr := e.prompt("Join session at address: %s")
+	if addr == "" {
+		return
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		e.setStatusMsg("collab: %s", err)
+		return
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	var welcome collabMsg
+	if err := dec.Decode(&welcome); err != nil || welcome.Type != "welcome" {
+		conn.Close()
+		e.setStatusMsg("collab: no welcome from %s", addr)
+		return
+	}
+
+	s := newCollabSession(e, e.addBuffer(), welcome.Site)
+	s.elems = welcome.Elems
+	for _, e := range s.elems {
+		if e.ID.Clock > s.clock {
+			s.clock = e.ID.Clock
+		}
+	}
+	s.rebuild(rootID)
+	s.buf.dirty = false
+	host := &collabPeer{site: 1, out: make(chan collabMsg, 256), conn: conn}
+	s.peers[1] = host
+	go host.write()
+	go s.read(host, dec)
+	e.startCollab(s)
+
+	e.setStatusMsg("Joined collaboration session at %s", addr)
+}
+
+func (e *Editor) collabLeave() {
+	s := e.collab
+	if s == nil {
+		return
+	}
+	e.collab = nil
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for _, p := range s.peers {
+		if !s.host {
+			p.out <- collabMsg{Type: "bye", Site: s.site}
+		}
+		close(p.out)
+	}
+	e.setStatusMsg("Left the collaboration session")
+}
+
+func newCollabSession(e *Editor, b *buffer, site int) *collabSession {
+	return &collabSession{
+		e:       e,
+		buf:     b,
+		site:    site,
+		peers:   map[int]*collabPeer{},
+		cursors: map[int]charID{},
+	}
+}
+
+func (e *Editor) startCollab(s *collabSession) {
+	e.collab = s
+
+	e.addHook(TextChanged, func(HookEvent) {
+		if e.collab == s {
+			if ops := s.diff(); len(ops) > 0 {
+				for _, op := range ops {
+					s.apply(op)
+				}
+				s.broadcast(collabMsg{Type: "ops", Ops: ops}, 0)
+			}
+			s.sendCursor()
+		}
+	})
+	e.addHook(CursorMoved, func(HookEvent) {
+		if e.collab == s {
+			s.sendCursor()
+		}
+	})
+}
+
+func (s *collabSession) sendCursor() {
+	if s.e.buf != s.buf {
+		return
+	}
+	id := s.anchor(bufferOffset(s.buf, s.buf.cursor))
+	s.broadcast(collabMsg{Type: "cursor", Site: s.site, ID: id}, 0)
+}
+
+/* broadcast sends msg to every peer except the one with the site skip. */
+func (s *collabSession) broadcast(msg collabMsg, skip int) {
+	for site, p := range s.peers {
+		if site != skip {
+			p.out <- msg
+		}
+	}
+}
+
+func (s *collabSession) addPeer(conn net.Conn) {
+	if s.e.collab != s {
+		conn.Close()
+		return
+	}
+
+	p := &collabPeer{site: s.nextSite, out: make(chan collabMsg, 256), conn: conn}
+	s.nextSite++
+	s.peers[p.site] = p
+	go p.write()
+
+	elems := make([]crdtElem, len(s.elems))
+	copy(elems, s.elems)
+	p.out <- collabMsg{Type: "welcome", Site: p.site, Elems: elems}
+	p.out <- collabMsg{Type: "cursor", Site: s.site, ID: s.anchor(bufferOffset(s.buf, s.buf.cursor))}
+	for site, id := range s.cursors {
+		p.out <- collabMsg{Type: "cursor", Site: site, ID: id}
+	}
+
+	go s.read(p, json.NewDecoder(bufio.NewReader(conn)))
+	s.e.setStatusMsg("Peer %d joined the session", p.site)
+}
+
+func (p *collabPeer) write() {
+	enc := json.NewEncoder(p.conn)
+	for msg := range p.out {
+		if err := enc.Encode(msg); err != nil {
+			break
+		}
+	}
+	p.conn.Close()
+	for range p.out {
+		/* drain until the session closes the channel */
+	}
+}
+
+func (s *collabSession) read(p *collabPeer, dec *json.Decoder) {
+	for {
+		var msg collabMsg
+		if err := dec.Decode(&msg); err != nil {
+			break
+		}
+		s.e.queueTask(func() { s.receive(p, msg) })
+	}
+	s.e.queueTask(func() { s.receive(p, collabMsg{Type: "bye", Site: p.site}) })
+}
+
+func (s *collabSession) receive(p *collabPeer, msg collabMsg) {
+	if s.e.collab != s {
+		return
+	}
+
+	switch msg.Type {
+	case "ops":
+		cursor := s.anchor(bufferOffset(s.buf, s.buf.cursor))
+		for _, op := range msg.Ops {
+			s.apply(op)
+		}
+		s.rebuild(cursor)
+		if s.host {
+			s.broadcast(msg, p.site)
+		}
+
+	case "cursor":
+		s.cursors[msg.Site] = msg.ID
+		if s.host {
+			s.broadcast(msg, p.site)
+		}
+
+	case "bye":
+		delete(s.cursors, msg.Site)
+		if s.host {
+			if _, ok := s.peers[msg.Site]; ok && msg.Site == p.site {
+				close(p.out)
+				delete(s.peers, p.site)
+				s.e.setStatusMsg("Peer %d left the session", p.site)
+			}
+			s.broadcast(msg, p.site)
+		} else if msg.Site == p.site {
+			s.e.collab = nil
+			close(p.out)
+			s.e.setStatusMsg("The collaboration session has ended")
+		}
+	}
+}
+
+/*
+ * Drawing
+ */
+
+/* collabMarks returns the escape sequences that color the remote cursors, by line and render column. */
+func (e *Editor) collabMarks() map[int]map[int]string {
+	s := e.collab
+	if s == nil || s.buf != e.buf || len(s.cursors) == 0 {
+		return nil
+	}
+
+	text, _ := s.visible()
+	marks := map[int]map[int]string{}
+	for site, id := range s.cursors {
+		p := textPoint(text, s.anchorOffset(id))
+		if p.y >= len(s.buf.lines) {
+			continue
+		}
+		if marks[p.y] == nil {
+			marks[p.y] = map[int]string{}
+		}
+		rx := e.lineRx(s.buf.lines[p.y], p.x)
+		marks[p.y][rx] = fmt.Sprintf("\x1b[30;%dm", 41+site%6)
+	}
+	return marks
+}
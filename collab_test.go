@@ -0,0 +1,162 @@
+package editor
+
+import (
+	"testing"
+)
+
+/* typed returns the operations of site typing text after the character after, starting at clock. */
+func typed(site, clock int, after charID, text string) []collabOp {
+	ops := []collabOp{}
+	for _, r := range text {
+		id := charID{Clock: clock, Site: site}
+		ops = append(ops, collabOp{ID: id, After: after, Ch: r})
+		after = id
+		clock++
+	}
+	return ops
+}
+
+/* replica returns a session of site that has applied ops. */
+func replica(site int, ops ...[]collabOp) *collabSession {
+	s := newCollabSession(nil, nil, site)
+	for _, o := range ops {
+		for _, op := range o {
+			s.apply(op)
+		}
+	}
+	return s
+}
+
+func visibleText(s *collabSession) string {
+	text, _ := s.visible()
+	return string(text)
+}
+
+func TestCollabConcurrentInsertsConverge(t *testing.T) {
+	base := typed(1, 1, rootID, "ac")
+	a := base[0].ID
+	x := typed(1, 3, a, "X")  // site 1 types after "a"
+	y := typed(2, 3, a, "YZ") // and site 2 at the same time
+
+	one := replica(1, base, x, y)
+	two := replica(2, base, y, x)
+	if visibleText(one) != visibleText(two) {
+		t.Fatalf("replicas differ: %q and %q", visibleText(one), visibleText(two))
+	}
+	if got := visibleText(one); got != "aYZXc" {
+		t.Errorf("got %q, want %q", got, "aYZXc")
+	}
+
+	/* both at the start of the text */
+	p := typed(1, 3, rootID, "P")
+	q := typed(3, 3, rootID, "Q")
+	if a, b := visibleText(replica(1, base, p, q)), visibleText(replica(3, base, q, p)); a != b {
+		t.Errorf("replicas differ at the start: %q and %q", a, b)
+	}
+}
+
+func TestCollabDeleteThenInsert(t *testing.T) {
+	base := typed(1, 1, rootID, "abc")
+	b := base[1].ID
+	del := []collabOp{{Delete: true, ID: b}}
+	ins := typed(2, 4, b, "X") // typed after "b" before the delete arrived
+
+	for _, s := range []*collabSession{replica(1, base, del, ins), replica(2, base, ins, del)} {
+		if got := visibleText(s); got != "aXc" {
+			t.Errorf("site %d: got %q, want %q", s.site, got, "aXc")
+		}
+	}
+
+	/* applying an operation twice changes nothing */
+	if got := visibleText(replica(1, base, ins, ins, del, del)); got != "aXc" {
+		t.Errorf("repeated operations: got %q, want %q", got, "aXc")
+	}
+}
+
+func TestCollabDiffRebuild(t *testing.T) {
+	e := New(WithTerminal(NewHeadless(8, 40)))
+	host := newCollabSession(e, &buffer{}, 1)
+	host.buf.lines = testLines("hello world")
+	ops := host.diff()
+	for _, op := range ops {
+		host.apply(op)
+	}
+	peer := newCollabSession(e, &buffer{}, 2)
+	for _, op := range ops {
+		peer.apply(op)
+	}
+
+	host.buf.lines = testLines("hello there\nworld")
+	edit := host.diff()
+	for _, op := range edit {
+		host.apply(op)
+	}
+	for _, op := range edit {
+		peer.apply(op)
+	}
+	if got, want := visibleText(host), "hello there\nworld"; got != want {
+		t.Errorf("host: got %q, want %q", got, want)
+	}
+	if len(host.diff()) != 0 {
+		t.Error("diff of an unchanged buffer is not empty")
+	}
+
+	peer.rebuild(peer.anchor(len("hello there\nwo")))
+	if got, want := string(bufferText(peer.buf)), "hello there\nworld"; got != want {
+		t.Errorf("peer buffer: got %q, want %q", got, want)
+	}
+	if want := (point{x: 2, y: 1}); peer.buf.cursor != want {
+		t.Errorf("peer cursor: got %v, want %v", peer.buf.cursor, want)
+	}
+}
+
+func TestCollabHostBye(t *testing.T) {
+	e := New(WithTerminal(NewHeadless(8, 40)))
+	s := newCollabSession(e, e.buf, 1)
+	s.host = true
+	e.collab = s
+	leaving := &collabPeer{site: 2, out: make(chan collabMsg, 8)}
+	staying := &collabPeer{site: 3, out: make(chan collabMsg, 8)}
+	s.peers[2], s.peers[3] = leaving, staying
+	s.cursors[2] = charID{Clock: 1, Site: 2}
+
+	s.receive(leaving, collabMsg{Type: "bye", Site: 2})
+	if _, ok := s.peers[2]; ok {
+		t.Error("the peer that left is still in the session")
+	}
+	if _, ok := s.cursors[2]; ok {
+		t.Error("the cursor of the peer that left is still shown")
+	}
+	if _, ok := <-leaving.out; ok {
+		t.Error("the output of the peer that left is not closed")
+	}
+	if msg := <-staying.out; msg.Type != "bye" || msg.Site != 2 {
+		t.Errorf("the other peer got %+v, want the bye", msg)
+	}
+
+	/* a peer can't say bye for another */
+	s.receive(staying, collabMsg{Type: "bye", Site: 4})
+	if _, ok := s.peers[3]; !ok || e.collab != s {
+		t.Error("a bye for another site ended the session of the peer")
+	}
+}
+
+func TestCollabPeerBye(t *testing.T) {
+	e := New(WithTerminal(NewHeadless(8, 40)))
+	s := newCollabSession(e, e.buf, 2)
+	e.collab = s
+	host := &collabPeer{site: 1, out: make(chan collabMsg, 8)}
+	s.peers[1] = host
+
+	s.receive(host, collabMsg{Type: "bye", Site: 3}) // another peer left
+	if e.collab != s {
+		t.Fatal("the bye of another peer ended the session")
+	}
+	s.receive(host, collabMsg{Type: "bye", Site: 1})
+	if e.collab != nil {
+		t.Error("the session goes on after the host left")
+	}
+	if _, ok := <-host.out; ok {
+		t.Error("the output to the host is not closed")
+	}
+}
@@ -0,0 +1,94 @@
+package editor
+
+/*-----------------------------------------------------------------------------
+ * Compose
+ *
+ * Characters typed on international layouts arrive as UTF-8 and are inserted
+ * as they are. For characters the keyboard has no key for, the compose key
+ * (ctrl+] by default, action "compose") is followed by two characters, such
+ * as ' e for é or o a for å. The two can be typed in either order.
+ */
+
+/*
+composeTable lists, for each accent character, pairs of a base character and
+the character it composes to.
+*/
+var composeTable = map[rune]string{
+	'\'': "aáeéiíoóuúyýcćnńsśzźAÁEÉIÍOÓUÚYÝCĆNŃSŚZŹ",
+	'`':  "aàeèiìoòuùAÀEÈIÌOÒUÙ",
+	'^':  "aâeêiîoôuûAÂEÊIÎOÔUÛ",
+	'"':  "aäeëiïoöuüyÿAÄEËIÏOÖUÜ",
+	'~':  "aãnñoõAÃNÑOÕ",
+	'o':  "aåAÅ",
+	',':  "cçsşCÇSŞ",
+	'/':  "oøOØlłLŁ",
+	'v':  "cčsšzžrřeěCČSŠZŽRŘEĚ",
+}
+
+/* composeSpecial lists compositions of two characters that are not an accent on a letter. */
+var composeSpecial = map[[2]rune]rune{
+	{'a', 'e'}: 'æ',
+	{'A', 'E'}: 'Æ',
+	{'s', 's'}: 'ß',
+	{'o', 'e'}: 'œ',
+	{'O', 'E'}: 'Œ',
+	{'=', 'e'}: '€',
+	{'-', 'l'}: '£',
+	{'=', 'y'}: '¥',
+	{'o', 'c'}: '©',
+	{'o', 'r'}: '®',
+	{'!', '!'}: '¡',
+	{'?', '?'}: '¿',
+	{'<', '<'}: '«',
+	{'>', '>'}: '»',
+	{'o', 'o'}: '°',
+	{'+', '-'}: '±',
+	{'x', 'x'}: '×',
+	{'-', ':'}: '÷',
+	{'.', '.'}: '…',
+	{'-', '-'}: '–',
+}
+
+/* composeRune returns the character a and b compose to, in either order. */
+func composeRune(a, b rune) (rune, bool) {
+	for _, p := range [][2]rune{{a, b}, {b, a}} {
+		if r, ok := composeSpecial[p]; ok {
+			return r, true
+		}
+		pairs := []rune(composeTable[p[0]])
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if pairs[i] == p[1] {
+				return pairs[i+1], true
+			}
+		}
+	}
+	return 0, false
+}
+
+/* compose reads two characters and inserts the character they compose to. */
+func (e *Editor) compose() {
+	if e.isReadonly() {
+		return
+	}
+
+	keys := []rune{}
+	for len(keys) < 2 {
+		e.setStatusMsg("Compose: %s", string(keys))
+		e.refreshScreen()
+		k, err := e.readKey()
+		if err != nil || k == '\x1b' || k >= kArrowUp {
+			e.setStatusMsg("")
+			return
+		}
+		keys = append(keys, rune(k))
+	}
+
+	r, ok := composeRune(keys[0], keys[1])
+	if !ok {
+		e.setStatusMsg("Nothing composes from %s", string(keys))
+		e.bell()
+		return
+	}
+	e.setStatusMsg("")
+	e.insertChar(int(r))
+}
@@ -0,0 +1,186 @@
+package editor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/*-----------------------------------------------------------------------------
+ * Compressed files
+ *
+ * Files compressed with gzip, bzip2 or zstd, recognized by their first bytes
+ * or their extension, are decompressed into the buffer and compressed again
+ * when saved. The standard library only reads bzip2, so bzip2 files are
+ * compressed by running the bzip2 command, and saving them fails, saying so,
+ * where it is not installed.
+ */
+
+type compression struct {
+	name   string
+	ext    string
+	magic  []byte
+	check  func(head []byte) bool // checks the bytes after the magic ones, nil if the magic is enough
+	reader func(io.Reader) (io.Reader, error)
+	writer func(io.Writer) (io.WriteCloser, error)
+}
+
+var compressions = []*compression{
+	{
+		name:   "gzip",
+		ext:    ".gz",
+		magic:  []byte{0x1f, 0x8b},
+		reader: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		writer: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+	},
+	{
+		name:   "bzip2",
+		ext:    ".bz2",
+		magic:  []byte("BZh"),
+		check:  bzip2Head,
+		reader: func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil },
+		writer: func(w io.Writer) (io.WriteCloser, error) { return newCommandWriter(w, "bzip2", "-c") },
+	},
+	{
+		name:   "zstd",
+		ext:    ".zst",
+		magic:  []byte{0x28, 0xb5, 0x2f, 0xfd},
+		reader: func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		writer: func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+	},
+}
+
+var (
+	bzip2Block = []byte("1AY&SY")                           // magic of a compressed block
+	bzip2End   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90} // magic of the end of a stream, first in an empty one
+)
+
+/* bzip2Head reports whether head, starting with BZh, goes on as a bzip2 stream: a block size digit and a block or the end. */
+func bzip2Head(head []byte) bool {
+	if len(head) < 10 || head[3] < '1' || head[3] > '9' {
+		return false
+	}
+	return bytes.Equal(head[4:10], bzip2Block) || bytes.Equal(head[4:10], bzip2End)
+}
+
+/*
+detectCompression returns the compression of the file name read by r, or nil.
+The magic bytes decide for files with content, the extension for empty ones.
+*/
+func detectCompression(name string, r *bufio.Reader) *compression {
+	head, _ := r.Peek(10)
+	for _, c := range compressions {
+		if bytes.HasPrefix(head, c.magic) && (c.check == nil || c.check(head)) {
+			return c
+		}
+	}
+	if len(head) == 0 {
+		for _, c := range compressions {
+			if filepath.Ext(name) == c.ext {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+/* openDecompressed opens the file name for reading, decompressing it if it is compressed, and returns its text, its compression and what to close when done. */
+func (e *Editor) openDecompressed(name string) (io.Reader, *compression, io.Closer, error) {
+	f, err := e.openForReading(name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	br := bufio.NewReader(f)
+	c := detectCompression(name, br)
+	if c == nil {
+		return br, nil, f, nil
+	}
+	r, err := c.reader(br)
+	if err != nil {
+		f.Close()
+		return nil, nil, nil, err
+	}
+	return r, c, f, nil
+}
+
+/* compressionFor returns the compression a new file called name is saved with, or nil. */
+func compressionFor(name string) *compression {
+	for _, c := range compressions {
+		if filepath.Ext(name) == c.ext {
+			return c
+		}
+	}
+	return nil
+}
+
+/* fileData returns the text of b as it is written to its file, compressed if the file is. */
+func (b *buffer) fileData() ([]byte, error) {
+	if b.compression == nil {
+		return []byte(b.text()), nil
+	}
+
+	var out bytes.Buffer
+	w, err := b.compression.writer(&out)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, b.text()); err != nil {
+		if cerr := w.Close(); cerr != nil {
+			return nil, cerr // says why the writer stopped taking the text
+		}
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+/* commandWriter compresses with an external command, for formats the standard library can only read. */
+type commandWriter struct {
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	stderr bytes.Buffer
+}
+
+func newCommandWriter(w io.Writer, name string, args ...string) (io.WriteCloser, error) {
+	cw := &commandWriter{cmd: exec.Command(name, args...)}
+	cw.cmd.Stdout = w
+	cw.cmd.Stderr = &cw.stderr
+	in, err := cw.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cw.cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("the %s command, needed to compress the file, is not installed", name)
+		}
+		return nil, err
+	}
+	cw.in = in
+	return cw, nil
+}
+
+func (w *commandWriter) Write(p []byte) (int, error) {
+	return w.in.Write(p)
+}
+
+func (w *commandWriter) Close() error {
+	w.in.Close()
+	if err := w.cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(w.stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", w.cmd.Args[0], err, msg)
+		}
+		return fmt.Errorf("%s: %w", w.cmd.Args[0], err)
+	}
+	return nil
+}
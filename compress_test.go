@@ -0,0 +1,40 @@
+package editor
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectCompression(t *testing.T) {
+	for _, tc := range []struct {
+		name, head string
+		want       string
+	}{
+		{"a.txt", "\x1f\x8b\x08\x00", "gzip"},
+		{"a.txt", "BZh91AY&SY\x00\x00", "bzip2"},
+		{"a.txt", "BZh9\x17\x72\x45\x38\x50\x90\x00\x00\x00\x00", "bzip2"},
+		{"a.txt", "BZh is how the notes start\n", ""},
+		{"a.bz2", "BZh0 and more text\n", ""},
+		{"a.bz2", "", "bzip2"},
+		{"a.txt", "\x28\xb5\x2f\xfd\x00", "zstd"},
+		{"a.txt", "plain text\n", ""},
+	} {
+		c := detectCompression(tc.name, bufio.NewReader(strings.NewReader(tc.head)))
+		got := ""
+		if c != nil {
+			got = c.name
+		}
+		if got != tc.want {
+			t.Errorf("%s starting %q is compressed with %q, want %q", tc.name, tc.head, got, tc.want)
+		}
+	}
+}
+
+func TestCommandWriterMissing(t *testing.T) {
+	_, err := newCommandWriter(io.Discard, "no-such-compressor")
+	if err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("error is %v, want one saying the command is not installed", err)
+	}
+}
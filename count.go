@@ -0,0 +1,59 @@
+package editor
+
+/*-----------------------------------------------------------------------------
+ * Count prefix
+ *
+ * The "universal_argument" action, ctrl+u by default, reads a count typed as
+ * digits and then handles the next key that many times, so ctrl+u 12 ctrl+k
+ * kills to the end of the line twelve times. Without digits the count is 4,
+ * and every further ctrl+u multiplies it by 4, as in Emacs.
+ */
+
+const maxCount = 10000 // a key is handled at most this many times
+
+func (e *Editor) universalArgument() {
+	n, typed := 4, false
+	for {
+		e.setStatusMsg("Count: %d", n)
+		e.refreshScreen()
+		k, err := e.readKey()
+		if err != nil {
+			e.stopAfterAction(false, err)
+			return
+		}
+		switch {
+		case k >= '0' && k <= '9':
+			if !typed {
+				n, typed = 0, true
+			}
+			n = n*10 + k - '0'
+		case e.keyBindings[k] == "universal_argument" && !typed:
+			n *= 4
+		case k == '\x1b':
+			e.setStatusMsg("Cancelled")
+			return
+		default:
+			e.setStatusMsg("")
+			e.stopAfterAction(e.handleTimes(k, n))
+			return
+		}
+		if n > maxCount {
+			n = maxCount
+		}
+	}
+}
+
+/* handleTimes handles the key k n times, and returns whether it quit the editor or why keys could not be read. */
+func (e *Editor) handleTimes(k, n int) (bool, error) {
+	keys := append([]int{}, e.keysRead...) // repeat plays the whole sequence
+	defer func() { e.keysRead = keys }()
+	for i := 0; i < n; i++ {
+		e.replayKeys = append([]int{k}, e.replayKeys...)
+		quit, err := e.processKey(e.isReadonly())
+		keys = append(keys, e.keysRead...)
+		if quit || err != nil {
+			return quit, err
+		}
+	}
+	return false, nil
+}
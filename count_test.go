@@ -0,0 +1,26 @@
+package editor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCountQuits(t *testing.T) {
+	h := NewHeadless(8, 40)
+	h.Type("\x153\x11") // ctrl+u 3 ctrl+q
+	h.Type("x")
+	e := New(WithTerminal(h))
+	e.isolated = true
+	if err := e.Run(context.Background(), []byte("text\n")); err != nil {
+		t.Fatalf("the editor did not quit: %v", err)
+	}
+	if got, want := e.linesToString(), "text\n"; got != want {
+		t.Errorf("text is %q, want %q", got, want)
+	}
+}
+
+func TestCountRepeats(t *testing.T) {
+	if text := runKeys(t, "text\n", `{}`, "\x15", "3", "x"); text != "xxxtext\n" {
+		t.Errorf("text is %q", text)
+	}
+}
@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*-----------------------------------------------------------------------------
+ * Describe bindings
+ *
+ * The "describe_bindings" action lists what every key does, the bindings of
+ * keymap.json, init.lua and plugins layered over the defaults followed by the
+ * keys built into the editor, in a read-only buffer that can be searched.
+ */
+
+const bindingsBufferName = "*bindings*"
+
+/* builtinKeys are the keys handled by processKey when no binding takes them. */
+var builtinKeys = []struct {
+	keys []int
+	what string
+}{
+	{[]int{'\r'}, "insert a new line"},
+	{[]int{ctrlKey('q')}, "quit"},
+	{[]int{ctrlKey('s')}, "save"},
+	{[]int{ctrlKey('f')}, "find"},
+	{[]int{kArrowUp, kArrowDown, kArrowLeft, kArrowRight}, "move the cursor"},
+	{[]int{kPageUp, kPageDown}, "move a screen up or down"},
+	{[]int{ctrlKey('a'), kHome}, "go to the indentation of the line, or its start"},
+	{[]int{ctrlKey('e'), kEnd}, "go to the end of the line"},
+	{[]int{kBackSpace}, "delete the character before the cursor, or the selection"},
+	{[]int{kDelete, ctrlKey('h')}, "delete the character under the cursor, or the selection"},
+	{[]int{ctrlKey('k')}, "delete to the end of the line"},
+	{[]int{ctrlKey('l')}, "redraw the screen"},
+	{[]int{'\x1b'}, "clear the selection"},
+	{[]int{'\t'}, "insert a tab, or spaces where tabs are not allowed"},
+}
+
+/* keyLabel returns the name of k, also for keys keymap.json has no name for. */
+func keyLabel(k int) string {
+	if name := keyName(k); name != "" {
+		return name
+	}
+	if k < ' ' {
+		return "ctrl+" + string(rune(k+'@'))
+	}
+	return fmt.Sprintf("key %#x", k)
+}
+
+func (e *Editor) describeBindings() {
+	lines := []string{"Key bindings", ""}
+	keys := make([]int, 0, len(e.keyBindings))
+	for k := range e.keyBindings {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keyLabel(keys[i]) < keyLabel(keys[j]) })
+	for _, k := range keys {
+		action := e.keyBindings[k]
+		if _, ok := e.actionDispatch[action]; !ok {
+			action += " (unknown action)"
+		}
+		lines = append(lines, fmt.Sprintf("%-16s %s", keyLabel(k), action))
+	}
+
+	lines = append(lines, "", "Built in", "")
+	for _, b := range builtinKeys {
+		for _, k := range b.keys {
+			what := b.what
+			if action, ok := e.keyBindings[k]; ok {
+				what += " (bound to " + action + ")"
+			}
+			lines = append(lines, fmt.Sprintf("%-16s %s", keyLabel(k), what))
+		}
+	}
+
+	b := e.findBuffer(bindingsBufferName)
+	if b == nil {
+		b = e.addBuffer()
+		b.fileName = bindingsBufferName
+		b.readonly = true
+	}
+	e.buf = b
+	b.lines = []line{}
+	for _, l := range lines {
+		e.insertRow(len(b.lines), l)
+	}
+	b.dirty = false
+	e.setCursor(point{})
+	b.fileY, b.fileX = 0, 0
+}
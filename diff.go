@@ -0,0 +1,220 @@
+package editor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Diff
+ *
+ * Line diffs with Myers' algorithm, printed as unified diffs. The
+ * "diff_unsaved" action shows the changes in the buffer that saving would
+ * write, compared with the file on disk.
+ */
+
+/* diffOp keeps ' ', removes '-' or adds '+' a line; a and b are the positions in the old and new lines before it. */
+type diffOp struct {
+	kind byte
+	a, b int
+}
+
+/* maxDiffEdits bounds the work of diffLines, which treats more different lines as replaced wholesale. */
+const maxDiffEdits = 2000
+
+/* diffLines returns the shortest edit script turning a into b. */
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > 0 && max > maxDiffEdits {
+		max = maxDiffEdits
+	}
+	off := max + 1
+	v := make([]int, 2*max+3)
+	trace := [][]int{} // trace[d] holds v[-d-1 .. d+1] as it was before step d
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int{}, v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+
+	/* too different, replace everything */
+	ops := []diffOp{}
+	for i := range a {
+		ops = append(ops, diffOp{'-', i, 0})
+	}
+	for j := range b {
+		ops = append(ops, diffOp{'+', n, j})
+	}
+	return ops
+}
+
+func backtrack(trace [][]int, n, m int) []diffOp {
+	ops := []diffOp{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		}
+		prevX := v(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', x - 1, y - 1})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', prevX, prevY})
+			} else {
+				ops = append(ops, diffOp{'-', prevX, prevY})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+/* unifiedDiff returns the differences between a and b as a unified diff with ctx lines of context, or nil if there are none. */
+func unifiedDiff(aName, bName string, a, b []string, ctx int) []string {
+	ops := diffLines(a, b)
+	out := []string{}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		/* the hunk runs until more than 2*ctx unchanged lines follow a change */
+		end := i
+		for j := i; j < len(ops) && j-end <= 2*ctx; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+		start := i - ctx
+		if start < 0 {
+			start = 0
+		}
+		stop := end + ctx + 1
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		aLen, bLen := 0, 0
+		body := []string{}
+		for _, op := range ops[start:stop] {
+			switch op.kind {
+			case ' ':
+				aLen++
+				bLen++
+				body = append(body, " "+a[op.a])
+			case '-':
+				aLen++
+				body = append(body, "-"+a[op.a])
+			case '+':
+				bLen++
+				body = append(body, "+"+b[op.b])
+			}
+		}
+		aStart, bStart := ops[start].a+1, ops[start].b+1
+		if aLen == 0 {
+			aStart--
+		}
+		if bLen == 0 {
+			bStart--
+		}
+
+		if len(out) == 0 {
+			out = append(out, "--- "+aName, "+++ "+bName)
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aLen, bStart, bLen))
+		out = append(out, body...)
+		i = stop
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+/* readLines reads the lines of the file name, decompressed, and no lines if it does not exist. */
+func (e *Editor) readLines(name string) ([]string, error) {
+	r, _, f, err := e.openDecompressed(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+/* textLines returns the lines of b, hidden ones included. */
+func (b *buffer) textLines() []string {
+	all := b.allLines()
+	lines := make([]string, len(all))
+	for i, l := range all {
+		lines[i] = string(l.chars)
+	}
+	return lines
+}
+
+/* diffUnsaved shows what saving the current buffer would change in its file. */
+func (e *Editor) diffUnsaved() {
+	name := e.buf.fileName
+	if name == "" || isURL(name) {
+		e.setStatusMsg("buffer has no file to compare with")
+		e.bell()
+		return
+	}
+
+	saved, err := e.readLines(name)
+	if err != nil {
+		e.setStatusMsg("diff: %s", err)
+		return
+	}
+	diff := unifiedDiff(name+" (saved)", name+" (buffer)", saved, e.buf.textLines(), 3)
+	if diff == nil {
+		e.setStatusMsg("No unsaved changes")
+		return
+	}
+	for i, l := range diff {
+		diff[i] = strings.ReplaceAll(l, "\t", strings.Repeat(" ", e.tabStop))
+	}
+	e.ShowPopup("Unsaved changes", diff)
+}
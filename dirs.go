@@ -0,0 +1,86 @@
+package editor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*-----------------------------------------------------------------------------
+ * Directories
+ *
+ * Following the XDG base directory specification, the configuration, such as
+ * keymap.json, init.lua, themes and plugins, is read from
+ * $XDG_CONFIG_HOME/editor, by default ~/.config/editor, and data the editor
+ * writes, such as the local history, is kept in $XDG_DATA_HOME/editor, by
+ * default ~/.local/share/editor. The ~/.editor directory of earlier versions
+ * is used for both as long as it exists and the new directory does not.
+ */
+
+/* legacyDir returns ~/.editor if it exists. */
+func legacyDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	dir := filepath.Join(home, ".editor")
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return ""
+	}
+	return dir
+}
+
+/* xdgDir returns the editor's directory under $env, or under fallback in the home directory when env is not set to an absolute path. */
+func xdgDir(env, fallback string) string {
+	base := os.Getenv(env)
+	if !filepath.IsAbs(base) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return legacyDir()
+		}
+		base = filepath.Join(home, fallback)
+	}
+	dir := filepath.Join(base, "editor")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if legacy := legacyDir(); legacy != "" {
+			return legacy
+		}
+	}
+	return dir
+}
+
+/* configDir returns the directory holding the editor configuration, or "" if it is unknown. */
+func configDir() string {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+/* dataDir returns the directory for the data the editor keeps, or "" if it is unknown. */
+func dataDir() string {
+	return xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// LoadTheme returns the theme called name, one of Themes or else the theme
+// in themes/name.json in the configuration directory. The JSON object holds
+// the fields of Theme; missing fields are taken from DefaultTheme.
+func LoadTheme(name string) (Theme, error) {
+	if t, ok := Themes[name]; ok {
+		return t, nil
+	}
+	dir := configDir()
+	if dir == "" || name != filepath.Base(name) {
+		return Theme{}, fmt.Errorf("unknown theme %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "themes", name+".json"))
+	if os.IsNotExist(err) {
+		return Theme{}, fmt.Errorf("unknown theme %q", name)
+	}
+	if err != nil {
+		return Theme{}, err
+	}
+	t := DefaultTheme
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", name, err)
+	}
+	return t, nil
+}
@@ -7,17 +7,20 @@ package editor
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
-	"golang.org/x/sys/unix"
+	lua "github.com/yuin/gopher-lua"
 )
 
 /*-----------------------------------------------------------------------------
@@ -27,6 +30,7 @@ import (
 type line struct {
 	chars  []rune // a line of text
 	render []rune // contain the actual characters to draw on the screen for the line of text
+	tabs   []int  // columns each tab is drawn as with elastic tabstops, nil for fixed tabs
 }
 
 type point struct {
@@ -34,47 +38,151 @@ type point struct {
 	y int // y position
 }
 
-type config struct {
-	orgTermios       unix.Termios   // termios structure
-	termRows         int            // number of terminal rows
-	termCols         int            // number of terminal columns
-	cursor           point          // cursors x & y position
-	rx               int            // the x position (index) into line.render
-	lines            []line         // lines of text
-	fileY            int            // current line in text the user is scrolled to
-	fileX            int            // current colum in the text the user is scrolled to
-	tabStop          int            // number of spaces in a tab
-	fileName         string         // name of edited file
-	statusMsg        string         // status message
-	statusMsgTime    time.Time      // timestamp of the status message
-	statusMsgTimeout float64        // Timeout for the status message
-	dirty            bool           // dirty flag, true if the file has been edited
-	quitComfirm      bool           // confirm quit if the file is dirty
-	searchPoints     []point        // x and y positions of search results
-	searchCursor     point          // the cursor point when a search is started
-	signals          chan os.Signal // channel for resize signals
+type buffer struct {
+	lines        []line       // lines of text
+	cursor       point        // cursors x & y position
+	fileY        int          // current line in text the user is scrolled to
+	fileX        int          // current colum in the text the user is scrolled to
+	fileName     string       // name of edited file
+	dirty        bool         // dirty flag, true if the file has been edited
+	sink         io.Writer    // if set, saves are written here instead of to the file
+	data         bool         // read from data or a reader, so "memory" is not a file of its own
+	protected    []lineRange  // read-only lines
+	compression  *compression // how the file is compressed, nil if it is not
+	modTime      time.Time    // modification time of the file when last read or written
+	preview      *mdPreview   // shown instead of the text, nil if it is not
+	historyHash  string       // hash of the text in the last local history snapshot
+	historySaved time.Time    // when the last local history snapshot was taken
+	readonly     bool         // the buffer can not be edited, whether the editor can or not
+	scratch      bool         // not backed by a file until the user saves it
+	narrow       *narrowing   // lines hidden by narrowing, nil if all are shown
+	mark         *point       // the other end of the selection from the cursor, nil if nothing is selected
+	timeline     []editFrame  // changes making the versions of the text, oldest first
+	timelineText string       // the text of the last version in the timeline
+	checkpoints  []checkpoint // named copies of the text, in the order they were set
+	bookmarks    []int        // bookmarked lines, ascending and counting the lines hidden by narrowing
+	goal         *goalColumn  // column vertical moves keep to, nil before the first
+}
+
+// Editor is an editor instance. Instances share no state, so several can run
+// at the same time.
+type Editor struct {
+	term             Terminal                   // the terminal the editor runs in
+	termRows         int                        // number of terminal rows
+	termCols         int                        // number of terminal columns
+	rx               int                        // the x position (index) into line.render
+	buf              *buffer                    // the current buffer
+	buffers          []*buffer                  // open buffers
+	tabStop          int                        // number of spaces in a tab
+	statusMsg        string                     // status message
+	statusMsgTime    time.Time                  // timestamp of the status message
+	statusMsgTimeout float64                    // Timeout for the status message
+	quitComfirm      bool                       // confirm quit if the file is dirty
+	searchPoints     []point                    // x and y positions of search results
+	searchCursor     point                      // the cursor point when a search is started
+	searchMatch      int                        // the search result the cursor is on, one based, 0 when not searching
+	readonly         bool                       // true if the buffers can not be edited
+	changes          int                        // incremented every time the text is modified
+	tasks            chan func()                // work queued by other goroutines to run on the main loop
+	actionDispatch   map[string]func()          // named actions that can be bound to keys
+	keyBindings      map[int]string             // key to action name
+	plugins          []*plugin                  // connected plugins
+	pluginCommands   map[string]*plugin         // plugin command name to the plugin providing it
+	lua              *lua.LState                // state of the init script
+	hooks            map[Hook][]func(HookEvent) // callbacks attached to hooks
+	collab           *collabSession             // collaborative editing session
+	controlListener  net.Listener               // remote control socket
+	controlStop      chan struct{}              // closed when the control socket is stopped
+	pluginListener   net.Listener               // unix socket plugins connect to
+	isolated         bool                       // do not load plugins, the init script or the control socket
+	keymapFile       string                     // key bindings to load instead of the default keymap.json
+	theme            Theme                      // colours the screen is drawn with
+	ctx              context.Context            // the editor exits when it is done
+	saveWriter       io.Writer                  // where the source buffer is saved instead of a file
+	saveFunc         SaveFunc                   // saves buffers instead of writing files
+	events           chan<- HookEvent           // receives an event every time a hook is run
+	overlays         []*overlay                 // boxes drawn over the text, the last one on top
+	statusProviders  []func(*Editor) string     // extra text for the status bar
+	statusUpdaters   []statusUpdater            // goroutines keeping status segments up to date
+	statusCancel     context.CancelFunc         // stops the status updaters, nil when they do not run
+	redrawQueued     int32                      // a redraw asked for by Invalidate is queued, accessed atomically
+	bellMode         BellMode                   // how actions that can not be done are signalled
+	sudoCommand      []string                   // command saving files the user may not write
+	sftpConns        map[string]*sftpConn       // connections for remote files by user@host:port
+	autosave         bool                       // save modified buffers when the user may walk away
+	title            string                     // title last set on the terminal, "" if it has not been set
+	titleOff         bool                       // leave the title of the terminal alone
+	escTimeout       time.Duration              // how long to wait for the rest of an escape sequence
+	swatchesOff      bool                       // do not draw colour swatches after lines with colours
+	auditLog         string                     // file recording the files opened and saved, "" for none
+	secure           bool                       // write nothing but the edited files and wipe the text on exit
+	quickfix         []quickfixEntry            // matches of the last grep
+	quickfixPos      int                        // entry of the quickfix list last gone to, -1 for none
+	grepGen          int                        // incremented for every grep, to drop results of earlier ones
+	grepCancel       context.CancelFunc         // stops the running grep, nil if none is running
+	renderStats      *renderStats               // timings of the last frame, nil unless they are shown
+	pprofListener    net.Listener               // serves the pprof endpoints
+	debugKeys        bool                       // show the bytes read for every key
+	keyBytes         []byte                     // bytes read for the key being read, when debugKeys is on
+	files            []string                   // opened as further buffers after the source
+	startLine        int                        // line to put the cursor on at start, one based
+	replaceUndo      []replaceUndo              // how to undo the last replace or replace_all_buffers
+	elastic          bool                       // draw tabs as elastic tabstops
+	templatesOff     bool                       // do not fill new files from templates
+	lowBandwidth     bool                       // only write the rows that changed
+	lastRows         []string                   // rows written by the last refresh, in low bandwidth mode
+	lastTop          string                     // overlays written by the last refresh, in low bandwidth mode
+	promptHistory    map[string][]string        // earlier input of the prompts by kind, nil until it is loaded
+	colorColumns     []int                      // columns drawn as rulers, one based and ascending
+	macro            []int                      // keys of the macro being recorded, nil when not recording
+	lastMacro        []int                      // keys of the last recorded macro
+	macros           map[string][]int           // saved macros by name
+	replayKeys       []int                      // keys of the macro being played, read before the terminal
+	replaying        bool                       // a macro is being played
+	actionQuit       bool                       // an action handled the quit key, for the main loop to quit
+	actionErr        error                      // keys could not be read within an action, for the main loop to return
+	keysRead         []int                      // keys read while handling the current key press
+	unread           []int                      // keys read ahead and put back, read before the terminal
+	lastEdit         []int                      // keys of the last edit, played by repeat
+	editRun          bool                       // the last edit is a run of typing that may go on
+	dateFormats      []string                   // strftime formats insert_date offers
+	problem          *point                     // character at fault highlighted until the next key, nil for none
+	textWidth        int                        // column lines are filled, wrapped and aligned to
+	autoWrap         wrapMode                   // which files lines are wrapped in as they are typed
+	keyboardLayout   string                     // layout "pos:" key names are translated to
+	imagePreview     string                     // protocol images are drawn with: auto, kitty, sixel or off
+	hyperlinksOn     bool                       // links are drawn as OSC 8 hyperlinks
+	idleTasks        []*idleTask                // work done when the typing pauses
+	inputGen         int                        // incremented for every key pressed
+	lastInput        time.Time                  // when the last key was pressed
+	occurrence       *occurrence                // word under the cursor whose occurrences are highlighted
+	occurrencesOff   bool                       // do not highlight the occurrences of the word under the cursor
+	gitStatus        *gitStatus                 // git status in the status bar, nil if it was never shown
+	overwrite        bool                       // typing overwrites the character under the cursor
 }
 
 /*-----------------------------------------------------------------------------
  * Global variables & constants
  */
 
-var editor config
-var errNoInput = errors.New("no input")
+// Version is the version of the editor.
+const Version = "1.0.0"
 
-const version = "1.0.0"
+const defaultEscTimeout = 50 * time.Millisecond
 
 const (
 	kBackSpace  = 127
-	kArrowUp    = 1000
-	kArrowDown  = 1001
-	kArrowLeft  = 1002
-	kArrowRight = 1003
-	kPageUp     = 1004
-	kPageDown   = 1005
-	kHome       = 1006
-	kEnd        = 1007
-	kDelete     = 1008
+	kArrowUp    = 0x110000 // special keys are above the last Unicode code point
+	kArrowDown  = 0x110001
+	kArrowLeft  = 0x110002
+	kArrowRight = 0x110003
+	kPageUp     = 0x110004
+	kPageDown   = 0x110005
+	kHome       = 0x110006
+	kEnd        = 0x110007
+	kDelete     = 0x110008
+	kInsert     = 0x110009
+	kAlt        = 0x200000 // added to a key typed with alt held down
 )
 
 /*-----------------------------------------------------------------------------
@@ -85,69 +193,91 @@ func ctrlKey(b byte) int {
 	return int(b & 0x1f)
 }
 
-func windowSize() (int, int, error) {
-	ws, err := unix.IoctlGetWinsize(unix.Stdout, unix.TIOCGWINSZ)
-	if err != nil {
-		return 0, 0, err
-	}
-	return int(ws.Row), int(ws.Col), nil
-}
-
-func clearTerminal() {
+func (e *Editor) clearTerminal() {
 	scrBuf := bytes.Buffer{} // screen buffer
+	e.invalidateScreen()
 
 	fmt.Fprint(&scrBuf, "\x1b[?25l") // hide cursor
 	fmt.Fprint(&scrBuf, "\x1b[H")    // cursor top-left corner
 
-	for y := 0; y <= editor.termRows+1; y++ {
+	for y := 0; y <= e.termRows+1; y++ {
 		fmt.Fprintf(&scrBuf, "\x1b[K") // clear to end of line
 		fmt.Fprint(&scrBuf, "\r\n")
 	}
 	fmt.Fprint(&scrBuf, "\x1b[H")    // cursor top-left corner
 	fmt.Fprint(&scrBuf, "\x1b[?25h") // show cursor
 
-	os.Stdout.Write(scrBuf.Bytes()) // write screen buffer to stdout
+	e.term.Write(scrBuf.Bytes()) // write screen buffer to the terminal
 }
 
-func cleanupBeforeExit() {
-	clearTerminal()
-	err := disableRawMode()
+func (e *Editor) cleanupBeforeExit() {
+	e.setFocusReporting(false)
+	e.restoreTitle()
+	e.stopGrep()
+	e.stopStatusUpdaters()
+	e.stopPprof()
+	e.collabLeave()
+	e.stopControlSocket()
+	e.stopScripting()
+	e.stopPlugins()
+	e.closeSFTP()
+	if e.secure {
+		e.wipeBuffers()
+	}
+	e.clearTerminal()
+	err := e.term.RawMode(false)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error disable raw mode %s", err)
 		os.Exit(1)
 	}
-	editor.signals <- syscall.SIGABRT
 }
 
-func resizeWindow() {
-	rows, cols, err := windowSize()
+func (e *Editor) resizeWindow() {
+	rows, cols, err := e.term.Size()
 	if err != nil {
 		panic(err)
 	}
 
-	editor.termRows = rows - 2
-	editor.termCols = cols
+	e.termRows = rows - 2
+	e.termCols = cols
+	e.invalidateScreen()
+}
+
+/* checkResize picks up a change of the terminal size and reports whether there was one. */
+func (e *Editor) checkResize() bool {
+	rows, cols, err := e.term.Size()
+	if err != nil || (rows-2 == e.termRows && cols == e.termCols) {
+		return false
+	}
+
+	e.resizeWindow()
+	e.runHook(Resize)
+	return true
 }
 
 /*-----------------------------------------------------------------------------
  * Draw operations
  */
 
-func drawRows(scrBuf *bytes.Buffer) {
+func (e *Editor) drawRows(scrBuf *bytes.Buffer) {
+	marks := e.colorColumnMarks(e.linkMarks(e.occurrenceMarks(e.selectionMarks(e.problemMarks(e.collabMarks())))))
+	links := e.hyperlinks()
 
-	for y := 0; y < editor.termRows; y++ {
-		fileLine := y + editor.fileY
+	for y := 0; y < e.termRows; y++ {
+		fileLine := y + e.buf.fileY
+		used := 1 // columns drawn on the row
 
-		if fileLine >= len(editor.lines) {
-			if len(editor.lines) == 0 && y == editor.termRows/3 {
-				msg := fmt.Sprintf("Simple editor. Version %s", version)
+		if fileLine >= len(e.buf.lines) {
+			fmt.Fprint(scrBuf, sgr(e.theme.EmptyLine))
+			if len(e.buf.lines) == 0 && y == e.termRows/3 {
+				msg := fmt.Sprintf("Simple editor. Version %s", Version)
 				msglen := len(msg)
 
-				if msglen > editor.termCols {
-					msg = msg[:editor.termCols]
-					msglen = editor.termCols
+				if msglen > e.termCols {
+					msg = msg[:e.termCols]
+					msglen = e.termCols
 				}
-				padding := (editor.termCols - msglen) / 2
+				padding := (e.termCols - msglen) / 2
 
 				if padding > 0 {
 					fmt.Fprint(scrBuf, "~")
@@ -157,174 +287,344 @@ func drawRows(scrBuf *bytes.Buffer) {
 					fmt.Fprint(scrBuf, " ")
 				}
 				fmt.Fprint(scrBuf, msg)
+				used = (e.termCols-msglen)/2 + msglen
 			} else {
 				fmt.Fprintf(scrBuf, "~")
 			}
 		} else {
-			lineLen := len(editor.lines[fileLine].render) - editor.fileX
+			fmt.Fprint(scrBuf, sgr(e.theme.Text))
+			e.drawGutter(scrBuf, fileLine)
+			lineLen := len(e.buf.lines[fileLine].render) - e.buf.fileX
 			if lineLen < 0 {
 				lineLen = 0
 			}
 
-			if lineLen > editor.termCols { // truncate if lines go past the end of screen
-				lineLen = editor.termCols
+			if lineLen > e.textCols() { // truncate if lines go past the end of screen
+				lineLen = e.textCols()
 			}
 
-			if lineLen > 0 {
-				fmt.Fprint(scrBuf, string(editor.lines[fileLine].render[editor.fileX:editor.fileX+lineLen]))
+			if marks[fileLine] != nil || links[fileLine] != nil {
+				e.drawMarkedLine(scrBuf, e.buf.lines[fileLine].render, lineLen, marks[fileLine], links[fileLine])
+			} else if lineLen > 0 {
+				fmt.Fprint(scrBuf, string(e.buf.lines[fileLine].render[e.buf.fileX:e.buf.fileX+lineLen]))
+			}
+			used = lineLen
+			if render := e.buf.lines[fileLine].render; e.buf.fileX+lineLen == len(render) {
+				if _, ok := marks[fileLine][len(render)]; ok {
+					used++ // a collaborator's cursor or the selection past the end of the line
+				}
+				used = e.drawSwatches(scrBuf, fileLine, used)
 			}
 		}
 
 		fmt.Fprintf(scrBuf, "\x1b[K") // clear to end of line
+		e.drawColorColumns(scrBuf, used)
+		fmt.Fprint(scrBuf, "\x1b[m") // normal colour
 		fmt.Fprint(scrBuf, "\r\n")
 
 	}
 }
 
-func drawStatusBar(scrBuf *bytes.Buffer) {
+/* drawMarkedLine draws lineLen characters of render, coloring the render columns in marks and making links of the spans in links. */
+func (e *Editor) drawMarkedLine(scrBuf *bytes.Buffer, render []rune, lineLen int, marks map[int]string, links []hyperlink) {
+	var open *hyperlink // the link being drawn
+	defer func() {
+		if open != nil {
+			fmt.Fprint(scrBuf, "\x1b]8;;\x1b\\")
+		}
+	}()
+	for rx := e.buf.fileX; rx <= e.buf.fileX+lineLen && rx < e.buf.fileX+e.textCols(); rx++ {
+		r := ' '
+		if rx < len(render) {
+			r = render[rx]
+		} else if marks[rx] == "" {
+			break
+		}
+
+		if open != nil && rx >= open.end {
+			fmt.Fprint(scrBuf, "\x1b]8;;\x1b\\")
+			open = nil
+		}
+		for len(links) > 0 && links[0].end <= rx {
+			links = links[1:]
+		}
+		if open == nil && len(links) > 0 && links[0].start <= rx && rx < len(render) {
+			open = &links[0]
+			fmt.Fprintf(scrBuf, "\x1b]8;;%s\x1b\\", open.url)
+		}
+
+		if mark, ok := marks[rx]; ok {
+			fmt.Fprintf(scrBuf, "%s%c\x1b[m%s", mark, r, sgr(e.theme.Text))
+		} else {
+			fmt.Fprintf(scrBuf, "%c", r)
+		}
+	}
+}
+
+func (e *Editor) drawStatusBar(scrBuf *bytes.Buffer) {
 	var leftStatusString string
 
-	fileName := editor.fileName
+	fileName := e.buf.fileName
 	if fileName == "" {
 		fileName = "No Name"
+		if e.buf.scratch {
+			fileName = "Scratch"
+		}
 	}
 
-	if editor.dirty {
+	if e.buf.dirty {
 		dirtyChar := '*'
-		leftStatusString = fmt.Sprintf("[%c%.20s] - %d lines", dirtyChar, fileName, len(editor.lines))
+		leftStatusString = fmt.Sprintf("[%c%.20s] - %d lines", dirtyChar, fileName, len(e.buf.lines))
 	} else {
-		leftStatusString = fmt.Sprintf("[%.20s] - %d lines", fileName, len(editor.lines))
+		leftStatusString = fmt.Sprintf("[%.20s] - %d lines", fileName, len(e.buf.lines))
 	}
 
-	rightStatusString := fmt.Sprintf("L%d,C%d", editor.cursor.y+1, editor.cursor.x+1)
+	if e.isReadonly() {
+		leftStatusString += " - read-only"
+	} else if e.overwrite {
+		leftStatusString += " - overwrite"
+	}
+	if e.buf.preview != nil {
+		leftStatusString += " - preview"
+	}
+	if e.buf.narrow != nil {
+		leftStatusString += " - narrowed"
+	}
+	if len(e.buffers) > 1 {
+		leftStatusString += fmt.Sprintf(" - buffer %d/%d", e.bufferIndex(e.buf)+1, len(e.buffers))
+	}
+
+	rightStatusString := fmt.Sprintf("L%d,C%d", e.buf.cursor.y+e.buf.hiddenAbove()+1, e.buf.cursor.x+1)
+	if e.searchMatch > 0 {
+		rightStatusString = fmt.Sprintf("match %d/%d  %s", e.searchMatch, len(e.searchPoints), rightStatusString)
+	}
+
+	/* fold in the text of the status providers, as much of it as there is room for */
+	extra := []string{}
+	for _, fn := range e.statusProviders {
+		if s := fn(e); s != "" {
+			extra = append(extra, s)
+		}
+	}
+	if len(extra) > 0 {
+		room := []rune(strings.Join(extra, " | "))
+		if free := e.termCols - utf8.RuneCountInString(leftStatusString) - utf8.RuneCountInString(rightStatusString) - 3; free < len(room) {
+			if free < 0 {
+				free = 0
+			}
+			room = room[:free]
+		}
+		if len(room) > 0 {
+			rightStatusString = string(room) + "  " + rightStatusString
+		}
+	}
 
-	numSpaces := editor.termCols - len(leftStatusString) - len(rightStatusString)
+	numSpaces := e.termCols - utf8.RuneCountInString(leftStatusString) - utf8.RuneCountInString(rightStatusString)
 
-	fmt.Fprint(scrBuf, "\x1b[7m") // invert colour
+	fmt.Fprint(scrBuf, sgr(e.theme.StatusBar))
 
 	if numSpaces >= 0 {
 		fmt.Fprint(scrBuf, leftStatusString+strings.Repeat(" ", numSpaces)+rightStatusString)
 	} else {
-		fmt.Fprint(scrBuf, (leftStatusString + rightStatusString)[:editor.termCols])
+		fmt.Fprint(scrBuf, string([]rune(leftStatusString + rightStatusString)[:e.termCols]))
 	}
 
 	fmt.Fprint(scrBuf, "\x1b[m") // normal colour
 	fmt.Fprint(scrBuf, "\r\n")
 }
 
-func drawStatusMsg(scrBuf *bytes.Buffer) {
+func (e *Editor) drawStatusMsg(scrBuf *bytes.Buffer) {
+	fmt.Fprint(scrBuf, sgr(e.theme.StatusMsg))
 	fmt.Fprint(scrBuf, "\x1b[K") // clear the line
 
-	if time.Since(editor.statusMsgTime).Seconds() < editor.statusMsgTimeout {
-		if len(editor.statusMsg) < editor.termCols {
-			fmt.Fprint(scrBuf, editor.statusMsg)
+	if time.Since(e.statusMsgTime).Seconds() < e.statusMsgTimeout {
+		if len(e.statusMsg) < e.termCols {
+			fmt.Fprint(scrBuf, e.statusMsg)
 		} else {
-			fmt.Fprint(scrBuf, editor.statusMsg[:editor.termCols])
+			fmt.Fprint(scrBuf, e.statusMsg[:e.termCols])
 		}
 	}
+	fmt.Fprint(scrBuf, "\x1b[m") // normal colour
 }
 
-func setStatusMsg(format string, a ...interface{}) {
-	editor.statusMsg = fmt.Sprintf(format, a...)
-	editor.statusMsgTime = time.Now()
+func (e *Editor) setStatusMsg(format string, a ...interface{}) {
+	e.statusMsg = fmt.Sprintf(format, a...)
+	e.statusMsgTime = time.Now()
 }
 
 /*-----------------------------------------------------------------------------
  * Prompt
  */
 
-func prompt(prompt string) string {
-	var input []byte
+func (e *Editor) prompt(prompt string) string {
+	input, err := e.readPrompt(prompt, nil, nil, nil)
+	if err != nil && err != errPromptCancelled && e.ctx.Err() == nil {
+		return fmt.Sprintf("%v", err)
+	}
+	return input
+}
+
+var errPromptCancelled = errors.New("prompt cancelled")
+
+/*
+readPrompt reads a line of input on the status line. Up and down step
+through history, oldest first. When validate is given the input is only
+accepted once it returns nil, and when complete is given tab cycles through
+the completions it returns for the input.
+*/
+func (e *Editor) readPrompt(prompt string, history []string, validate func(string) error, complete func(string) []string) (string, error) {
+	var input []rune
+	var hint string      // shown after the input
+	var matches []string // completions tab cycles through
+	mi := 0
+	hi := len(history) // the history entry shown, len(history) for the input being typed
+	var typed []rune   // the input being typed while history is shown
 
 	for {
-		setStatusMsg(prompt, input)
-		refreshScreen()
-		k, err := readKey()
+		e.setStatusMsg(prompt, string(input))
+		if hint != "" {
+			e.statusMsg += "  [" + hint + "]"
+		}
+		e.refreshScreen()
+		k, err := e.readKey()
 		if err != nil {
-			return fmt.Sprintf("%v", err)
+			return "", err
+		}
+
+		hint = ""
+		if k != '\t' {
+			matches = nil
 		}
 
 		if k == kDelete || k == ctrlKey('h') || k == kBackSpace {
 			if len(input) > 0 {
 				input = input[:len(input)-1]
 			}
+		} else if (k == kArrowUp && hi > 0) || (k == kArrowDown && hi < len(history)) {
+			if hi == len(history) {
+				typed = input
+			}
+			if k == kArrowUp {
+				hi--
+			} else {
+				hi++
+			}
+			if hi == len(history) {
+				input = typed
+			} else {
+				input = []rune(history[hi])
+			}
 		} else if k == '\x1b' {
-			setStatusMsg("")
-			return ""
+			e.setStatusMsg("")
+			return "", errPromptCancelled
 		} else if k == '\r' {
-			setStatusMsg("")
+			if validate != nil {
+				if err := validate(string(input)); err != nil {
+					hint = err.Error()
+					continue
+				}
+			}
+			e.setStatusMsg("")
 			break
+		} else if k == '\t' && complete != nil {
+			if matches == nil {
+				matches = complete(string(input))
+				mi = 0
+			} else {
+				mi = (mi + 1) % len(matches)
+			}
+			if len(matches) == 0 {
+				matches = nil
+				hint = "no completions"
+				continue
+			}
+			input = []rune(matches[mi])
+			if len(matches) > 1 {
+				hint = fmt.Sprintf("%d/%d", mi+1, len(matches))
+			}
 		} else if unicode.IsPrint(rune(k)) {
-			input = append(input, byte(k))
+			input = append(input, rune(k))
 		}
 	}
 
-	return string(input)
+	return string(input), nil
 }
 
 /*-----------------------------------------------------------------------------
  * Find
  */
 
-func find() {
-
-	query := prompt("Search: %s")
+func (e *Editor) find() {
 
-	if query == "" {
+	query, ok := e.historyPrompt(searchHistory, "Search: ", func(s string) error {
+		_, err := searchPattern(s)
+		return err
+	}, nil)
+	if !ok || query == "" {
 		return
 	}
 
-	editor.searchPoints = []point{}
-
-	for row, line := range editor.lines {
-		points := searchPoints(row+1, string(line.chars), query)
-
-		if len(points) != 0 {
-			editor.searchPoints = append(editor.searchPoints, points...)
+	from, to, within := e.useSelection("Search")
+	points, ok := e.searchBuffer(query)
+	if !ok {
+		e.setStatusMsg("Search cancelled")
+		return
+	}
+	if within {
+		kept := points[:0]
+		for _, p := range points {
+			if inSelection(p, from, to) {
+				kept = append(kept, p)
+			}
 		}
+		points = kept
 	}
+	e.searchPoints = points
 
-	if len(editor.searchPoints) == 0 {
-		setStatusMsg("No match found.")
+	if len(e.searchPoints) == 0 {
+		e.setStatusMsg("No match found.")
+		e.bell()
 		return
 	}
 
 	/* Save the current position in the file. */
-	editor.searchCursor.x = editor.cursor.x
-	editor.searchCursor.y = editor.cursor.y
+	e.searchCursor.x = e.buf.cursor.x
+	e.searchCursor.y = e.buf.cursor.y
 
-	setCursor(editor.searchPoints[0])
-	setStatusMsg("Use arrow keys to move, ESC or ENTER to exit.")
+	e.setCursor(e.searchPoints[0])
+	e.setStatusMsg("Use arrow keys to move, ESC or ENTER to exit.")
 
 	point := 0
+	defer func() { e.searchMatch = 0 }()
 findLoop:
 	for {
-		refreshScreen()
-		k, err := readKey()
+		e.searchMatch = point + 1
+		e.refreshScreen()
+		k, err := e.readKey()
 		if err != nil {
 			break findLoop
 		}
 		switch k {
 		case kArrowDown, kArrowRight:
 			point++
-			if point > len(editor.searchPoints)-1 {
+			if point > len(e.searchPoints)-1 {
 				point = 0
 			}
-			setCursor(editor.searchPoints[point])
+			e.setCursor(e.searchPoints[point])
 		case kArrowUp, kArrowLeft:
 			point--
 			if point < 0 {
-				point = len(editor.searchPoints) - 1
+				point = len(e.searchPoints) - 1
 			}
-			setCursor(editor.searchPoints[point])
+			e.setCursor(e.searchPoints[point])
 
 		case '\x1b':
-			setStatusMsg("Esc")
-			setCursor(editor.searchCursor)
+			e.setStatusMsg("Esc")
+			e.setCursor(e.searchCursor)
 			break findLoop
 
 		case '\r':
-			setStatusMsg("")
+			e.setStatusMsg("")
 			break findLoop
 		}
 	}
@@ -345,7 +645,8 @@ func searchPoints(row int, str string, substr string) []point {
 		}
 
 		s = s[i+len(substr):]
-		points = append(points, point{y: row - 1, x: len(str) - len(s) - len(substr)})
+		at := len(str) - len(s) - len(substr)
+		points = append(points, point{y: row - 1, x: utf8.RuneCountInString(str[:at])})
 	}
 	return points
 }
@@ -362,11 +663,11 @@ func searchPoints(row int, str string, substr string) []point {
 //	os.Stdout.Write(scrBuf.Bytes())
 //}
 
-func computeRx(row []rune, x int) int {
+func (e *Editor) computeRx(row []rune, x int) int {
 	rx := 0
 	for i := 0; i < x; i++ {
 		if row[i] == '\t' {
-			rx = rx + editor.tabStop - 1
+			rx = rx + e.tabStop - 1
 		}
 		rx++
 	}
@@ -374,59 +675,114 @@ func computeRx(row []rune, x int) int {
 	return rx
 }
 
-func scroll() {
+func (e *Editor) scroll() {
 
-	editor.rx = 0
+	e.rx = 0
 
-	if editor.cursor.y < len(editor.lines) {
-		editor.rx = computeRx(editor.lines[editor.cursor.y].chars, editor.cursor.x)
+	if e.elastic {
+		e.layoutElastic(e.buf, e.buf.cursor.y, e.buf.cursor.y+1)
+	}
+	if e.buf.cursor.y < len(e.buf.lines) {
+		e.rx = e.lineRx(e.buf.lines[e.buf.cursor.y], e.buf.cursor.x)
 	}
 
 	/* check if the cursor is above the visible window */
-	if editor.cursor.y < editor.fileY {
-		editor.fileY = editor.cursor.y
+	if e.buf.cursor.y < e.buf.fileY {
+		e.buf.fileY = e.buf.cursor.y
 	}
 
 	/* check if the cursor is past the bottom of the visible window */
-	if editor.cursor.y >= editor.fileY+editor.termRows {
-		editor.fileY = editor.cursor.y - editor.termRows + 1
+	if e.buf.cursor.y >= e.buf.fileY+e.termRows {
+		e.buf.fileY = e.buf.cursor.y - e.termRows + 1
 	}
 
 	/* check if the cursor is to the left of the visible window */
-	if editor.rx < editor.fileX {
-		editor.fileX = editor.rx
+	if e.rx < e.buf.fileX {
+		e.buf.fileX = e.rx
 	}
 
 	/* check if the cursor is to the right of the visible window */
-	if editor.rx >= editor.fileX+editor.termCols {
-		editor.fileX = editor.rx - editor.termCols + 1
+	if e.rx >= e.buf.fileX+e.textCols() {
+		e.buf.fileX = e.rx - e.textCols() + 1
+	}
+
+	if e.elastic {
+		e.layoutElastic(e.buf, e.buf.fileY, e.buf.fileY+e.termRows)
 	}
 }
 
-func refreshScreen() {
+func (e *Editor) refreshScreen() {
 	scrBuf := bytes.Buffer{} // screen buffer
 
-	scroll()
+	st := e.renderStats
+	var start time.Time
+	var allocs uint64
+	if st != nil {
+		allocs = mallocs()
+		start = time.Now()
+	}
 
-	fmt.Fprint(&scrBuf, "\x1b[?25l") // hide cursor
-	fmt.Fprint(&scrBuf, "\x1b[H")    // cursor top-left corner
+	e.scroll()
+	if st != nil {
+		st.scroll = time.Since(start)
+	}
 
-	drawRows(&scrBuf)
-	drawStatusBar(&scrBuf)
-	drawStatusMsg(&scrBuf)
+	rows := bytes.Buffer{} // the rows of the screen
+	if e.buf.preview != nil {
+		e.drawPreview(&rows)
+	} else {
+		e.drawRows(&rows)
+	}
+	e.drawStatusBar(&rows)
+	e.drawStatusMsg(&rows)
 
-	// reposition cursor
-	fmt.Fprintf(&scrBuf, "\x1b[%d;%dH",
-		editor.cursor.y-editor.fileY+1,
-		editor.rx-editor.fileX+1)
+	top := bytes.Buffer{} // drawn over the rows
+	if st != nil {
+		e.drawRenderStats(&top)
+	}
+	placed := e.drawOverlays(&top)
 
-	fmt.Fprint(&scrBuf, "\x1b[?25h") // show cursor
+	frame := bytes.Buffer{}
+	if e.lowBandwidth {
+		e.drawChanged(&frame, rows.Bytes(), top.Bytes())
+	} else {
+		fmt.Fprint(&frame, "\x1b[H") // cursor top-left corner
+		frame.Write(rows.Bytes())
+		frame.Write(top.Bytes())
+	}
+	if frame.Len() > 0 {
+		fmt.Fprint(&scrBuf, "\x1b[?25l") // hide cursor
+		scrBuf.Write(frame.Bytes())
+	}
 
-	os.Stdout.Write(scrBuf.Bytes()) // write screen buffer to stdout
+	if !placed && e.buf.preview == nil {
+		// reposition cursor
+		fmt.Fprintf(&scrBuf, "\x1b[%d;%dH",
+			e.buf.cursor.y-e.buf.fileY+1,
+			e.rx-e.buf.fileX+e.gutterWidth()+1)
+	}
+
+	if frame.Len() > 0 {
+		fmt.Fprint(&scrBuf, "\x1b[?25h") // show cursor
+	}
+
+	var drawn time.Time
+	if st != nil {
+		drawn = time.Now()
+		st.draw = drawn.Sub(start) - st.scroll
+	}
+	e.term.Write(scrBuf.Bytes()) // write screen buffer to the terminal
+	e.updateTitle()
+	if st != nil {
+		st.write = time.Since(drawn)
+		st.allocs = mallocs() - allocs
+		st.bytes = scrBuf.Len()
+		st.frames++
+	}
 }
 
-func updateRow(src []rune) []rune {
-	tabSpaces := []rune(strings.Repeat(" ", editor.tabStop))
+func (e *Editor) updateRow(src []rune) []rune {
+	tabSpaces := []rune(strings.Repeat(" ", e.tabStop))
 	dest := []rune{}
 
 	for _, r := range src {
@@ -440,112 +796,83 @@ func updateRow(src []rune) []rune {
 	return dest
 }
 
-func moveCursor(key int) {
+func (e *Editor) moveCursor(key int) {
 
-	endOfFile := editor.cursor.y >= len(editor.lines)
+	endOfFile := e.buf.cursor.y >= len(e.buf.lines)
 
 	switch key {
 	case kArrowLeft:
-		if editor.cursor.x > 0 {
-			editor.cursor.x--
-		} else if editor.cursor.y > 0 {
+		if e.buf.cursor.x > 0 {
+			e.buf.cursor.x--
+		} else if e.buf.cursor.y > 0 {
 			/* if we are at the beginning of a line then move to the end of the previous line */
-			editor.cursor.y--
-			editor.cursor.x = len(editor.lines[editor.cursor.y].chars)
+			e.buf.cursor.y--
+			e.buf.cursor.x = len(e.buf.lines[e.buf.cursor.y].chars)
 		}
 	case kArrowRight:
 		if !endOfFile {
-			if editor.cursor.x < len(editor.lines[editor.cursor.y].chars) {
-				editor.cursor.x++
-			} else if editor.cursor.x == len(editor.lines[editor.cursor.y].chars) {
+			if e.buf.cursor.x < len(e.buf.lines[e.buf.cursor.y].chars) {
+				e.buf.cursor.x++
+			} else if e.buf.cursor.x == len(e.buf.lines[e.buf.cursor.y].chars) {
 				/* if we are at the end of a line then move to the start of the next line */
-				editor.cursor.y++
-				editor.cursor.x = 0
+				e.buf.cursor.y++
+				e.buf.cursor.x = 0
 			}
 		}
 	case kArrowDown:
-		if editor.cursor.y < len(editor.lines) {
-			editor.cursor.y++
+		if e.buf.cursor.y < len(e.buf.lines) {
+			e.moveVertically(e.buf.cursor.y + 1)
 		}
 	case kArrowUp:
-		if editor.cursor.y > 0 {
-			editor.cursor.y--
+		if e.buf.cursor.y > 0 {
+			e.moveVertically(e.buf.cursor.y - 1)
 		}
 	}
 
 	/* snap cursor to end of line */
-	endOfFile = editor.cursor.y >= len(editor.lines)
+	endOfFile = e.buf.cursor.y >= len(e.buf.lines)
 	rowLen := 0
 	if !endOfFile {
-		rowLen = len(editor.lines[editor.cursor.y].chars)
+		rowLen = len(e.buf.lines[e.buf.cursor.y].chars)
 	}
-	if editor.cursor.x > rowLen {
-		editor.cursor.x = rowLen
+	if e.buf.cursor.x > rowLen {
+		e.buf.cursor.x = rowLen
 	}
 }
 
-func setCursor(p point) {
-	editor.cursor.x = p.x
-	editor.cursor.y = p.y
+func (e *Editor) setCursor(p point) {
+	e.buf.cursor.x = p.x
+	e.buf.cursor.y = p.y
 }
 
 /*-----------------------------------------------------------------------------
  * Match operations
  */
 
-func paren(left rune, right rune) (point, error) {
-	var depth = 0
-	p := point{}
-	x := 0
-	startFromCursor := true
-
-	for y := editor.cursor.y; y >= 0; y-- {
-		line := editor.lines[y]
-
-		if startFromCursor {
-			// start search from the position befor the cursor
-			x = editor.cursor.x - 1
-			startFromCursor = false
-
-		} else {
-			// otherwise start search from the last character in the line
-			x = len(line.chars) - 1
-		}
-
-		if len(line.chars) == 0 {
-			// skip empty lines
-			continue
-		}
-
-		for i := x; i >= 0; i-- {
-			if line.chars[i] == right {
-				depth++
-			} else if line.chars[i] == left {
-				depth--
-			}
-			if depth == 0 {
-				p.x = i
-				p.y = y
-				return p, nil
-			}
+/* paren returns the left bracket matching the right one before the cursor. */
+func (e *Editor) paren(left rune, right rune) (point, error) {
+	c := e.buf.cursor
+	if c.x > 0 && c.y < len(e.buf.lines) && e.buf.lines[c.y].chars[c.x-1] == right {
+		if p, ok := e.buf.matchBracket(point{x: c.x - 1, y: c.y}); ok && e.buf.lines[p.y].chars[p.x] == left {
+			return p, nil
 		}
 	}
-
-	return p, fmt.Errorf("no matching parenthesis found")
+	return point{}, fmt.Errorf("no matching parenthesis found")
 }
 
-func matchParenthesis(left rune, right rune) {
-	c := editor.cursor
+func (e *Editor) matchParenthesis(left rune, right rune) {
+	c := e.buf.cursor
 
-	p, err := paren(left, right)
+	p, err := e.paren(left, right)
 
 	if err != nil {
-		setStatusMsg("No matching parenthesis found")
+		e.setStatusMsg("No matching parenthesis found")
+		e.bell()
 	} else {
-		editor.cursor = p
-		refreshScreen()
+		e.buf.cursor = p
+		e.refreshScreen()
 		time.Sleep(300000 * time.Microsecond)
-		editor.cursor = c
+		e.buf.cursor = c
 
 	}
 }
@@ -565,59 +892,79 @@ func rowInsertChar(row []rune, col int, c int) []rune {
 	return row
 }
 
-func insertChar(key int) {
-	if editor.cursor.y == len(editor.lines) {
-		insertRow(len(editor.lines), "")
+func (e *Editor) insertChar(key int) {
+	if !e.canEdit(e.buf.cursor.y, e.buf.cursor.y) {
+		return
+	}
+	if e.buf.cursor.y == len(e.buf.lines) {
+		e.insertRow(len(e.buf.lines), "")
+	}
+	e.buf.lines[e.buf.cursor.y].chars = rowInsertChar(e.buf.lines[e.buf.cursor.y].chars, e.buf.cursor.x, key)
+	e.buf.lines[e.buf.cursor.y].render = e.updateRow(e.buf.lines[e.buf.cursor.y].chars)
+	e.buf.cursor.x++
+	e.buf.dirty = true
+	e.changes++
+	if !unicode.IsSpace(rune(key)) {
+		e.autoWrapLine()
 	}
-	editor.lines[editor.cursor.y].chars = rowInsertChar(editor.lines[editor.cursor.y].chars, editor.cursor.x, key)
-	editor.lines[editor.cursor.y].render = updateRow(editor.lines[editor.cursor.y].chars)
-	editor.cursor.x++
-	editor.dirty = true
 }
 
-func insertRow(row int, s string) {
-	if row < 0 || row > len(editor.lines) {
+func (e *Editor) insertRow(row int, s string) {
+	if row < 0 || row > len(e.buf.lines) {
 		return
 	}
 
 	rns := []rune(s)
-	nrow := line{chars: rns, render: updateRow(rns)}
-
-	editor.lines = append(editor.lines, line{})
-	copy(editor.lines[row+1:], editor.lines[row:])
-	editor.lines[row] = nrow
-	editor.dirty = true
+	nrow := line{chars: rns, render: e.updateRow(rns)}
+
+	e.buf.lines = append(e.buf.lines, line{})
+	copy(e.buf.lines[row+1:], e.buf.lines[row:])
+	e.buf.lines[row] = nrow
+	e.buf.shiftProtected(row, 1)
+	e.buf.shiftBookmarks(row, 1)
+	e.buf.dirty = true
+	e.changes++
 }
 
-func insertNewLine() {
-	if editor.cursor.x == 0 {
-		insertRow(editor.cursor.y, "")
+func (e *Editor) insertNewLine() {
+	if e.buf.cursor.x == 0 && !e.canInsertRow(e.buf.cursor.y) {
+		return
+	}
+	if e.buf.cursor.x > 0 && !e.canEdit(e.buf.cursor.y, e.buf.cursor.y) {
+		return
+	}
+
+	if e.buf.cursor.x == 0 {
+		e.insertRow(e.buf.cursor.y, "")
 
 	} else {
 
-		moveChars := string(editor.lines[editor.cursor.y].chars[editor.cursor.x:])
+		moveChars := string(e.buf.lines[e.buf.cursor.y].chars[e.buf.cursor.x:])
 
-		editor.lines[editor.cursor.y].chars = editor.lines[editor.cursor.y].chars[:editor.cursor.x]
-		editor.lines[editor.cursor.y].render = updateRow(editor.lines[editor.cursor.y].chars)
+		e.buf.lines[e.buf.cursor.y].chars = e.buf.lines[e.buf.cursor.y].chars[:e.buf.cursor.x]
+		e.buf.lines[e.buf.cursor.y].render = e.updateRow(e.buf.lines[e.buf.cursor.y].chars)
 
-		insertRow(editor.cursor.y+1, moveChars)
+		e.insertRow(e.buf.cursor.y+1, moveChars)
 	}
-	editor.cursor.y++
-	editor.cursor.x = 0
+	e.buf.cursor.y++
+	e.buf.cursor.x = 0
 }
 
 /*-----------------------------------------------------------------------------
  * Delete operations
  */
 
-func deleteRow(row int) {
-	if row < 0 || row >= len(editor.lines) {
+func (e *Editor) deleteRow(row int) {
+	if row < 0 || row >= len(e.buf.lines) {
 		return
 	}
 
-	copy(editor.lines[row:], editor.lines[row+1:])
-	editor.lines = editor.lines[:len(editor.lines)-1]
-	editor.dirty = true
+	copy(e.buf.lines[row:], e.buf.lines[row+1:])
+	e.buf.lines = e.buf.lines[:len(e.buf.lines)-1]
+	e.buf.shiftProtected(row, -1)
+	e.buf.shiftBookmarks(row, -1)
+	e.buf.dirty = true
+	e.changes++
 }
 
 func rowDeleteChar(row []rune, col int) []rune {
@@ -630,81 +977,154 @@ func rowDeleteChar(row []rune, col int) []rune {
 	return row
 }
 
-func deleteChar() {
-	if editor.cursor.y == len(editor.lines) {
+func (e *Editor) deleteChar() {
+	if e.buf.cursor.y == len(e.buf.lines) {
+		e.bell()
 		return
 	}
 
-	if editor.cursor.x == 0 && editor.cursor.y == 0 {
+	if e.buf.cursor.x == 0 && e.buf.cursor.y == 0 {
+		e.bell()
 		return
 	}
 
-	if editor.cursor.x > 0 {
-		editor.lines[editor.cursor.y].chars = rowDeleteChar(editor.lines[editor.cursor.y].chars, editor.cursor.x-1)
-		editor.lines[editor.cursor.y].render = updateRow(editor.lines[editor.cursor.y].chars)
-		editor.cursor.x--
+	from := e.buf.cursor.y
+	if e.buf.cursor.x == 0 {
+		from-- // joins the line with the one above
+	}
+	if !e.canEdit(from, e.buf.cursor.y) {
+		return
+	}
+
+	if e.buf.cursor.x > 0 {
+		e.buf.lines[e.buf.cursor.y].chars = rowDeleteChar(e.buf.lines[e.buf.cursor.y].chars, e.buf.cursor.x-1)
+		e.buf.lines[e.buf.cursor.y].render = e.updateRow(e.buf.lines[e.buf.cursor.y].chars)
+		e.buf.cursor.x--
 	} else {
-		editor.cursor.x = len(editor.lines[editor.cursor.y-1].chars)
-		editor.lines[editor.cursor.y-1].chars = append(editor.lines[editor.cursor.y-1].chars, editor.lines[editor.cursor.y].chars...)
-		editor.lines[editor.cursor.y-1].render = updateRow(editor.lines[editor.cursor.y-1].chars)
-		deleteRow(editor.cursor.y)
-		editor.cursor.y--
+		e.buf.cursor.x = len(e.buf.lines[e.buf.cursor.y-1].chars)
+		e.buf.lines[e.buf.cursor.y-1].chars = append(e.buf.lines[e.buf.cursor.y-1].chars, e.buf.lines[e.buf.cursor.y].chars...)
+		e.buf.lines[e.buf.cursor.y-1].render = e.updateRow(e.buf.lines[e.buf.cursor.y-1].chars)
+		e.deleteRow(e.buf.cursor.y)
+		e.buf.cursor.y--
 	}
 
-	editor.dirty = true
+	e.buf.dirty = true
+	e.changes++
 }
 
 /*-----------------------------------------------------------------------------
  * Handle user input
  */
 
-func rawReadKey() (byte, error) {
-	k := []byte{0}
-	n, err := os.Stdin.Read(k)
-	switch {
-	case err == io.EOF:
-		return 0, errNoInput
-	case err != nil:
-		return 0, err
-	case n == 0:
-		return 0, errNoInput
-	default:
-		return k[0], nil
+func (e *Editor) rawReadKey() (byte, error) {
+	key, err := e.term.ReadKey()
+	if err == nil && e.debugKeys {
+		e.keyBytes = append(e.keyBytes, key)
+	}
+	return key, err
+}
+
+/*
+readEscapeByte reads the next byte of an escape sequence and reports false if
+none arrives within the escape timeout, in which case the escape was a key of
+its own. Terminals that can not wait that precisely fall back on the timeout
+of ReadKey.
+*/
+func (e *Editor) readEscapeByte() (byte, bool, error) {
+	if w, ok := e.term.(KeyWaiter); ok && !w.WaitKey(e.escTimeout) {
+		return 0, false, nil
+	}
+	b, err := e.rawReadKey()
+	if err == ErrNoInput {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return b, true, nil
+}
+
+/* readKey returns the next key of the macro being played or else of the terminal, recording it if a macro is being recorded. */
+func (e *Editor) readKey() (int, error) {
+	if len(e.replayKeys) > 0 {
+		k := e.replayKeys[0]
+		e.replayKeys = e.replayKeys[1:]
+		return k, nil
+	}
+	var k int
+	var err error
+	if len(e.unread) > 0 {
+		k, e.unread = e.unread[0], e.unread[1:]
+	} else {
+		k, err = e.readTermKey()
+	}
+	if err == nil {
+		e.keysRead = append(e.keysRead, k)
+		if e.macro != nil {
+			e.macro = append(e.macro, k)
+		}
 	}
+	return k, err
 }
 
-func readKey() (int, error) {
+func (e *Editor) readTermKey() (int, error) {
 
 	for {
-		key, err := rawReadKey()
+		e.reportUnknownKey() // a sequence read in the last round that was not returned
+		key, err := e.rawReadKey()
+		if err == nil {
+			e.keyPressed()
+		}
 		switch {
-		case err == errNoInput:
+		case err == ErrNoInput:
+			if err := e.ctx.Err(); err != nil {
+				return 0, err
+			}
+			ran := e.runTasks()
+			idled := e.runIdle()
+			resized := e.checkResize()
+			if ran || idled || resized {
+				e.refreshScreen()
+			}
 			continue
 		case err == io.EOF:
 			return 0, err
 		case err != nil:
-			return 0, fmt.Errorf("reading key %s", err)
+			return 0, fmt.Errorf("reading key %w", err)
 		case key == '\x1b': // escape character 27
-			esc0, err := rawReadKey()
-			if err == errNoInput {
-				return '\x1b', nil
-			}
+			esc0, ok, err := e.readEscapeByte()
 			if err != nil {
 				return 0, err
 			}
-			esc1, err := rawReadKey()
-			if err == errNoInput {
-				return '\x1b', err
+			if !ok {
+				return '\x1b', nil // a bare escape key
 			}
+			if esc0 != '[' && esc0 != 'O' && esc0 >= ' ' && esc0 < kBackSpace {
+				return kAlt + int(esc0), nil // alt sends escape before the key
+			}
+			esc1, ok, err := e.readEscapeByte()
 			if err != nil {
 				return 0, err
 			}
+			if !ok {
+				return '\x1b', nil
+			}
+
+			if esc0 == '[' && (esc1 == 'I' || esc1 == 'O') { // focus in or out
+				e.focusChanged(esc1 == 'I')
+				e.keyBytes = e.keyBytes[:0]
+				e.refreshScreen()
+				continue
+			}
 
 			if esc0 == '[' {
 				if esc1 >= '0' && esc1 <= '9' {
-					esc2, err := rawReadKey()
-					if err == errNoInput {
-						return '\x1b', err
+					esc2, ok, err := e.readEscapeByte()
+					if err != nil {
+						return 0, err
+					}
+					if !ok {
+						return '\x1b', nil
 					}
 					if esc2 == '~' {
 						switch esc1 {
@@ -714,16 +1134,21 @@ func readKey() (int, error) {
 							return kPageDown, nil // fn+kArrowDown
 						case '3':
 							return kDelete, nil
+						case '2':
+							return kInsert, nil
 						}
 					}
 					if esc2 == ';' {
-						esc3, err1 := rawReadKey()
-						esc4, err2 := rawReadKey()
-						if err1 == errNoInput {
-							return '\x1b', err1
+						esc3, ok3, err := e.readEscapeByte()
+						if err != nil {
+							return 0, err
+						}
+						esc4, ok4, err := e.readEscapeByte()
+						if err != nil {
+							return 0, err
 						}
-						if err2 == errNoInput {
-							return '\x1b', err2
+						if !ok3 || !ok4 {
+							return '\x1b', nil
 						}
 						if esc3 == '2' {
 							switch esc4 { // shift + arrow keys
@@ -757,29 +1182,20 @@ func readKey() (int, error) {
 				}
 			}
 
-		case key == 195: // swedish characters
-			esc1, err := rawReadKey()
-			if err == errNoInput {
-				return '\x1b', err
-			}
-			if err != nil {
-				return 0, err
-			}
-
-			switch {
-			case esc1 == 165:
-				return 'å', nil
-			case esc1 == 164:
-				return 'ä', nil
-			case esc1 == 182:
-				return 'ö', nil
-			case esc1 == 133:
-				return 'Å', nil
-			case esc1 == 132:
-				return 'Ä', nil
-			case esc1 == 150:
-				return 'Ö', nil
+		case key >= 0xc0: // first byte of a multi-byte UTF-8 character
+			buf := []byte{key}
+			for !utf8.FullRune(buf) {
+				b, ok, err := e.readEscapeByte() // the rest comes as quickly as that of an escape sequence
+				if err != nil {
+					return 0, err
+				}
+				if !ok {
+					return utf8.RuneError, nil // the character was cut short
+				}
+				buf = append(buf, b)
 			}
+			r, _ := utf8.DecodeRune(buf)
+			return int(r), nil
 
 		default:
 			return int(key), nil
@@ -787,66 +1203,260 @@ func readKey() (int, error) {
 	}
 }
 
-func processKey(readonly bool) (bool, error) {
-	k, err := readKey()
+/*-----------------------------------------------------------------------------
+ * Actions & key bindings
+ */
+
+var keyNames = map[string]int{
+	"enter":      '\r',
+	"tab":        '\t',
+	"esc":        '\x1b',
+	"backspace":  kBackSpace,
+	"up":         kArrowUp,
+	"down":       kArrowDown,
+	"left":       kArrowLeft,
+	"right":      kArrowRight,
+	"pageup":     kPageUp,
+	"pagedown":   kPageDown,
+	"home":       kHome,
+	"end":        kEnd,
+	"delete":     kDelete,
+	"insert":     kInsert,
+	"ctrl+space": 0,
+}
+
+/* parseKey converts a key name such as "ctrl+g", "alt+q", "pageup" or "x" to a key code. */
+func parseKey(name string) (int, error) {
+	if k, ok := keyNames[strings.ToLower(name)]; ok {
+		return k, nil
+	}
+
+	if k, ok := strings.CutPrefix(name, "alt+"); ok && len(k) == 1 && k[0] >= ' ' && k[0] < kBackSpace {
+		return kAlt + int(k[0]), nil
+	}
+
+	lname := strings.ToLower(name)
+	if strings.HasPrefix(lname, "ctrl+") && len(lname) == 6 && lname[5] >= 'a' && lname[5] <= 'z' {
+		return ctrlKey(lname[5]), nil
+	}
+
+	if rs := []rune(name); len(rs) == 1 {
+		return int(rs[0]), nil
+	}
+
+	return 0, fmt.Errorf("unknown key %q", name)
+}
+
+func (e *Editor) bindKey(key string, action string) error {
+	key, err := e.resolveKey(key)
+	if err != nil {
+		return err
+	}
+	k, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	e.keyBindings[k] = action
+	return nil
+}
+
+var (
+	defaultActionsMu sync.Mutex
+	defaultActions   = map[string]func(*Editor){}
+)
+
+// RegisterAction adds the action name, which runs fn, to every editor started
+// after the call. The action can be bound to a key in keymap.json.
+func RegisterAction(name string, fn func(*Editor)) {
+	defaultActionsMu.Lock()
+	defer defaultActionsMu.Unlock()
+	defaultActions[name] = fn
+}
+
+func (e *Editor) runAction(name string) bool {
+	action, ok := e.actionDispatch[name]
+	if !ok {
+		return false
+	}
+	action()
+	return true
+}
+
+/* stopAfterAction keeps whether a key handled within an action quit, or why keys could not be read, for the main loop to act on. */
+func (e *Editor) stopAfterAction(quit bool, err error) {
+	e.actionQuit = e.actionQuit || quit
+	if e.actionErr == nil {
+		e.actionErr = err
+	}
+}
+
+/* actionStop returns, and forgets, what stopAfterAction kept. */
+func (e *Editor) actionStop() (bool, error) {
+	quit, err := e.actionQuit, e.actionErr
+	e.actionQuit, e.actionErr = false, nil
+	return quit, err
+}
+
+/*-----------------------------------------------------------------------------
+ * Main loop tasks
+ */
+
+/* queueTask schedules fn to run on the main loop. It is safe to call from any goroutine. */
+func (e *Editor) queueTask(fn func()) {
+	e.tasks <- fn
+}
+
+/* runTasks runs the queued tasks and reports whether any task was run. */
+func (e *Editor) runTasks() bool {
+	ran := false
+	for {
+		select {
+		case task := <-e.tasks:
+			e.runTask(task)
+			ran = true
+		default:
+			if ran {
+				e.snapCursor()
+			}
+			return ran
+		}
+	}
+}
+
+/* runTask runs task and tells the hooks, the timeline and repeat about the edits it makes, as a key press does. */
+func (e *Editor) runTask(task func()) {
+	cursor, changes := e.buf.cursor, e.changes
+	task()
+	if e.changes != changes {
+		e.editRun = false // a key typed after the task does not add to the edit before it
+	}
+	e.recordTimeline(changes)
+	e.notifyChanges(cursor, changes)
+}
+
+/* snapCursor moves the cursor back inside the text if the text changed under it. */
+func (e *Editor) snapCursor() {
+	if e.buf.cursor.y > len(e.buf.lines) {
+		e.buf.cursor.y = len(e.buf.lines)
+	}
+	if e.buf.cursor.y < 0 {
+		e.buf.cursor.y = 0
+	}
+
+	rowLen := 0
+	if e.buf.cursor.y < len(e.buf.lines) {
+		rowLen = len(e.buf.lines[e.buf.cursor.y].chars)
+	}
+	if e.buf.cursor.x > rowLen {
+		e.buf.cursor.x = rowLen
+	}
+	if e.buf.cursor.x < 0 {
+		e.buf.cursor.x = 0
+	}
+}
+
+func (e *Editor) notifyChanges(cursor point, changes int) {
+	if e.changes != changes {
+		e.runHook(TextChanged)
+	}
+	if e.buf.cursor != cursor {
+		e.runHook(CursorMoved)
+	}
+}
+
+func (e *Editor) processKey(readonly bool) (bool, error) {
+	waiting := time.Now()
+	e.keyBytes = e.keyBytes[:0]
+	e.keysRead = e.keysRead[:0]
+	k, err := e.readKey()
 
 	if err != nil {
 		return true, err
 	}
+	e.problem = nil
+	if e.debugKeys {
+		e.setStatusMsg("%s", describeKey(e.keyBytes, k))
+	}
+	if st := e.renderStats; st != nil {
+		st.wait = time.Since(waiting)
+		defer func(t time.Time) { st.handle = time.Since(t) }(time.Now())
+	}
+
+	defer e.notifyChanges(e.buf.cursor, e.changes)
+	defer e.noteEdit(e.changes)
+	defer e.recordTimeline(e.changes)
+
+	if e.overlayKey(k) {
+		return false, nil
+	}
+	if e.buf.preview != nil && e.previewKey(k) {
+		return false, nil
+	}
+
+	if name, ok := e.keyBindings[k]; ok {
+		if e.runAction(name) {
+			return e.actionStop()
+		}
+	}
+	if !readonly && e.typeOverSelection(k) {
+		return false, nil
+	}
+	if !readonly && e.pasteBurst(k) {
+		return false, nil
+	}
 
 	switch k {
 	case '\r': // enter
 		if readonly {
 			break
 		}
-		insertNewLine()
+		e.insertNewLine()
 
 	case ctrlKey('q'): // quit editor
-		if editor.dirty && !editor.quitComfirm {
-			setStatusMsg("There are unsaved changes. Press ctrl-q to quit or ctrl-s to save.")
-			editor.quitComfirm = true
+		if e.anyBufferDirty() && !e.quitComfirm {
+			e.setStatusMsg("There are unsaved changes. Press ctrl-q to quit or ctrl-s to save.")
+			e.quitComfirm = true
 			return false, nil
 		}
 		return true, nil
 
 	case kArrowDown, kArrowLeft, kArrowRight, kArrowUp:
-		moveCursor(k)
+		e.moveCursor(k)
 
 	case kPageUp:
-		editor.cursor.y = editor.fileY
-		for i := 0; i < editor.termRows; i++ {
-			moveCursor(kArrowUp)
+		e.buf.cursor.y = e.buf.fileY
+		e.snapCursor()
+		for i := 0; i < e.termRows; i++ {
+			e.moveCursor(kArrowUp)
 		}
 
 	case kPageDown:
-		editor.cursor.y = editor.fileY + editor.termRows - 1
-		if editor.cursor.y > len(editor.lines) {
-			editor.cursor.y = len(editor.lines)
-		}
-		for i := 0; i < editor.termRows; i++ {
-			moveCursor(kArrowDown)
+		e.buf.cursor.y = e.buf.fileY + e.termRows - 1
+		e.snapCursor()
+		for i := 0; i < e.termRows; i++ {
+			e.moveCursor(kArrowDown)
 		}
 
 	case ctrlKey('a'), kHome:
-		editor.cursor.x = 0
+		e.lineStart()
 
 	case ctrlKey('e'), kEnd:
-		if editor.cursor.y < len(editor.lines) {
-			editor.cursor.x = len(editor.lines[editor.cursor.y].chars)
+		if e.buf.cursor.y < len(e.buf.lines) {
+			e.buf.cursor.x = len(e.buf.lines[e.buf.cursor.y].chars)
 		}
 
 	case kBackSpace:
 		if readonly {
 			break
 		}
-		deleteChar()
+		e.deleteChar()
 
 	case kDelete, ctrlKey('h'):
 		if readonly {
 			break
 		}
-		moveCursor(kArrowRight)
-		deleteChar()
+		e.moveCursor(kArrowRight)
+		e.deleteChar()
 
 	case ctrlKey('k'):
 		if readonly {
@@ -854,64 +1464,67 @@ func processKey(readonly bool) (bool, error) {
 		}
 
 		for {
-			if editor.cursor.x >= len(editor.lines[editor.cursor.y].chars) {
+			if e.buf.cursor.x >= len(e.buf.lines[e.buf.cursor.y].chars) {
 				break
 			}
-			moveCursor(kArrowRight)
-			deleteChar()
+			e.moveCursor(kArrowRight)
+			e.deleteChar()
 		}
 
-	case ctrlKey('l'), '\x1b':
+	case ctrlKey('l'):
 		break
 
+	case '\x1b':
+		e.buf.mark = nil
+
 	case ctrlKey('s'):
 		if readonly {
 			break
 		}
-		save()
+		e.save()
 
 	case ctrlKey('f'):
-		find()
+		e.find()
 
 	case ')':
 		if readonly {
 			break
 		}
-		insertChar(k)
-		matchParenthesis('(', ')')
+		e.typeChar(k)
+		e.matchParenthesis('(', ')')
 
 	case '}':
 		if readonly {
 			break
 		}
-		insertChar(k)
-		matchParenthesis('{', '}')
+		e.typeChar(k)
+		e.matchParenthesis('{', '}')
 
 	case ']':
 		if readonly {
 			break
 		}
-		insertChar(k)
-		matchParenthesis('[', ']')
+		e.typeChar(k)
+		e.matchParenthesis('[', ']')
 
 	case 'å', 'ä', 'ö', 'Å', 'Ä', 'Ö':
 		if readonly {
 			break
 		}
-		insertChar(k)
+		e.typeChar(k)
 
 	case '\t':
 		if readonly {
 			break
 		}
-		insertChar(k)
+		e.insertTab()
 
 	default:
 		if readonly {
 			break
 		}
 		if unicode.IsPrint(rune(k)) {
-			insertChar(k)
+			e.typeChar(k)
 		}
 	}
 
@@ -922,65 +1535,119 @@ func processKey(readonly bool) (bool, error) {
  * Save to file
  */
 
-func linesToString() string {
+func (e *Editor) linesToString() string {
+	return e.buf.text()
+}
+
+/* text returns the lines of b, hidden ones included, each ended by a newline. */
+func (b *buffer) text() string {
 	var sb strings.Builder
 
-	for _, rows := range editor.lines {
+	for _, rows := range b.allLines() {
 		sb.WriteString(string(rows.chars))
 		sb.WriteByte('\n')
 	}
 	return sb.String()
 }
 
-func save() {
+func (e *Editor) save() {
 
-	if editor.fileName == "" {
-		editor.fileName = prompt("Save as: %s")
-		if editor.fileName == "" {
-			setStatusMsg("Save cancelled")
+	if e.buf.sink == nil && (e.buf.fileName == "" || isURL(e.buf.fileName)) {
+		name, _ := e.historyPrompt(fileHistory, "Save as: ", nil, completePath)
+		if name == "" {
+			e.setStatusMsg("Save cancelled")
 			return
 		}
+		e.buf.fileName = name
+		e.buf.compression = compressionFor(e.buf.fileName)
+		e.buf.scratch = false
 	}
 
-	f, err := os.Create(editor.fileName)
+	e.runHook(BufWritePre)
+
+	if e.buf.sink != nil {
+		n, err := io.WriteString(e.buf.sink, e.linesToString())
+		if err != nil {
+			e.setStatusMsg("error writing: %s", err)
+			return
+		}
+		e.setStatusMsg("%d bytes written", n)
+		e.buf.dirty = false
+		e.runHook(BufWritePost)
+		return
+	}
+
+	if e.saveFunc != nil {
+		data := []byte(e.linesToString())
+		if err := e.saveFunc(e.buf.fileName, data); err != nil {
+			e.setStatusMsg("error saving: %s: %s", err, e.buf.fileName)
+			return
+		}
+		e.setStatusMsg("%d bytes saved", len(data))
+		e.buf.dirty = false
+		e.runHook(BufWritePost)
+		return
+	}
+
+	f, err := e.createForWriting(e.buf.fileName)
 	if err != nil {
-		setStatusMsg("error creating file: %s: %s", err, editor.fileName)
+		if _, remote := parseRemotePath(e.buf.fileName); !remote && errors.Is(err, fs.ErrPermission) && e.sudoWrite() {
+			return
+		}
+		e.setStatusMsg("error creating file: %s: %s", err, e.buf.fileName)
 		return
 	}
 	defer f.Close()
 
-	n, err := fmt.Fprint(f, linesToString())
+	data, err := e.buf.fileData()
 	if err != nil {
-		setStatusMsg("error writing to file: %s: %s", err, editor.fileName)
+		e.setStatusMsg("error compressing: %s: %s", err, e.buf.fileName)
 		return
 	}
-	setStatusMsg("%d bytes written to disk", n)
-	editor.dirty = false
+	n, err := f.Write(data)
+	if err != nil {
+		e.setStatusMsg("error writing to file: %s: %s", err, e.buf.fileName)
+		return
+	}
+	if rp, remote := parseRemotePath(e.buf.fileName); remote {
+		e.setStatusMsg("%d bytes written to %s", n, rp.host)
+	} else {
+		e.setStatusMsg("%d bytes written to disk", n)
+	}
+	e.buf.dirty = false
+	e.buf.modTime = localModTime(e.buf.fileName)
+	e.runHook(BufWritePost)
 }
 
 /*-----------------------------------------------------------------------------
  * Open file
  */
 
-func openFile(name string) error {
-	f, err := os.Open(name)
+func (e *Editor) openFile(name string) error {
+	r, c, f, err := e.openDecompressed(name)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	editor.lines = []line{}
+	e.buf.lines = []line{}
+	e.buf.narrow = nil
+	e.buf.mark = nil
+	e.buf.compression = c
 
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		insertRow(len(editor.lines), scanner.Text())
+		e.insertRow(len(e.buf.lines), scanner.Text())
 	}
-	editor.fileName = name
-	editor.dirty = false
+	e.buf.fileName = name
+	e.buf.data = false
+	e.buf.dirty = false
+	e.buf.modTime = localModTime(name)
 
 	if err := scanner.Err(); err != nil {
 		return err
 	}
+	e.runHook(BufOpen)
 	return nil
 }
 
@@ -988,20 +1655,25 @@ func openFile(name string) error {
  * Open Data
  */
 
-func openData(data []byte) error {
-	editor.lines = []line{}
-	reader := bytes.NewReader(data)
+func (e *Editor) openData(data []byte) error {
+	return e.openReader(bytes.NewReader(data))
+}
+
+func (e *Editor) openReader(reader io.Reader) error {
+	e.buf.lines = []line{}
 
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
-		insertRow(len(editor.lines), scanner.Text())
+		e.insertRow(len(e.buf.lines), scanner.Text())
 	}
-	editor.fileName = "memory" // or set to something meaningful
-	editor.dirty = false
+	e.buf.fileName = "memory" // or set to something meaningful
+	e.buf.data = true
+	e.buf.dirty = false
 
 	if err := scanner.Err(); err != nil {
 		return err
 	}
+	e.runHook(BufOpen)
 
 	return nil
 }
@@ -1010,34 +1682,158 @@ func openData(data []byte) error {
  * Initialize editor
  */
 
-func initialize(readonly bool) error {
+func (e *Editor) initialize(readonly bool) error {
 
-	resizeWindow()
-	editor.cursor.x = 0
-	editor.cursor.y = 0
-	editor.tabStop = 4
-	editor.statusMsgTimeout = 3
+	e.resizeWindow()
+	e.buffers = nil
+	e.addBuffer()
+	e.readonly = readonly
+	if e.secure {
+		if err := disableCoreDumps(); err != nil {
+			return err
+		}
+	}
+	e.actionDispatch = map[string]func(){
+		"next_buffer":           e.nextBuffer,
+		"prev_buffer":           e.prevBuffer,
+		"collab_host":           e.collabHost,
+		"collab_join":           e.collabJoin,
+		"collab_leave":          e.collabLeave,
+		"compose":               e.compose,
+		"revert":                e.revert,
+		"rename_file":           e.renameFile,
+		"suspend":               e.suspend,
+		"open_url":              e.openLink,
+		"preview":               e.togglePreview,
+		"export_html":           e.exportBuffer,
+		"export_selection":      e.exportSelection,
+		"playback":              e.playback,
+		"checkpoint":            e.checkpoint,
+		"checkpoints":           e.checkpoints,
+		"line_start":            e.lineStart,
+		"first_nonblank_down":   e.firstNonblankDown,
+		"forward_sexp":          e.forwardSexp,
+		"backward_sexp":         e.backwardSexp,
+		"forward_sentence":      e.forwardSentence,
+		"backward_sentence":     e.backwardSentence,
+		"toggle_overwrite":      e.toggleOverwrite,
+		"toggle_bookmark":       e.toggleBookmark,
+		"next_bookmark":         e.nextBookmark,
+		"prev_bookmark":         e.prevBookmark,
+		"diff_unsaved":          e.diffUnsaved,
+		"local_history":         e.localHistory,
+		"toggle_readonly":       e.toggleReadonly,
+		"grep":                  e.grep,
+		"quickfix":              e.showQuickfix,
+		"quickfix_next":         e.quickfixNext,
+		"quickfix_prev":         e.quickfixPrev,
+		"debug_render":          e.toggleRenderStats,
+		"debug_keys":            e.toggleDebugKeys,
+		"describe_bindings":     e.describeBindings,
+		"new_buffer":            e.newBuffer,
+		"switch_buffer":         e.switchBuffer,
+		"narrow":                e.narrow,
+		"widen":                 e.widenAction,
+		"replace_all_buffers":   e.replaceAllBuffers,
+		"undo_replace":          e.undoReplace,
+		"replace":               e.replace,
+		"set_mark":              e.setMark,
+		"open_file":             e.openFileAction,
+		"file_info":             e.fileInfo,
+		"goto_line":             e.gotoLine,
+		"goto_top":              e.gotoTop,
+		"goto_bottom":           e.gotoBottom,
+		"goto_middle_of_screen": e.gotoMiddleOfScreen,
+		"record_macro":          e.recordMacro,
+		"play_macro":            e.playMacro,
+		"save_macro":            e.saveMacro,
+		"repeat":                e.repeat,
+		"universal_argument":    e.universalArgument,
+		"upcase":                e.upcase,
+		"downcase":              e.downcase,
+		"capitalize":            e.capitalize,
+		"increment_number":      e.incrementNumber,
+		"decrement_number":      e.decrementNumber,
+		"insert_date":           e.insertDate,
+		"insert_metadata":       e.insertMetadata,
+		"insert_file":           e.insertFile,
+		"insert_unicode":        e.insertUnicode,
+		"what_char":             e.whatChar,
+		"calc":                  e.calc,
+		"base64_encode":         e.base64Encode,
+		"base64_decode":         e.base64Decode,
+		"url_encode":            e.urlEncode,
+		"url_decode":            e.urlDecode,
+		"hex_dump":              e.hexDumpAction,
+		"hex_undump":            e.hexUndumpAction,
+		"json_format":           e.jsonFormat,
+		"json_minify":           e.jsonMinify,
+		"align":                 e.align,
+		"format_table":          e.formatTable,
+		"fill_paragraph":        e.fillParagraphAction,
+		"center_line":           e.centerLine,
+		"right_align_line":      e.rightAlignLine,
+		"number_lines":          e.numberLines,
+		"uniq_lines":            e.uniqLinesAction,
+		"uniq_all_lines":        e.uniqAllLinesAction,
+		"reverse_lines":         e.reverseLines,
+		"shuffle_lines":         e.shuffleLines,
+		"preview_image":         e.previewImageAction,
+	}
+	defaultActionsMu.Lock()
+	for name, fn := range defaultActions {
+		fn := fn
+		e.actionDispatch[name] = func() { fn(e) }
+	}
+	defaultActionsMu.Unlock()
+	e.keyBindings = map[int]string{
+		ctrlKey('n'): "next_buffer",
+		ctrlKey('p'): "prev_buffer",
+		ctrlKey(']'): "compose",
+		ctrlKey('z'): "suspend",
+		ctrlKey('g'): "file_info",
+		ctrlKey('r'): "record_macro",
+		ctrlKey('t'): "play_macro",
+		ctrlKey('y'): "repeat",
+		ctrlKey('u'): "universal_argument",
+		0:            "set_mark", // ctrl+space
+		kAlt + 'q':   "fill_paragraph",
+		kAlt + ')':   "forward_sexp",
+		kAlt + '(':   "backward_sexp",
+		kAlt + 'e':   "forward_sentence",
+		kAlt + 'a':   "backward_sentence",
+		kInsert:      "toggle_overwrite",
+		kAlt + 'm':   "toggle_bookmark",
+		kAlt + 'n':   "next_bookmark",
+		kAlt + 'p':   "prev_bookmark",
+	}
+	e.pluginCommands = map[string]*plugin{}
 	if readonly {
-		setStatusMsg("Press ctrl+q to exit.")
+		e.setStatusMsg("Press ctrl+q to exit.")
 	} else {
-		setStatusMsg("Press ctrl+q to exit. Press ctrl+s to save.")
+		e.setStatusMsg("Press ctrl+q to exit. Press ctrl+s to save.")
 	}
-
-	/* Handle resize window signals */
-	editor.signals = make(chan os.Signal, 1)
-	signal.Notify(editor.signals, syscall.SIGWINCH)
-
-	go func() {
-		for s := range editor.signals {
-			switch s {
-			case syscall.SIGABRT:
-				return
-			case syscall.SIGWINCH:
-				resizeWindow()
-				refreshScreen()
+	e.loadMacros()
+	e.loadKeymap()
+	e.addHook(BufOpen, func(HookEvent) { e.audit("open") })
+	e.addHook(BufOpen, func(HookEvent) { e.startTimeline() })
+	e.addHook(BufOpen, func(HookEvent) { e.loadBookmarks() })
+	e.addHook(BufWritePost, func(HookEvent) { e.audit("save") })
+	e.addHook(BufWritePost, func(HookEvent) { e.saveBookmarks() })
+	e.addHook(BufWritePost, func(HookEvent) { e.checkIndentation() })
+
+	if !e.isolated {
+		snapshot := func(HookEvent) {
+			if err := e.snapshot(e.buf); err != nil {
+				e.setStatusMsg("local history: %s", err)
 			}
 		}
-	}()
+		e.addHook(BufOpen, snapshot)
+		e.addHook(BufWritePost, snapshot)
+		e.startPlugins()
+		e.startScripting()
+		e.startControlSocket()
+	}
 
 	return nil
 }
@@ -1046,43 +1842,162 @@ func initialize(readonly bool) error {
  * Editor API
  */
 
-func Editor(source interface{}, readonly bool) error {
+func newEditor(term Terminal) *Editor {
+	e := &Editor{term: term}
+	e.tasks = make(chan func(), 64)
+	e.tabStop = 4
+	e.statusMsgTimeout = 3
+	e.escTimeout = defaultEscTimeout
+	e.theme = DefaultTheme
+	e.dateFormats = defaultDateFormats
+	e.textWidth = 80
+	e.keyboardLayout = "qwerty"
+	e.imagePreview = "auto"
+	e.hyperlinksOn = supportsHyperlinks()
+	e.ctx = context.Background()
+	e.OnIdle(occurrenceDelay, e.findOccurrences)
+	e.OnIdle(gitStatusDelay, e.refreshGitStatus)
+	e.OnIdle(historyDelay, e.historyTick)
+	e.OnIdle(autosaveDelay, e.autosaveBuffers)
+
+	defaultHooksMu.Lock()
+	e.hooks = map[Hook][]func(HookEvent){}
+	for h, fns := range defaultHooks {
+		e.hooks[h] = append([]func(HookEvent){}, fns...)
+	}
+	defaultHooksMu.Unlock()
+
+	return e
+}
+
+// New returns an editor configured by opts.
+func New(opts ...Option) *Editor {
+	e := newEditor(nil)
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.term == nil {
+		e.term = NewTTY()
+	}
+	return e
+}
+
+// Run edits source, a file name, a []byte or an io.Reader, until the user quits or ctx is
+// done. When ctx is done the terminal is restored and ctx.Err() is returned.
+func (e *Editor) Run(ctx context.Context, source interface{}) error {
+	e.ctx = ctx
 
-	if err := enableRawMode(); err != nil {
+	if err := e.term.RawMode(true); err != nil {
 		fmt.Fprintf(os.Stderr, "can not enable raw mode %s", err)
 		return err
 	}
 
-	if err := initialize(readonly); err != nil {
+	return e.run(source, e.readonly)
+}
+
+// Edit runs an editor configured by opts on source.
+func Edit(source interface{}, opts ...Option) error {
+	return New(opts...).Run(context.Background(), source)
+}
+
+/* saveRecorder notes whether a buffer was saved and passes the saves on to w. */
+type saveRecorder struct {
+	w     io.Writer
+	saved bool
+}
+
+func (r *saveRecorder) Write(p []byte) (int, error) {
+	r.saved = true
+	if r.w != nil {
+		return r.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// EditBytes runs an editor configured by opts on data. It returns the text of
+// the buffer when the editor exits and whether the user saved it.
+func EditBytes(data []byte, opts ...Option) ([]byte, bool, error) {
+	e := New(opts...)
+	rec := &saveRecorder{w: e.saveWriter}
+	e.saveWriter = rec
+
+	if err := e.Run(context.Background(), data); err != nil {
+		return nil, false, err
+	}
+
+	for _, b := range e.buffers {
+		if b.sink == rec {
+			return []byte(b.text()), rec.saved, nil
+		}
+	}
+	return nil, rec.saved, nil
+}
+
+func (e *Editor) run(source interface{}, readonly bool) error {
+
+	if err := e.initialize(readonly); err != nil {
 		return err
 	}
 
 	switch src := source.(type) {
 	case string: // File source
 		if src != "" {
-			if err := openFile(src); err != nil {
-				cleanupBeforeExit()
+			if err := e.openFile(src); errors.Is(err, fs.ErrNotExist) {
+				e.newFile(src)
+			} else if err != nil {
+				e.cleanupBeforeExit()
 				return err
 			}
 		}
 	case []byte: // Data source
-		if err := openData(src); err != nil {
-			cleanupBeforeExit()
+		if err := e.openData(src); err != nil {
+			e.cleanupBeforeExit()
+			return err
+		}
+	case io.Reader: // Stream source
+		if err := e.openReader(src); err != nil {
+			e.cleanupBeforeExit()
 			return err
 		}
 	default:
+		e.cleanupBeforeExit()
 		return fmt.Errorf("unsupported source type")
 	}
+	e.buf.sink = e.saveWriter
+
+	if len(e.files) > 0 {
+		first := e.buf
+		for _, name := range e.files {
+			if err := e.openBuffer(name); err != nil {
+				e.cleanupBeforeExit()
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		e.buf = first
+	}
+	if e.startLine > 0 {
+		y := e.startLine - 1
+		if y >= len(e.buf.lines) {
+			y = len(e.buf.lines) - 1
+		}
+		e.setCursor(point{y: y})
+		e.snapCursor()
+	}
+	e.setFocusReporting(true)
+	e.startStatusUpdaters()
 
 	for {
-		refreshScreen()
-		exit_editor, err := processKey(readonly)
+		e.refreshScreen()
+		exit_editor, err := e.processKey(e.isReadonly()) // toggle_readonly or switching buffers may have changed it
+		if !exit_editor && err == nil {
+			exit_editor, err = e.actionStop() // an action run by a script, a plugin or the control socket
+		}
 		if err != nil {
-			cleanupBeforeExit()
+			e.cleanupBeforeExit()
 			return err
 		}
 		if exit_editor {
-			cleanupBeforeExit()
+			e.cleanupBeforeExit()
 			return nil
 		}
 	}
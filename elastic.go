@@ -0,0 +1,142 @@
+package editor
+
+/*-----------------------------------------------------------------------------
+ * Elastic tabstops
+ *
+ * With the "elastic_tabstops" setting on, a tab ends a cell instead of
+ * jumping a fixed number of columns, and the cells in the same column of
+ * adjacent lines are made as wide as the widest of them, so tab separated
+ * tables and comments line up. A line without the column ends the block. The
+ * lines on the screen, and the runs of lines with tabs around them, are laid
+ * out again every time the screen is drawn.
+ */
+
+const elasticPadding = 2 // columns between the text of a cell and the next one
+
+/* lineRx returns the render column of character x of l. */
+func (e *Editor) lineRx(l line, x int) int {
+	if l.tabs == nil {
+		return e.computeRx(l.chars, x)
+	}
+	rx, tab := 0, 0
+	for i := 0; i < x && i < len(l.chars); i++ {
+		if l.chars[i] == '\t' && tab < len(l.tabs) {
+			rx += l.tabs[tab]
+			tab++
+			continue
+		}
+		rx++
+	}
+	return rx
+}
+
+func hasTab(chars []rune) bool {
+	for _, r := range chars {
+		if r == '\t' {
+			return true
+		}
+	}
+	return false
+}
+
+/* cellWidths returns the widths of the cells of chars ended by a tab. */
+func cellWidths(chars []rune) []int {
+	cells := []int{}
+	w := 0
+	for _, r := range chars {
+		if r == '\t' {
+			cells = append(cells, w)
+			w = 0
+			continue
+		}
+		w++
+	}
+	return cells
+}
+
+/* layoutElastic lays out the lines from to to of b, and the runs of lines with tabs they are in. */
+func (e *Editor) layoutElastic(b *buffer, from, to int) {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(b.lines) {
+		to = len(b.lines)
+	}
+	if from >= to {
+		return
+	}
+	for from > 0 && hasTab(b.lines[from].chars) && hasTab(b.lines[from-1].chars) {
+		from--
+	}
+	for to < len(b.lines) && hasTab(b.lines[to-1].chars) && hasTab(b.lines[to].chars) {
+		to++
+	}
+
+	cells := make([][]int, to-from)
+	widths := make([][]int, to-from)
+	for i := range cells {
+		cells[i] = cellWidths(b.lines[from+i].chars)
+		widths[i] = make([]int, len(cells[i]))
+	}
+
+	/* the cells of column c on consecutive lines form a block */
+	for c := 0; ; c++ {
+		any := false
+		for i := 0; i < len(cells); {
+			if len(cells[i]) <= c {
+				i++
+				continue
+			}
+			any = true
+			j, width := i, e.tabStop
+			for ; j < len(cells) && len(cells[j]) > c; j++ {
+				if w := cells[j][c] + elasticPadding; w > width {
+					width = w
+				}
+			}
+			for ; i < j; i++ {
+				widths[i][c] = width - cells[i][c]
+			}
+		}
+		if !any {
+			break
+		}
+	}
+
+	for i := range cells {
+		l := &b.lines[from+i]
+		if len(cells[i]) == 0 {
+			l.tabs = nil
+			l.render = e.updateRow(l.chars)
+			continue
+		}
+		l.tabs = widths[i]
+		render := make([]rune, 0, len(l.chars))
+		tab := 0
+		for _, r := range l.chars {
+			if r == '\t' {
+				for n := 0; n < l.tabs[tab]; n++ {
+					render = append(render, ' ')
+				}
+				tab++
+				continue
+			}
+			render = append(render, r)
+		}
+		l.render = render
+	}
+}
+
+/* setElasticTabstops turns elastic tabstops on or off, laying out all buffers with fixed tabs when off. */
+func (e *Editor) setElasticTabstops(on bool) {
+	e.elastic = on
+	if on {
+		return
+	}
+	for _, b := range e.buffers {
+		for i := range b.lines {
+			b.lines[i].tabs = nil
+			b.lines[i].render = e.updateRow(b.lines[i].chars)
+		}
+	}
+}
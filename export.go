@@ -0,0 +1,260 @@
+package editor
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+/*-----------------------------------------------------------------------------
+ * Export
+ *
+ * The "export_html" action writes the buffer, highlighted as in code fences
+ * of the Markdown preview, to a standalone HTML file. A name ending in .ans
+ * gets the text with ANSI colour escapes instead, for pasting into a terminal.
+ *
+ * The "export_selection" action writes the selection highlighted the same
+ * way as RTF, for word processors and chat, or ANSI text, to a file or the
+ * clipboard. Files ending in .rtf get RTF and other files ANSI text.
+ */
+
+/* exportColors maps the SGR parameters of highlighted code to CSS. */
+var exportColors = map[string]string{
+	"1;34": "color:#268bd2;font-weight:bold", // keyword
+	"32":   "color:#859900",                  // string
+	"36":   "color:#2aa198",                  // number
+	"2":    "color:#93a1a1",                  // comment
+}
+
+/* rtfColors are the colours of the RTF colour table, after the default one, for the SGR parameters of highlighted code. */
+var rtfColors = []struct {
+	style   string
+	r, g, b int
+	bold    bool
+}{
+	{"1;34", 0x26, 0x8b, 0xd2, true}, // keyword
+	{"32", 0x85, 0x99, 0x00, false},  // string
+	{"36", 0x2a, 0xa1, 0x98, false},  // number
+	{"2", 0x93, 0xa1, 0xa1, false},   // comment
+}
+
+/* codeLang returns the language of the file name for highlighting, "" if it is not code the highlighter knows. */
+func codeLang(name string) string {
+	lang := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	if commentStart(lang) == "" {
+		return ""
+	}
+	return lang
+}
+
+/* highlightedLines returns the lines of the buffer highlighted for the language of its file. */
+func (e *Editor) highlightedLines() [][]mdSpan {
+	lang := codeLang(e.buf.fileName)
+	out := make([][]mdSpan, len(e.buf.lines))
+	for i, l := range e.buf.lines {
+		if lang == "" {
+			out[i] = []mdSpan{{text: string(l.chars)}}
+		} else {
+			out[i] = highlightCode(string(l.chars), lang)
+		}
+	}
+	return out
+}
+
+func exportHTML(title string, lines [][]mdSpan) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(title))
+	sb.WriteString("<pre style=\"font-family:monospace;tab-size:4\">")
+	for _, l := range lines {
+		for _, s := range l {
+			text := html.EscapeString(s.text)
+			if css, ok := exportColors[s.style]; ok {
+				fmt.Fprintf(&sb, "<span style=\"%s\">%s</span>", css, text)
+			} else {
+				sb.WriteString(text)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</pre>\n</body>\n</html>\n")
+	return sb.String()
+}
+
+func exportANSI(lines [][]mdSpan) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		for _, s := range l {
+			if s.style == "" {
+				sb.WriteString(s.text)
+			} else {
+				sb.WriteString(sgr(s.style) + s.text + "\x1b[m")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (e *Editor) exportBuffer() {
+	base := e.buf.fileName
+	if base == "" {
+		base = "buffer"
+	}
+	def := filepath.Base(base) + ".html"
+
+	name, ok := e.Prompt(fmt.Sprintf("Export to (%s): ", def), nil, nil)
+	if !ok {
+		return
+	}
+	if name == "" {
+		name = def
+	}
+
+	lines := e.highlightedLines()
+	var data string
+	if strings.HasSuffix(name, ".ans") {
+		data = exportANSI(lines)
+	} else {
+		data = exportHTML(filepath.Base(base), lines)
+	}
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		e.setStatusMsg("export: %s", err)
+		return
+	}
+	e.setStatusMsg("exported to %s", name)
+}
+
+/* rtfEscape escapes text for RTF, writing characters past ASCII as \u escapes. */
+func rtfEscape(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '{' || r == '}':
+			sb.WriteString("\\" + string(r))
+		case r == '\t':
+			sb.WriteString("\\tab ")
+		case r < 0x80:
+			sb.WriteRune(r)
+		case r < 0x10000:
+			fmt.Fprintf(&sb, "\\u%d?", int16(r))
+		default:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&sb, "\\u%d?\\u%d?", int16(r1), int16(r2))
+		}
+	}
+	return sb.String()
+}
+
+func exportRTF(lines [][]mdSpan) string {
+	var sb strings.Builder
+	sb.WriteString("{\\rtf1\\ansi\\deff0{\\fonttbl{\\f0\\fmodern Courier New;}}{\\colortbl;")
+	for _, c := range rtfColors {
+		fmt.Fprintf(&sb, "\\red%d\\green%d\\blue%d;", c.r, c.g, c.b)
+	}
+	sb.WriteString("}\n\\f0\\fs20 ")
+	for i, l := range lines {
+		if i > 0 {
+			sb.WriteString("\\line\n")
+		}
+		for _, s := range l {
+			text := rtfEscape(s.text)
+			styled := false
+			for n, c := range rtfColors {
+				if c.style != s.style {
+					continue
+				}
+				bold := ""
+				if c.bold {
+					bold = "\\b"
+				}
+				fmt.Fprintf(&sb, "{\\cf%d%s %s}", n+1, bold, text)
+				styled = true
+			}
+			if !styled {
+				sb.WriteString(text)
+			}
+		}
+	}
+	sb.WriteString("\n}\n")
+	return sb.String()
+}
+
+/* clipSpans returns the part of the spans of a line from character lo up to hi. */
+func clipSpans(spans []mdSpan, lo, hi int) []mdSpan {
+	out := []mdSpan{}
+	x := 0
+	for _, s := range spans {
+		text := []rune(s.text)
+		a, b := lo-x, hi-x
+		x += len(text)
+		if a < 0 {
+			a = 0
+		}
+		if b > len(text) {
+			b = len(text)
+		}
+		if a < b {
+			out = append(out, mdSpan{text: string(text[a:b]), style: s.style})
+		}
+	}
+	return out
+}
+
+func (e *Editor) exportSelection() {
+	a, c, ok := e.buf.selection()
+	if !ok {
+		e.setStatusMsg("Select the text first, set_mark starts a selection")
+		e.bell()
+		return
+	}
+	name, ok := e.historyPrompt(fileHistory, "Export selection to (empty for the clipboard): ", nil, completePath)
+	if !ok {
+		return
+	}
+
+	all := e.highlightedLines()
+	lines := [][]mdSpan{}
+	for y := a.y; y <= c.y && y < len(all); y++ {
+		lo, hi := 0, len(e.buf.lines[y].chars)
+		if y == a.y {
+			lo = a.x
+		}
+		if y == c.y {
+			hi = c.x
+		}
+		lines = append(lines, clipSpans(all[y], lo, hi))
+	}
+
+	rtf := strings.HasSuffix(strings.ToLower(name), ".rtf")
+	if name == "" {
+		i, ok := e.Pick("Copy the selection as", []string{"RTF, for word processors and chat", "ANSI text, for terminals"})
+		if !ok {
+			return
+		}
+		rtf = i == 0
+	}
+	var data, mime string
+	if rtf {
+		data, mime = exportRTF(lines), "text/rtf"
+	} else {
+		data, mime = strings.TrimSuffix(exportANSI(lines), "\n"), "text/plain"
+	}
+
+	if name != "" {
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			e.setStatusMsg("export: %s", err)
+			return
+		}
+		e.setStatusMsg("exported the selection to %s", name)
+		return
+	}
+	how, err := e.copyToClipboard(data, mime)
+	if err != nil {
+		e.setStatusMsg("export: %s", err)
+		return
+	}
+	e.setStatusMsg("copied the selection with %s", how)
+}
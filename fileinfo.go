@@ -0,0 +1,130 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * File information
+ *
+ * The "file_info" action, ctrl+g by default, shows the path, size,
+ * permissions, modification time, encoding and line endings of the file of
+ * the current buffer, with its line count and where the cursor is. The size,
+ * encoding and line endings are those of the file as last saved, and are
+ * only known for local files.
+ */
+
+const fileInfoSniff = 1 << 20 // bytes read to tell the encoding and line endings
+
+/* formatSize returns n bytes in a readable form. */
+func formatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	units := "KMGTPE"
+	v, i := float64(n)/1024, 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%d bytes (%.1f %ciB)", n, v, units[i])
+}
+
+/* textEncoding guesses the encoding of the start of a file. */
+func textEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xef\xbb\xbf")):
+		return "UTF-8 with BOM"
+	case bytes.HasPrefix(data, []byte("\xff\xfe")):
+		return "UTF-16 LE"
+	case bytes.HasPrefix(data, []byte("\xfe\xff")):
+		return "UTF-16 BE"
+	case bytes.IndexByte(data, 0) >= 0:
+		return "binary"
+	}
+	for len(data) > 0 && !utf8.FullRune(data) {
+		data = data[:len(data)-1] // a character cut off by the sniff
+	}
+	ascii := true
+	for _, c := range data {
+		if c >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	switch {
+	case ascii:
+		return "ASCII"
+	case utf8.Valid(data):
+		return "UTF-8"
+	}
+	return "unknown, not UTF-8"
+}
+
+/* lineEndings tells the line endings used in data. */
+func lineEndings(data []byte) string {
+	crlf := bytes.Count(data, []byte("\r\n"))
+	lf := bytes.Count(data, []byte("\n")) - crlf
+	switch {
+	case crlf == 0 && lf == 0:
+		return "none"
+	case crlf == 0:
+		return "LF"
+	case lf == 0:
+		return "CRLF"
+	}
+	return fmt.Sprintf("mixed, %d LF and %d CRLF", lf, crlf)
+}
+
+func (e *Editor) fileInfo() {
+	b := e.buf
+	info := [][2]string{{"Path", bufferPath(b)}}
+	if b.fileName == "" {
+		info[0][1] = bufferTitle(b) + ", not saved"
+	}
+
+	if _, remote := parseRemotePath(b.fileName); !remote && !isURL(b.fileName) && b.fileName != "" {
+		if fi, err := os.Stat(b.fileName); err != nil {
+			info = append(info, [2]string{"File", "not saved yet"})
+		} else {
+			info = append(info,
+				[2]string{"Size", formatSize(fi.Size())},
+				[2]string{"Permissions", fi.Mode().String()},
+				[2]string{"Modified", fi.ModTime().Format("2006-01-02 15:04:05")})
+			if f, err := os.Open(b.fileName); err == nil {
+				data, _ := io.ReadAll(io.LimitReader(f, fileInfoSniff))
+				f.Close()
+				info = append(info,
+					[2]string{"Encoding", textEncoding(data)},
+					[2]string{"Line endings", lineEndings(data)})
+			}
+		}
+	}
+	if b.compression != nil {
+		info = append(info, [2]string{"Compression", b.compression.name})
+	}
+
+	lines := len(b.allLines())
+	state := "unmodified"
+	if b.dirty {
+		state = "modified"
+	}
+	info = append(info, [2]string{"Lines", fmt.Sprintf("%d, %s", lines, state)})
+
+	y := b.cursor.y + b.hiddenAbove()
+	percent := 100
+	if lines > 0 && y < lines {
+		percent = (y + 1) * 100 / lines
+	}
+	info = append(info, [2]string{"Cursor", fmt.Sprintf("line %d, column %d, %d%%", y+1, b.cursor.x+1, percent)})
+
+	rows := make([]string, len(info))
+	for i, kv := range info {
+		rows[i] = fmt.Sprintf("%-12s  %s", kv[0], kv[1])
+	}
+	e.ShowPopup("File info", rows)
+}
@@ -0,0 +1,208 @@
+package editor
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+/*-----------------------------------------------------------------------------
+ * Fill
+ *
+ * The "fill_paragraph" action, alt+q, rewraps the paragraph around the cursor,
+ * or the paragraphs of the selected lines, so that no line is wider than the
+ * textwidth setting, 80 columns unless set. Paragraphs end at blank lines, at
+ * lines with another comment marker and before list items. The indentation
+ * and comment marker, such as "// ", "# " or " * ", of the first line start
+ * the first line of the result and those of the second line the others, or
+ * for a list item the first line's indented past the item marker. A word
+ * wider than the textwidth gets a line of its own.
+ */
+
+/* commentMarkers are the comment markers kept at the start of filled lines, longest first. */
+var commentMarkers = []string{"//", "--", "#", ";", "%", ">", "*"}
+
+var listItemRx = regexp.MustCompile(`^([-+*]|[0-9]+[.)])[ \t]+`)
+
+/* linePrefix returns the indentation and comment marker, with the space after it, that line starts with. */
+func linePrefix(line string) string {
+	rest := strings.TrimLeft(line, " \t")
+	for _, m := range commentMarkers {
+		if !strings.HasPrefix(rest, m) || (m == "*" && rest == line) {
+			continue // a * at the start of the line is a list item
+		}
+		for strings.HasPrefix(rest, m) {
+			rest = rest[len(m):]
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		break
+	}
+	return line[:len(line)-len(rest)]
+}
+
+/* lineMarker returns the comment marker of line without the space around it. */
+func lineMarker(line string) string {
+	return strings.TrimSpace(linePrefix(line))
+}
+
+/* blankLine reports whether line has nothing but its prefix. */
+func blankLine(line string) bool {
+	return strings.TrimSpace(line[len(linePrefix(line)):]) == ""
+}
+
+/* startsParagraph reports whether line i of lines starts a paragraph. */
+func startsParagraph(lines []string, i int) bool {
+	if i == 0 || blankLine(lines[i-1]) || blankLine(lines[i]) {
+		return true
+	}
+	return lineMarker(lines[i]) != lineMarker(lines[i-1]) ||
+		listItemRx.MatchString(lines[i][len(linePrefix(lines[i])):])
+}
+
+/* fillLines fills the paragraphs of lines to width columns, keeping the blank lines. */
+func (e *Editor) fillLines(lines []string, width int) []string {
+	out := []string{}
+	for start := 0; start < len(lines); {
+		end := start + 1
+		for end < len(lines) && !startsParagraph(lines, end) {
+			end++
+		}
+		if blankLine(lines[start]) {
+			out = append(out, lines[start:end]...)
+		} else {
+			out = append(out, e.fillParagraph(lines[start:end], width)...)
+		}
+		start = end
+	}
+	return out
+}
+
+/* fillParagraph rewraps the words of the paragraph lines to width columns. */
+func (e *Editor) fillParagraph(lines []string, width int) []string {
+	first := linePrefix(lines[0])
+	rest := first
+	if m := listItemRx.FindString(lines[0][len(first):]); m != "" {
+		rest = hangingIndent(first, m)
+	} else if len(lines) > 1 {
+		rest = linePrefix(lines[1])
+	}
+
+	words := []string{}
+	for _, l := range lines {
+		words = append(words, strings.Fields(l[len(linePrefix(l)):])...)
+	}
+
+	out := []string{}
+	var sb strings.Builder
+	col, prefix := 0, first
+	for _, w := range words {
+		ww := len(e.updateRow([]rune(w)))
+		if sb.Len() > 0 && col+1+ww > width {
+			out = append(out, sb.String())
+			sb.Reset()
+			prefix = rest
+		}
+		if sb.Len() == 0 {
+			sb.WriteString(prefix + w)
+			col = len(e.updateRow([]rune(prefix))) + ww
+			continue
+		}
+		sb.WriteString(" " + w)
+		col += 1 + ww
+	}
+	return append(out, sb.String())
+}
+
+/* hangingIndent returns the prefix of the lines after a list item that starts with prefix and the item marker m. */
+func hangingIndent(prefix, m string) string {
+	return strings.TrimRight(prefix, " \t") + strings.Repeat(" ", len(prefix)-len(strings.TrimRight(prefix, " \t"))+len(m))
+}
+
+/* wordsBefore counts the characters of lines up to x on line y that are not space or a prefix. */
+func wordsBefore(lines []string, y, x int) int {
+	n := 0
+	for i := 0; i <= y && i < len(lines); i++ {
+		chars := []rune(lines[i])
+		lo, hi := len([]rune(linePrefix(lines[i]))), len(chars)
+		if i == y && x < hi {
+			hi = x
+		}
+		for j := lo; j < hi; j++ {
+			if !unicode.IsSpace(chars[j]) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+/* wordsPoint returns the position in lines after n characters that are not space or a prefix. */
+func wordsPoint(lines []string, n int) point {
+	for y, l := range lines {
+		chars := []rune(l)
+		for x := len([]rune(linePrefix(l))); x < len(chars); x++ {
+			if unicode.IsSpace(chars[x]) {
+				continue
+			}
+			if n == 0 {
+				return point{x: x, y: y}
+			}
+			n--
+		}
+	}
+	last := len(lines) - 1
+	return point{x: len([]rune(lines[last])), y: last}
+}
+
+func (e *Editor) fillParagraphAction() {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	first, last, ok := e.buf.selectedLines()
+	if !ok {
+		y := e.buf.cursor.y
+		if y >= len(e.buf.lines) || blankLine(string(e.buf.lines[y].chars)) {
+			e.setStatusMsg("No paragraph at the cursor")
+			e.bell()
+			return
+		}
+		all := make([]string, len(e.buf.lines))
+		for i, l := range e.buf.lines {
+			all[i] = string(l.chars)
+		}
+		first, last = y, y
+		for !startsParagraph(all, first) {
+			first--
+		}
+		for last+1 < len(all) && !startsParagraph(all, last+1) {
+			last++
+		}
+	}
+	if !e.canEdit(first, last) {
+		return
+	}
+
+	lines := make([]string, 0, last-first+1)
+	for y := first; y <= last; y++ {
+		lines = append(lines, string(e.buf.lines[y].chars))
+	}
+	filled := e.fillLines(lines, e.textWidth)
+	if strings.Join(filled, "\n") == strings.Join(lines, "\n") {
+		e.setStatusMsg("Already filled")
+		return
+	}
+
+	cur := e.buf.cursor
+	n := -1
+	if cur.y >= first && cur.y <= last {
+		n = wordsBefore(lines, cur.y-first, cur.x)
+	}
+	e.replaceRange(point{y: first}, point{x: len(e.buf.lines[last].chars), y: last}, strings.Join(filled, "\n"))
+	if n >= 0 {
+		p := wordsPoint(filled, n)
+		e.setCursor(point{x: p.x, y: first + p.y})
+	}
+	e.snapCursor()
+}
@@ -0,0 +1,69 @@
+package editor
+
+import (
+	"os"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Focus
+ *
+ * The terminal is asked to report when it gains and loses focus. Gaining focus
+ * reloads a file changed on disk by another program, or warns about it when
+ * the buffer has changes of its own, runs the FocusGained hook and redraws so
+ * status providers show fresh information. Losing focus runs FocusLost and
+ * autosaves.
+ */
+
+func (e *Editor) setFocusReporting(on bool) {
+	if on {
+		e.term.Write([]byte("\x1b[?1004h"))
+	} else {
+		e.term.Write([]byte("\x1b[?1004l"))
+	}
+}
+
+/* localModTime returns the modification time of a local file, or the zero time for remote files and URLs. */
+func localModTime(name string) time.Time {
+	if _, remote := parseRemotePath(name); remote || isURL(name) || name == "" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+func (e *Editor) focusChanged(in bool) {
+	if in {
+		e.checkChangedOnDisk()
+		e.runHook(FocusGained)
+		return
+	}
+	e.runHook(FocusLost)
+	e.autosaveBuffers()
+}
+
+/* checkChangedOnDisk reloads the file of the current buffer if another program has written it. */
+func (e *Editor) checkChangedOnDisk() {
+	b := e.buf
+	if b.modTime.IsZero() || b.sink != nil {
+		return
+	}
+	mt := localModTime(b.fileName)
+	if mt.IsZero() || mt.Equal(b.modTime) {
+		return
+	}
+	if b.dirty {
+		b.modTime = mt // warn once per change
+		e.setStatusMsg("%s has changed on disk, revert to load it", b.fileName)
+		e.bell()
+		return
+	}
+	if err := e.reload(); err != nil {
+		e.setStatusMsg("reload: %s", err)
+		return
+	}
+	e.setStatusMsg("%s has changed on disk and was reloaded", b.fileName)
+}
@@ -0,0 +1,124 @@
+package editor
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Ignore rules
+ *
+ * A subset of .gitignore: comments, negation with !, patterns for directories
+ * only ending in /, patterns anchored to their directory when they contain a
+ * /, and the *, ?, [...] and ** wildcards.
+ */
+
+type ignoreRule struct {
+	base    string         // directory of the .gitignore, slash separated and relative to the root
+	re      *regexp.Regexp // matches the path relative to base, or the name when the pattern has no /
+	anchor  bool           // the pattern contains a /
+	neg     bool           // the pattern starts with !
+	dirOnly bool           // the pattern ends with /
+}
+
+type ignoreRules []ignoreRule
+
+/* loadIgnoreFile adds the rules of the .gitignore in dir, which is rel from the root, to r. */
+func (r ignoreRules) loadIgnoreFile(dir, rel string) ignoreRules {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return r
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		p := strings.TrimRight(scanner.Text(), " ")
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		rule := ignoreRule{base: rel}
+		if strings.HasPrefix(p, "!") {
+			rule.neg, p = true, p[1:]
+		}
+		p = strings.TrimPrefix(p, "\\")
+		if strings.HasSuffix(p, "/") {
+			rule.dirOnly, p = true, strings.TrimSuffix(p, "/")
+		}
+		if strings.Contains(p, "/") {
+			rule.anchor, p = true, strings.TrimPrefix(p, "/")
+		}
+		re, err := regexp.Compile("^" + globToRegexp(p) + "$")
+		if err != nil {
+			continue
+		}
+		rule.re = re
+		r = append(r, rule)
+	}
+	return r
+}
+
+/* globToRegexp translates a gitignore glob to a regular expression. */
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**"):
+			sb.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			if j := strings.IndexByte(glob[i:], ']'); j > 0 {
+				class := glob[i+1 : i+j]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				sb.WriteString("[" + class + "]")
+				i += j
+			} else {
+				sb.WriteString(`\[`)
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+/* ignored reports whether the path rel, slash separated and relative to the root, is ignored. */
+func (r ignoreRules) ignored(rel string, dir bool) bool {
+	ignored := false
+	for _, rule := range r {
+		if rule.dirOnly && !dir {
+			continue
+		}
+		sub := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			sub = rel[len(rule.base)+1:]
+		}
+		if !rule.anchor {
+			sub = path.Base(sub)
+		}
+		if rule.re.MatchString(sub) {
+			ignored = !rule.neg
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,113 @@
+package editor
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Git status
+ *
+ * With the git_status setting, the status bar shows the git branch of the
+ * current file, with a * when the work tree has changes. It is refreshed in
+ * the background when the typing pauses, at most every gitStatusInterval, so
+ * a slow repository never holds up a key press.
+ */
+
+const gitStatusInterval = 5 * time.Second
+
+type gitStatus struct {
+	seg     *StatusSegment
+	on      bool      // the status is shown
+	dir     string    // directory the status was last asked for in
+	asked   time.Time // when it was last asked for
+	running bool      // git is running
+}
+
+/* parseGitStatus returns what the status bar shows for the output of git status --porcelain -b. */
+func parseGitStatus(out string) string {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	head, ok := strings.CutPrefix(lines[0], "## ")
+	if !ok {
+		return ""
+	}
+	head, _, _ = strings.Cut(head, "...")
+	head, _, _ = strings.Cut(head, " ")
+	if h, ok := strings.CutPrefix(lines[0], "## No commits yet on "); ok {
+		head = h
+	}
+	if head == "HEAD" {
+		head = "detached"
+	}
+	if len(lines) > 1 {
+		head += "*"
+	}
+	return "git " + head
+}
+
+/* gitStatusDir returns the directory the git status of the current buffer is taken in, "" if it has none. */
+func (e *Editor) gitStatusDir() string {
+	name := e.buf.fileName
+	if name == "" {
+		return "."
+	}
+	if _, remote := parseRemotePath(name); remote || isURL(name) {
+		return ""
+	}
+	return filepath.Dir(name)
+}
+
+/* refreshGitStatus runs git status in the background to update the status bar. */
+func (e *Editor) refreshGitStatus() {
+	g := e.gitStatus
+	if g == nil || !g.on || g.running {
+		return
+	}
+	dir := e.gitStatusDir()
+	if dir == g.dir && time.Since(g.asked) < gitStatusInterval {
+		return
+	}
+	g.dir, g.asked = dir, time.Now()
+	if dir == "" {
+		g.seg.Set("")
+		return
+	}
+	g.running = true
+	ctx := e.ctx
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, gitStatusInterval)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "git", "status", "--porcelain", "-b", "--untracked-files=no")
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		text := ""
+		if err == nil {
+			text = parseGitStatus(string(out))
+		}
+		e.Do(func() {
+			g.running = false
+			if g.on {
+				g.seg.Set(text)
+			}
+		})
+	}()
+}
+
+/* setGitStatus shows or hides the git status in the status bar. */
+func (e *Editor) setGitStatus(on bool) {
+	g := e.gitStatus
+	if g == nil {
+		if !on {
+			return
+		}
+		g = &gitStatus{seg: e.AddStatusSegment()}
+		e.gitStatus = g
+	}
+	g.on, g.dir = on, ""
+	if !on {
+		g.seg.Set("")
+	}
+}
@@ -2,4 +2,12 @@ module github.com/pergus/editor
 
 go 1.20
 
-require golang.org/x/sys v0.8.0
+require (
+	github.com/klauspost/compress v1.16.7
+	github.com/pkg/sftp v1.13.5
+	github.com/yuin/gopher-lua v1.1.1
+	golang.org/x/crypto v0.9.0
+	golang.org/x/sys v0.8.0
+)
+
+require github.com/kr/fs v0.1.0 // indirect
@@ -0,0 +1,95 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Go to
+ *
+ * The "goto_line" action prompts for a line, "120" or "120:8" with a column,
+ * or for a position in the buffer as a percentage, "50%". Line numbers are
+ * the ones in the status bar, also when the buffer is narrowed. "goto_top"
+ * and "goto_bottom" go to the first and last line, and
+ * "goto_middle_of_screen" to the line in the middle of the screen.
+ */
+
+/* parseGoto parses the input of the goto_line prompt into a zero based line of lines shown lines, hidden lines above, and a column. */
+func parseGoto(s string, lines, hidden int) (point, error) {
+	s = strings.TrimSpace(s)
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		n, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || n < 0 || n > 100 {
+			return point{}, fmt.Errorf("not a percentage from 0 to 100")
+		}
+		y := int(n * float64(lines) / 100)
+		if y >= lines {
+			y = lines - 1
+		}
+		return point{y: y}, nil
+	}
+
+	ls, cs, hasCol := strings.Cut(s, ":")
+	n, err := strconv.Atoi(strings.TrimSpace(ls))
+	if err != nil || n < 1 {
+		return point{}, fmt.Errorf("not a line number")
+	}
+	p := point{y: n - 1 - hidden}
+	if hasCol {
+		c, err := strconv.Atoi(strings.TrimSpace(cs))
+		if err != nil || c < 1 {
+			return point{}, fmt.Errorf("not a column number")
+		}
+		p.x = c - 1
+	}
+	if p.y < 0 || p.y >= lines {
+		return point{}, fmt.Errorf("line %d is not shown", n)
+	}
+	return p, nil
+}
+
+func (e *Editor) gotoLine() {
+	if len(e.buf.lines) == 0 {
+		e.setStatusMsg("buffer is empty")
+		return
+	}
+	hidden := e.buf.hiddenAbove()
+	validate := func(s string) error {
+		_, err := parseGoto(s, len(e.buf.lines), hidden)
+		return err
+	}
+	input, ok := e.Prompt(fmt.Sprintf("Go to line (%d-%d or 0-100%%): ", hidden+1, hidden+len(e.buf.lines)), validate, nil)
+	if !ok {
+		return
+	}
+	p, _ := parseGoto(input, len(e.buf.lines), hidden)
+	e.setCursor(p)
+	e.snapCursor()
+}
+
+func (e *Editor) gotoTop() {
+	e.setCursor(point{})
+}
+
+func (e *Editor) gotoBottom() {
+	y := len(e.buf.lines) - 1
+	if y < 0 {
+		y = 0
+	}
+	e.setCursor(point{y: y})
+}
+
+func (e *Editor) gotoMiddleOfScreen() {
+	shown := len(e.buf.lines) - e.buf.fileY
+	if shown > e.termRows {
+		shown = e.termRows
+	}
+	y := e.buf.fileY
+	if shown > 0 {
+		y += (shown - 1) / 2
+	}
+	e.setCursor(point{y: y})
+	e.snapCursor()
+}
@@ -0,0 +1,258 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * Project search and the quickfix list
+ *
+ * The "grep" action searches the files of the project, the git work tree the
+ * current directory is in or else the current directory, for a regular
+ * expression. Files are read by a pool of GOMAXPROCS workers, skipping binary
+ * files and what .gitignore ignores, and the matches are added to the
+ * quickfix list as they are found. The "quickfix" action picks a match to go
+ * to, and "quickfix_next" and "quickfix_prev" step through them.
+ */
+
+const grepMaxFileSize = 16 << 20 // bigger files are not searched
+
+type quickfixEntry struct {
+	file string // as shown, relative to the project root
+	path string // to open
+	line int    // zero based
+	col  int    // zero based, in characters
+	text string
+}
+
+/* projectRoot returns the top of the git work tree dir is in, or dir. */
+func projectRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+func (e *Editor) stopGrep() {
+	if e.grepCancel != nil {
+		e.grepCancel()
+		e.grepCancel = nil
+	}
+}
+
+func (e *Editor) grep() {
+	query, ok := e.historyPrompt(searchHistory, "Grep: ", func(s string) error {
+		_, err := regexp.Compile(s)
+		return err
+	}, nil)
+	if !ok || query == "" {
+		return
+	}
+	re := regexp.MustCompile(query)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		e.setStatusMsg("grep: %s", err)
+		return
+	}
+	e.startGrep(projectRoot(cwd), re)
+}
+
+/* startGrep replaces the quickfix list with the matches of re in the files under root, found in the background. */
+func (e *Editor) startGrep(root string, re *regexp.Regexp) {
+	e.stopGrep()
+	ctx, cancel := context.WithCancel(e.ctx)
+	e.grepCancel = cancel
+	e.quickfix = nil
+	e.quickfixPos = -1
+	e.grepGen++
+	gen := e.grepGen
+
+	/* post runs fn on the main loop unless the search has been stopped */
+	post := func(fn func()) bool {
+		select {
+		case e.tasks <- func() {
+			if e.grepGen == gen {
+				fn()
+			}
+		}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	files := make(chan string, 256)
+	var wg sync.WaitGroup
+	var matchedFiles, matches int
+	var mu sync.Mutex
+
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range files {
+				found := grepFile(root, path, re)
+				if len(found) == 0 {
+					continue
+				}
+				mu.Lock()
+				matchedFiles++
+				matches += len(found)
+				n := matches
+				mu.Unlock()
+				if !post(func() {
+					e.quickfix = append(e.quickfix, found...)
+					e.setStatusMsg("grep: %d matches so far", n)
+				}) {
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		rules := ignoreRules{}.loadIgnoreFile(root, "")
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if path == root {
+				return nil
+			}
+			rel := filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+			if d.IsDir() {
+				if d.Name() == ".git" || rules.ignored(rel, true) {
+					return filepath.SkipDir
+				}
+				rules = rules.loadIgnoreFile(path, rel)
+				return nil
+			}
+			if !d.Type().IsRegular() || rules.ignored(rel, false) {
+				return nil
+			}
+			select {
+			case files <- path:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+		close(files)
+		wg.Wait()
+
+		post(func() {
+			e.setStatusMsg("grep: %d matches in %d files", matches, matchedFiles)
+			e.grepCancel = nil
+		})
+		cancel()
+	}()
+
+	e.setStatusMsg("grep: searching %s ...", root)
+}
+
+/* grepFile returns the matches of re in the file path, nothing for binary files. */
+func grepFile(root, path string, re *regexp.Regexp) []quickfixEntry {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Size() > grepMaxFileSize {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil // binary
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	found := []quickfixEntry{}
+	for n, l := range strings.Split(string(data), "\n") {
+		if loc := re.FindStringIndex(l); loc != nil {
+			found = append(found, quickfixEntry{
+				file: rel,
+				path: path,
+				line: n,
+				col:  utf8.RuneCountInString(l[:loc[0]]),
+				text: strings.TrimRight(l, "\r"),
+			})
+		}
+	}
+	return found
+}
+
+/* gotoQuickfix opens the file of entry i of the quickfix list at its match. */
+func (e *Editor) gotoQuickfix(i int) {
+	q := e.quickfix[i]
+	if err := e.openBuffer(q.path); err != nil {
+		e.setStatusMsg("%s: %s", q.file, err)
+		return
+	}
+	e.quickfixPos = i
+	e.widen()
+	e.setCursor(point{x: q.col, y: q.line})
+	e.snapCursor()
+	e.setStatusMsg("(%d of %d) %s:%d: %s", i+1, len(e.quickfix), q.file, q.line+1, strings.TrimSpace(q.text))
+}
+
+func (e *Editor) showQuickfix() {
+	if len(e.quickfix) == 0 {
+		e.setStatusMsg("The quickfix list is empty")
+		e.bell()
+		return
+	}
+	items := make([]string, len(e.quickfix))
+	for i, q := range e.quickfix {
+		items[i] = fmt.Sprintf("%s:%d: %s", q.file, q.line+1, strings.TrimSpace(strings.ReplaceAll(q.text, "\t", " ")))
+	}
+	if i, ok := e.Pick("Quickfix", items); ok {
+		e.gotoQuickfix(i)
+	}
+}
+
+func (e *Editor) quickfixNext() {
+	if e.quickfixPos+1 >= len(e.quickfix) {
+		e.setStatusMsg("No more matches")
+		e.bell()
+		return
+	}
+	e.gotoQuickfix(e.quickfixPos + 1)
+}
+
+func (e *Editor) quickfixPrev() {
+	if e.quickfixPos <= 0 {
+		e.setStatusMsg("No earlier matches")
+		e.bell()
+		return
+	}
+	e.gotoQuickfix(e.quickfixPos - 1)
+}
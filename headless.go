@@ -0,0 +1,285 @@
+package editor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * Headless terminal
+ */
+
+// Headless is an in-memory Terminal for running the editor without a real
+// terminal, for example in tests. Keys queued with Type are returned by
+// ReadKey and everything the editor draws is interpreted into a matrix of
+// screen cells.
+type Headless struct {
+	mu      sync.Mutex
+	bursts  [][]byte // queued input, the editor sees a pause between bursts
+	pasted  []bool   // whether each burst arrives at once, as pasted text
+	keyEsc  int      // 1 after an escape read, 2 within an escape sequence
+	keyTail int      // continuation bytes of a UTF-8 character still to be read
+	rows    int
+	cols    int
+	cells   [][]rune
+	cy, cx  int      // screen cursor
+	esc     []byte   // unfinished escape sequence
+	screen  []string // the screen the last time the editor waited for input
+}
+
+var errHeadlessDone = errors.New("no more keys")
+
+// NewHeadless returns a headless terminal with rows rows and cols columns.
+func NewHeadless(rows, cols int) *Headless {
+	h := &Headless{}
+	h.Resize(rows, cols)
+	return h
+}
+
+// Type queues keys as one burst of input. Every call to Type is separated
+// from the previous one by a pause, so a lone "\x1b" is read as the escape
+// key rather than the start of an escape sequence. The keys of a burst come
+// one at a time, as typed, rather than all at once as pasted text does.
+func (h *Headless) Type(keys string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bursts = append(h.bursts, []byte(keys))
+	h.pasted = append(h.pasted, false)
+}
+
+// Paste queues text as one burst of input arriving all at once, as from a
+// terminal pasting without bracketed paste.
+func (h *Headless) Paste(text string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bursts = append(h.bursts, []byte(text))
+	h.pasted = append(h.pasted, true)
+}
+
+// Resize changes the size of the screen, which clears it.
+func (h *Headless) Resize(rows, cols int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rows, h.cols = rows, cols
+	h.cells = make([][]rune, rows)
+	for y := range h.cells {
+		h.cells[y] = []rune(strings.Repeat(" ", cols))
+	}
+	h.cy, h.cx = 0, 0
+}
+
+// Screen returns the rows of the screen, without trailing spaces, as they
+// were the last time the editor waited for input.
+func (h *Headless) Screen() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string{}, h.screen...)
+}
+
+// Run runs an editor configured by opts on source until it quits or the
+// queued keys are used up, and returns the text of the current buffer.
+func (h *Headless) Run(source interface{}, opts ...Option) (string, error) {
+	e := New(append(opts, WithTerminal(h))...)
+	e.isolated = true
+
+	err := e.Run(context.Background(), source)
+	if errors.Is(err, errHeadlessDone) {
+		err = nil
+	}
+	return e.linesToString(), err
+}
+
+func (h *Headless) ReadKey() (byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.bursts) == 0 {
+		h.snapshot()
+		return 0, errHeadlessDone
+	}
+	if len(h.bursts[0]) == 0 {
+		h.bursts, h.pasted = h.bursts[1:], h.pasted[1:]
+		h.snapshot()
+		return 0, ErrNoInput
+	}
+
+	k := h.bursts[0][0]
+	h.bursts[0] = h.bursts[0][1:]
+	h.trackKey(k)
+	return k, nil
+}
+
+/* trackKey notes whether the byte k leaves a key, an escape sequence or a UTF-8 character, unfinished. */
+func (h *Headless) trackKey(k byte) {
+	switch {
+	case h.keyTail > 0:
+		h.keyTail--
+	case h.keyEsc == 1 && (k == '[' || k == 'O'):
+		h.keyEsc = 2
+	case h.keyEsc == 1 || (h.keyEsc == 2 && k >= 0x40 && k <= 0x7e):
+		h.keyEsc = 0
+	case h.keyEsc == 2:
+	case k == '\x1b':
+		h.keyEsc = 1
+	case k >= 0xf0:
+		h.keyTail = 3
+	case k >= 0xe0:
+		h.keyTail = 2
+	case k >= 0xc0:
+		h.keyTail = 1
+	}
+}
+
+/*
+WaitKey reports whether there is more input in the current burst. Asked for
+input that is already waiting, with d zero, it reports the rest of typed keys
+only while a key is unfinished, as a terminal would, and pasted text always.
+*/
+func (h *Headless) WaitKey(d time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.bursts) == 0 || len(h.bursts[0]) == 0 {
+		return false
+	}
+	return d > 0 || h.pasted[0] || h.keyEsc > 0 || h.keyTail > 0
+}
+
+func (h *Headless) Size() (int, int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rows, h.cols, nil
+}
+
+func (h *Headless) RawMode(on bool) error {
+	return nil
+}
+
+func (h *Headless) snapshot() {
+	h.screen = make([]string, h.rows)
+	for y, row := range h.cells {
+		h.screen[y] = strings.TrimRight(string(row), " ")
+	}
+}
+
+/* Write interprets the subset of VT100 output the editor produces. */
+func (h *Headless) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data := append(h.esc, p...)
+	h.esc = nil
+
+	for len(data) > 0 {
+		switch data[0] {
+		case '\x1b':
+			n := h.escape(data)
+			if n == 0 {
+				h.esc = append([]byte{}, data...) // wait for the rest of the sequence
+				return len(p), nil
+			}
+			data = data[n:]
+		case '\r':
+			h.cx = 0
+			data = data[1:]
+		case '\n':
+			if h.cy < h.rows-1 {
+				h.cy++
+			}
+			data = data[1:]
+		default:
+			r, n := utf8.DecodeRune(data)
+			if h.cy < h.rows && h.cx < h.cols {
+				h.cells[h.cy][h.cx] = r
+			}
+			h.cx++
+			data = data[n:]
+		}
+	}
+	return len(p), nil
+}
+
+/* escape handles the escape sequence at the start of data and returns its length, or 0 if it is incomplete. */
+func (h *Headless) escape(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+
+	switch data[1] {
+	case '[': // control sequence
+		for i := 2; i < len(data); i++ {
+			if data[i] >= 0x40 && data[i] <= 0x7e {
+				h.csi(string(data[2:i]), data[i])
+				return i + 1
+			}
+		}
+		return 0
+
+	case ']': // operating system command, ended by BEL or ESC \
+		for i := 2; i < len(data); i++ {
+			if data[i] == '\a' {
+				return i + 1
+			}
+			if data[i] == '\x1b' && i+1 < len(data) && data[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return 0
+	}
+
+	return 2
+}
+
+func (h *Headless) csi(params string, final byte) {
+	args := []int{}
+	for _, a := range strings.Split(strings.TrimPrefix(params, "?"), ";") {
+		n, _ := strconv.Atoi(a)
+		args = append(args, n)
+	}
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return def
+	}
+
+	switch final {
+	case 'H': // cursor position
+		h.cy, h.cx = arg(0, 1)-1, arg(1, 1)-1
+	case 'K': // erase to the end of the line
+		if h.cy < h.rows {
+			for x := h.cx; x < h.cols; x++ {
+				h.cells[h.cy][x] = ' '
+			}
+		}
+	case 'J': // erase the screen
+		if arg(0, 0) == 2 {
+			for y := range h.cells {
+				for x := range h.cells[y] {
+					h.cells[y][x] = ' '
+				}
+			}
+		}
+	case 'A':
+		h.cy -= arg(0, 1)
+	case 'B':
+		h.cy += arg(0, 1)
+	case 'C':
+		h.cx += arg(0, 1)
+	case 'D':
+		h.cx -= arg(0, 1)
+	case 'G': // cursor to column
+		h.cx = arg(0, 1) - 1
+	}
+
+	if h.cy < 0 {
+		h.cy = 0
+	}
+	if h.cx < 0 {
+		h.cx = 0
+	}
+}
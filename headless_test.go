@@ -0,0 +1,153 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/* testKeymap returns a keymap file with bindings, a JSON object of key names and actions. */
+func testKeymap(t *testing.T, bindings string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keymap.json")
+	if err := os.WriteFile(path, []byte(bindings), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+/* runKeys runs the headless editor on source with the bursts keys and bindings, and returns the text. */
+func runKeys(t *testing.T, source, bindings string, keys ...string) string {
+	t.Helper()
+	h := NewHeadless(10, 40)
+	for _, k := range keys {
+		h.Type(k)
+	}
+	text, err := h.Run([]byte(source), WithKeymapFile(testKeymap(t, bindings)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return text
+}
+
+func TestHeadlessRun(t *testing.T) {
+	keymap := testKeymap(t, `{"ctrl+b": "narrow", "ctrl+w": "widen"}`)
+	long := "0123456789abcdefghij"
+
+	for _, tc := range []struct {
+		name   string
+		source string
+		keys   []string // bursts typed, or pasted when starting with "paste:"
+		want   string   // text of the buffer
+		screen string   // text on the screen after the last burst
+	}{
+		{
+			name:   "arrows",
+			source: "abc\ndef\n",
+			keys:   []string{"\x1b[B", "\x1b[C", "X"},
+			want:   "abc\ndXef\n",
+			screen: "dXef",
+		},
+		{
+			name:   "line start and end",
+			source: "  abc\n",
+			keys:   []string{"\x05", "X", "\x01", "Y", "\x01", "\x01", "Z"},
+			want:   "Z  YabcX\n",
+		},
+		{
+			name:   "sentences",
+			source: "One two. Three four. Five.\n",
+			keys:   []string{"\x1be", "\x1be", "X", "\x1ba", "Y"},
+			want:   "One two. Three four. YXFive.\n",
+		},
+		{
+			name:   "expressions",
+			source: "(a, (b c)) d\n",
+			keys:   []string{"\x1b)", "X", "\x1b(", "\x1b(", "Y"},
+			want:   "Y(a, (b c))X d\n",
+		},
+		{
+			name:   "selection deleted",
+			source: "abcdef\n",
+			keys:   []string{"\x1b[C", "\x00", "\x1b[C", "\x1b[C", "\x1b[C", "\x7f"},
+			want:   "aef\n",
+		},
+		{
+			name:   "selection typed over",
+			source: "abcdef\n",
+			keys:   []string{"\x00", "\x1b[C", "\x1b[C", "X"},
+			want:   "Xcdef\n",
+		},
+		{
+			name:   "selection cleared",
+			source: "abcdef\n",
+			keys:   []string{"\x00", "\x1b[C", "\x1b[C", "\x1b", "X"},
+			want:   "abXcdef\n",
+		},
+		{
+			name:   "narrowed",
+			source: "one\ntwo\nthree\nfour\n",
+			keys:   []string{"\x02", "2-3\r", "\x1b[A", "X", "\x1b[B", "\x1b[B", "\x1b[B", "Y"},
+			want:   "one\nXtwo\nthree\nY\nfour\n",
+			screen: "narrowed",
+		},
+		{
+			name:   "widened",
+			source: "one\ntwo\nthree\nfour\n",
+			keys:   []string{"\x02", "2-3\r", "\x17", "\x1b[A", "X"},
+			want:   "Xone\ntwo\nthree\nfour\n",
+			screen: "Xone",
+		},
+		{
+			name:   "utf-8",
+			source: "text\n",
+			keys:   []string{"\xc3\xa5", "\xe2\x82", "x"},
+			want:   "\u00e5\ufffdxtext\n",
+		},
+		{
+			name:   "overwrite",
+			source: "abcd\n",
+			keys:   []string{"\x1b[2~", "XY"},
+			want:   "XYcd\n",
+			screen: "XYcd",
+		},
+		{
+			name:   "overwrite toggled off",
+			source: "abcd\n",
+			keys:   []string{"\x1b[2~", "X", "\x1b[2~", "Y"},
+			want:   "XYbcd\n",
+		},
+		{
+			name:   "paste",
+			source: "abcd\n",
+			keys:   []string{"paste:" + long},
+			want:   long + "abcd\n",
+		},
+		{
+			name:   "paste overwriting",
+			source: "abcd\nefgh\n",
+			keys:   []string{"\x1b[2~", "paste:" + long},
+			want:   long + "\nefgh\n",
+		},
+	} {
+		h := NewHeadless(10, 40)
+		for _, k := range tc.keys {
+			if text, ok := strings.CutPrefix(k, "paste:"); ok {
+				h.Paste(text)
+			} else {
+				h.Type(k)
+			}
+		}
+		text, err := h.Run([]byte(tc.source), WithKeymapFile(keymap))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if text != tc.want {
+			t.Errorf("%s: text is %q, want %q", tc.name, text, tc.want)
+		}
+		if screen := strings.Join(h.Screen(), "\n"); !strings.Contains(screen, tc.screen) {
+			t.Errorf("%s: %q is not on the screen:\n%s", tc.name, tc.screen, screen)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package editor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Local history
+ *
+ * Snapshots of the edited files are kept in history in the data directory,
+ * independent of any version control: one when a file is opened or saved,
+ * and one every few minutes of a file with unsaved changes, taken when the
+ * typing pauses. Each file gets a directory named by a hash of its path,
+ * holding the path and a snapshot per time stamp. The "local_history" action
+ * lists the snapshots of the current file to view, diff with the buffer or
+ * restore.
+ */
+
+const (
+	historyInterval = 5 * time.Minute // between snapshots of unsaved changes
+	historyKeep     = 50              // snapshots kept per file
+	historyStamp    = "20060102-150405.000"
+)
+
+func historyDir() string {
+	dir := dataDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "history")
+}
+
+/* historyFileDir returns the directory with the snapshots of the file name. */
+func historyFileDir(name string) (string, error) {
+	dir := historyDir()
+	if dir == "" {
+		return "", fmt.Errorf("no home directory")
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])), nil
+}
+
+/* hasHistory reports whether snapshots of b are kept, which needs a local or remote file. */
+func (e *Editor) hasHistory(b *buffer) bool {
+	return !e.isolated && !e.secure && b.fileName != "" && !b.data && b.sink == nil && !isURL(b.fileName)
+}
+
+/* snapshot saves the text of b in its local history, unless it is the same as in the last snapshot. */
+func (e *Editor) snapshot(b *buffer) error {
+	if !e.hasHistory(b) {
+		return nil
+	}
+	text := b.text()
+	sum := sha256.Sum256([]byte(text))
+	hash := hex.EncodeToString(sum[:])
+	if hash == b.historyHash {
+		return nil
+	}
+
+	dir, err := historyFileDir(b.fileName)
+	if err != nil {
+		return err
+	}
+	if b.historyHash == "" { // compare with the snapshot of an earlier session
+		if stamps, _ := historyStamps(dir); len(stamps) > 0 {
+			last, _ := os.ReadFile(filepath.Join(dir, stamps[0]))
+			if sha256.Sum256(last) == sum {
+				b.historyHash = hash
+				return nil
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	abs, _ := filepath.Abs(b.fileName)
+	if err := os.WriteFile(filepath.Join(dir, "path"), []byte(abs+"\n"), 0600); err != nil {
+		return err
+	}
+	now := time.Now()
+	for { // snapshots taken within the same millisecond get different stamps
+		if _, err := os.Stat(filepath.Join(dir, now.Format(historyStamp))); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Millisecond)
+	}
+	if err := os.WriteFile(filepath.Join(dir, now.Format(historyStamp)), []byte(text), 0600); err != nil {
+		return err
+	}
+	b.historyHash = hash
+	b.historySaved = time.Now()
+
+	/* drop the oldest snapshots */
+	stamps, err := historyStamps(dir)
+	if err != nil {
+		return err
+	}
+	for len(stamps) > historyKeep {
+		os.Remove(filepath.Join(dir, stamps[len(stamps)-1]))
+		stamps = stamps[:len(stamps)-1]
+	}
+	return nil
+}
+
+/* historyStamps returns the time stamps of the snapshots in dir, newest first. */
+func historyStamps(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stamps := []string{}
+	for _, en := range entries {
+		if _, err := time.Parse(historyStamp, en.Name()); err == nil {
+			stamps = append(stamps, en.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(stamps)))
+	return stamps, nil
+}
+
+/* historyTick snapshots the buffers with unsaved changes that have not been snapshot for a while. */
+func (e *Editor) historyTick() {
+	for _, b := range e.buffers {
+		if b.dirty && time.Since(b.historySaved) >= historyInterval {
+			if err := e.snapshot(b); err != nil {
+				e.setStatusMsg("local history: %s", err)
+			}
+			b.historySaved = time.Now() // try again later, also when unchanged
+		}
+	}
+}
+
+/* localHistory lets the user pick a snapshot of the current file and view, diff or restore it. */
+func (e *Editor) localHistory() {
+	if !e.hasHistory(e.buf) {
+		e.setStatusMsg("buffer has no local history")
+		e.bell()
+		return
+	}
+	dir, err := historyFileDir(e.buf.fileName)
+	if err != nil {
+		e.setStatusMsg("local history: %s", err)
+		return
+	}
+	stamps, err := historyStamps(dir)
+	if err != nil {
+		e.setStatusMsg("local history: %s", err)
+		return
+	}
+	if len(stamps) == 0 {
+		e.setStatusMsg("No local history for %s", e.buf.fileName)
+		return
+	}
+
+	items := make([]string, len(stamps))
+	for i, s := range stamps {
+		t, _ := time.ParseInLocation(historyStamp, s, time.Local)
+		items[i] = t.Format("2006-01-02 15:04:05")
+	}
+	i, ok := e.Pick("Local history of "+filepath.Base(e.buf.fileName), items)
+	if !ok {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(dir, stamps[i]))
+	if err != nil {
+		e.setStatusMsg("local history: %s", err)
+		return
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	what, ok := e.Pick(items[i], []string{"View", "Diff with buffer", "Restore"})
+	if !ok {
+		return
+	}
+	tab := strings.Repeat(" ", e.tabStop)
+	switch what {
+	case 0:
+		view := make([]string, len(lines))
+		for j, l := range lines {
+			view[j] = strings.ReplaceAll(l, "\t", tab)
+		}
+		e.ShowPopup(items[i], view)
+
+	case 1:
+		diff := unifiedDiff(items[i], "buffer", lines, e.buf.textLines(), 3)
+		if diff == nil {
+			e.setStatusMsg("The buffer is the same as the snapshot")
+			return
+		}
+		for j, l := range diff {
+			diff[j] = strings.ReplaceAll(l, "\t", tab)
+		}
+		e.ShowPopup("Changes since "+items[i], diff)
+
+	case 2:
+		if e.isReadonly() || len(e.buf.protected) > 0 || e.buf.narrow != nil {
+			e.setStatusMsg("buffer can not be replaced")
+			e.bell()
+			return
+		}
+		if e.buf.dirty && !e.Confirm("Discard the unsaved changes?") {
+			return
+		}
+		e.replaceRange(point{}, point{y: len(e.buf.lines)}, string(data))
+		e.snapCursor()
+		e.setStatusMsg("restored the snapshot of %s", items[i])
+	}
+}
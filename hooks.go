@@ -0,0 +1,81 @@
+package editor
+
+import "sync"
+
+/*-----------------------------------------------------------------------------
+ * Hooks
+ */
+
+// Hook names a point in the editor where callbacks can be attached.
+type Hook string
+
+const (
+	BufOpen      Hook = "BufOpen"      // a file or data has been loaded into the buffer
+	BufWritePre  Hook = "BufWritePre"  // the buffer is about to be saved
+	BufWritePost Hook = "BufWritePost" // the buffer has been saved
+	CursorMoved  Hook = "CursorMoved"  // the cursor has moved
+	TextChanged  Hook = "TextChanged"  // the text in the buffer has changed
+	Resize       Hook = "Resize"       // the terminal has been resized
+	FocusGained  Hook = "FocusGained"  // the terminal has gained focus
+	FocusLost    Hook = "FocusLost"    // the terminal has lost focus
+)
+
+var hookNames = map[Hook]bool{
+	BufOpen:      true,
+	BufWritePre:  true,
+	BufWritePost: true,
+	CursorMoved:  true,
+	TextChanged:  true,
+	Resize:       true,
+	FocusGained:  true,
+	FocusLost:    true,
+}
+
+// HookEvent describes the state of the editor when a hook is run.
+type HookEvent struct {
+	Hook      Hook
+	FileName  string // name of the edited file
+	Line      int    // cursor line, zero based
+	Col       int    // cursor column, zero based
+	LineCount int    // number of lines in the buffer
+}
+
+var (
+	defaultHooksMu sync.Mutex
+	defaultHooks   = map[Hook][]func(HookEvent){}
+)
+
+// AddHook registers fn to be called every time the hook h is run in an
+// editor started after the call.
+func AddHook(h Hook, fn func(HookEvent)) {
+	defaultHooksMu.Lock()
+	defer defaultHooksMu.Unlock()
+	defaultHooks[h] = append(defaultHooks[h], fn)
+}
+
+func (e *Editor) addHook(h Hook, fn func(HookEvent)) {
+	e.hooks[h] = append(e.hooks[h], fn)
+}
+
+/* runHook calls the Go and script callbacks attached to h and notifies the subscribed plugins and the events channel. */
+func (e *Editor) runHook(h Hook) {
+	ev := HookEvent{
+		Hook:      h,
+		FileName:  e.buf.fileName,
+		Line:      e.buf.cursor.y,
+		Col:       e.buf.cursor.x,
+		LineCount: len(e.buf.lines),
+	}
+
+	for _, fn := range e.hooks[h] {
+		fn(ev)
+	}
+	e.pluginEvent(ev)
+
+	if e.events != nil {
+		select {
+		case e.events <- ev:
+		default: // the receiver is behind, drop the event rather than block the editor
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package editor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+/* runTask runs an editor on text with task queued, and returns it with the number of times TextChanged ran. */
+func runTask(t *testing.T, text string, task func(e *Editor)) (*Editor, int) {
+	t.Helper()
+	h := NewHeadless(8, 40)
+	h.Type("x")
+	changed := 0
+	e := New(WithTerminal(h), WithHook(TextChanged, func(HookEvent) { changed++ }))
+	e.isolated = true
+	e.Do(func() { task(e) })
+	if err := e.Run(context.Background(), []byte(text)); err != nil && !errors.Is(err, errHeadlessDone) {
+		t.Fatal(err)
+	}
+	return e, changed
+}
+
+func TestTaskEditRunsHooks(t *testing.T) {
+	e, changed := runTask(t, "text\n", func(e *Editor) {
+		if err := e.Buffer().InsertAt(Point{}, "task "); err != nil {
+			t.Error(err)
+		}
+	})
+	if got, want := e.linesToString(), "task xtext\n"; got != want {
+		t.Errorf("text is %q, want %q", got, want)
+	}
+	if changed != 2 {
+		t.Errorf("TextChanged ran %d times, want 2", changed)
+	}
+	if n := len(e.buf.timeline); n != 3 {
+		t.Errorf("the timeline has %d versions, want 3", n)
+	}
+}
+
+func TestPluginEditRunsHooksOnce(t *testing.T) {
+	e, changed := runTask(t, "text\n", func(e *Editor) {
+		p := newPlugin(e, "test", io.Discard, nil, nil)
+		params, _ := json.Marshal(map[string]interface{}{"line": 0, "text": "plugin"})
+		if _, err := p.handle(rpcRequest{JSONRPC: "2.0", Method: "set_line", Params: params}); err != nil {
+			t.Error(err.Message)
+		}
+	})
+	if got, want := e.linesToString(), "plugin\n"; got != want {
+		t.Errorf("text is %q, want %q", got, want)
+	}
+	if changed != 2 { // typing x and the plugin edit
+		t.Errorf("TextChanged ran %d times, want 2", changed)
+	}
+}
+
+func TestCollabEditRunsHooksOnce(t *testing.T) {
+	e, changed := runTask(t, "text\n", func(e *Editor) {
+		s := newCollabSession(e, e.buf, 2)
+		for _, r := range bufferText(s.buf) {
+			s.clock++
+			s.elems = append(s.elems, crdtElem{ID: charID{Clock: s.clock, Site: 1}, Ch: r})
+		}
+		e.startCollab(s)
+		host := &collabPeer{site: 1}
+		s.receive(host, collabMsg{Type: "ops", Ops: []collabOp{{ID: charID{Clock: 100, Site: 1}, Ch: '!'}}})
+	})
+	if got, want := e.linesToString(), "!xtext\n"; got != want {
+		t.Errorf("text is %q, want %q", got, want)
+	}
+	if changed != 2 { // typing x and the remote edit
+		t.Errorf("TextChanged ran %d times, want 2", changed)
+	}
+}
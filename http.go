@@ -0,0 +1,36 @@
+package editor
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * HTTP documents
+ *
+ * http:// and https:// URLs are fetched into a buffer. The buffer can not be
+ * saved back to the URL, saving asks for a file to write it to.
+ */
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+func (e *Editor) openURL(url string) (io.ReadCloser, error) {
+	e.transferStatus("fetching %s ...", url)
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,54 @@
+package editor
+
+import "time"
+
+/*-----------------------------------------------------------------------------
+ * Idle tasks
+ *
+ * Work that is too slow to do for every key press, such as highlighting the
+ * occurrences of the word under the cursor, refreshing the git status,
+ * snapshotting the local history and autosaving, waits until no key has been
+ * pressed for a while. Every idle task runs once per pause in the typing,
+ * when the pause has lasted its delay, and again only after the next key.
+ */
+
+const (
+	occurrenceDelay = 300 * time.Millisecond
+	gitStatusDelay  = time.Second
+	historyDelay    = 2 * time.Second
+	autosaveDelay   = 30 * time.Second
+)
+
+type idleTask struct {
+	delay time.Duration
+	fn    func()
+	gen   int // input generation the task last ran in, -1 if it never ran
+}
+
+// OnIdle runs fn on the editor's main loop when no key has been pressed for
+// delay, once for every pause. It must be called before Run or on the main
+// loop.
+func (e *Editor) OnIdle(delay time.Duration, fn func()) {
+	e.idleTasks = append(e.idleTasks, &idleTask{delay: delay, fn: fn, gen: -1})
+}
+
+/* keyPressed starts a new pause for the idle tasks. */
+func (e *Editor) keyPressed() {
+	e.inputGen++
+	e.lastInput = time.Now()
+}
+
+/* runIdle runs the idle tasks whose delay has passed since the last key, and returns whether any did. */
+func (e *Editor) runIdle() bool {
+	idle := time.Since(e.lastInput)
+	ran := false
+	for _, t := range e.idleTasks {
+		if t.gen == e.inputGen || idle < t.delay {
+			continue
+		}
+		t.gen = e.inputGen
+		t.fn()
+		ran = true
+	}
+	return ran
+}
@@ -0,0 +1,339 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif" // registers the decoders
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Image preview
+ *
+ * Opening a PNG, JPEG or GIF file with open_file, or the "preview_image"
+ * action on an image path under the cursor, shows the image over the screen
+ * until a key is pressed, drawn with the kitty graphics protocol or as sixels
+ * when the terminal supports them. Other terminals get a popup with the size
+ * and format of the image, as do images of more than maxImagePixels, which
+ * are not decoded since a small file can declare a huge image. The
+ * image_preview setting picks the protocol: auto, kitty, sixel or off, which
+ * always shows the popup.
+ */
+
+const (
+	defaultCellWidth  = 10 // pixels, when the terminal does not tell
+	defaultCellHeight = 20
+	kittyChunk        = 4096     // bytes of base64 per kitty graphics escape
+	maxImagePixels    = 50 << 20 // larger images are described rather than decoded
+)
+
+func isImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+func parseImagePreview(value string) (string, error) {
+	switch value {
+	case "auto", "kitty", "sixel", "off":
+		return value, nil
+	}
+	return "", fmt.Errorf("image_preview must be auto, kitty, sixel or off")
+}
+
+/* imageProtocol returns the protocol to draw images with, "" when the terminal has none. */
+func (e *Editor) imageProtocol() string {
+	switch e.imagePreview {
+	case "kitty", "sixel":
+		return e.imagePreview
+	case "off":
+		return ""
+	}
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(term, "kitty") ||
+		program == "WezTerm" || program == "ghostty":
+		return "kitty"
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "mlterm") ||
+		strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "yaft"):
+		return "sixel"
+	}
+	return ""
+}
+
+/* cellSize returns the size of a character cell in pixels. */
+func (e *Editor) cellSize() (int, int) {
+	if cs, ok := e.term.(CellSizer); ok {
+		if w, h := cs.CellSize(); w > 0 && h > 0 {
+			return w, h
+		}
+	}
+	return defaultCellWidth, defaultCellHeight
+}
+
+/* scaleImage returns img scaled down to fit in w by h pixels, keeping its aspect. */
+func scaleImage(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= w && b.Dy() <= h {
+		return img
+	}
+	sw, sh := w, b.Dy()*w/b.Dx()
+	if sh > h {
+		sw, sh = b.Dx()*h/b.Dy(), h
+	}
+	if sw < 1 {
+		sw = 1
+	}
+	if sh < 1 {
+		sh = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, sw, sh))
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*b.Dx()/sw, b.Min.Y+y*b.Dy()/sh))
+		}
+	}
+	return dst
+}
+
+/* kittyImage returns the kitty graphics escapes drawing img at the cursor. */
+func kittyImage(img image.Image) ([]byte, error) {
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		return nil, err
+	}
+	enc := base64.StdEncoding.EncodeToString(data.Bytes())
+
+	var out bytes.Buffer
+	for first := true; first || len(enc) > 0; first = false {
+		chunk := enc
+		if len(chunk) > kittyChunk {
+			chunk = chunk[:kittyChunk]
+		}
+		enc = enc[len(chunk):]
+		more := 0
+		if len(enc) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(&out, "\x1b_Ga=T,f=100,q=2,m=%d;%s\x1b\\", more, chunk)
+		} else {
+			fmt.Fprintf(&out, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+/* sixelImage returns the sixel escape drawing img at the cursor in a palette of 6 levels of red, green and blue. */
+func sixelImage(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "\x1bP0;1;0q\"1;1;%d;%d", w, h)
+	for i := 0; i < 216; i++ {
+		fmt.Fprintf(&out, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+
+	/* the palette entry of each pixel, -1 for transparent ones */
+	pixels := make([]int, w*h)
+	level := func(v uint32) int { return int((v*5 + 0x7fff) / 0xffff) }
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			if a < 0x8000 {
+				pixels[y*w+x] = -1
+				continue
+			}
+			pixels[y*w+x] = level(r)*36 + level(g)*6 + level(bl)
+		}
+	}
+
+	bits := make([]byte, w)
+	for band := 0; band < h; band += 6 {
+		used := map[int]bool{}
+		for y := band; y < band+6 && y < h; y++ {
+			for x := 0; x < w; x++ {
+				if c := pixels[y*w+x]; c >= 0 {
+					used[c] = true
+				}
+			}
+		}
+		for c := 0; c < 216; c++ {
+			if !used[c] {
+				continue
+			}
+			for x := range bits {
+				bits[x] = 0
+				for dy := 0; dy < 6 && band+dy < h; dy++ {
+					if pixels[(band+dy)*w+x] == c {
+						bits[x] |= 1 << dy
+					}
+				}
+			}
+			fmt.Fprintf(&out, "#%d", c)
+			for x := 0; x < w; {
+				n := 1
+				for x+n < w && bits[x+n] == bits[x] {
+					n++
+				}
+				if n > 3 {
+					fmt.Fprintf(&out, "!%d%c", n, 63+bits[x])
+				} else {
+					out.Write(bytes.Repeat([]byte{63 + bits[x]}, n))
+				}
+				x += n
+			}
+			out.WriteByte('$')
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\x1b\\")
+	return out.Bytes()
+}
+
+/* previewImage shows the image file name until a key is pressed. */
+func (e *Editor) previewImage(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	size := int64(0)
+	if fi, err := f.Stat(); err == nil {
+		size = fi.Size()
+	}
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		e.ShowPopup(filepath.Base(name), []string{
+			fmt.Sprintf("%d x %d pixels", cfg.Width, cfg.Height),
+			fmt.Sprintf("%s, %s", strings.ToUpper(format), formatSize(size)),
+			"",
+			fmt.Sprintf("Too large to preview, over %d megapixels.", maxImagePixels>>20),
+		})
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return err
+	}
+	b := img.Bounds()
+	info := fmt.Sprintf("%s  %dx%d %s  %s", filepath.Base(name), b.Dx(), b.Dy(), strings.ToUpper(format), formatSize(size))
+
+	proto := e.imageProtocol()
+	if proto == "" {
+		e.ShowPopup(filepath.Base(name), []string{
+			fmt.Sprintf("%d x %d pixels", b.Dx(), b.Dy()),
+			fmt.Sprintf("%s, %s", strings.ToUpper(format), formatSize(size)),
+			imageColors(img),
+			"",
+			"This terminal can not show images, image_preview picks a protocol.",
+		})
+		return nil
+	}
+
+	cw, ch := e.cellSize()
+	rows := e.termRows + 1 // the status message line shows the caption
+	img = scaleImage(img, e.termCols*cw, rows*ch)
+	var pic []byte
+	if proto == "kitty" {
+		if pic, err = kittyImage(img); err != nil {
+			return err
+		}
+	} else {
+		pic = sixelImage(img)
+	}
+
+	var scr bytes.Buffer
+	scr.WriteString("\x1b[?25l\x1b[m\x1b[2J\x1b[H")
+	scr.Write(pic)
+	caption := info + "  (press a key)"
+	if r := []rune(caption); len(r) > e.termCols {
+		caption = string(r[:e.termCols])
+	}
+	fmt.Fprintf(&scr, "\x1b[%d;1H%s%s\x1b[K\x1b[m", e.termRows+2, sgr(e.theme.StatusBar), caption)
+	e.term.Write(scr.Bytes())
+
+	_, err = e.readKey()
+	if proto == "kitty" {
+		e.term.Write([]byte("\x1b_Ga=d,q=2\x1b\\"))
+	}
+	e.term.Write([]byte("\x1b[2J\x1b[?25h"))
+	return err
+}
+
+/* imageColors describes the colours of img. */
+func imageColors(img image.Image) string {
+	switch i := img.(type) {
+	case *image.Paletted:
+		return fmt.Sprintf("%d colour palette", len(i.Palette))
+	case *image.Gray, *image.Gray16:
+		return "grayscale"
+	case *image.YCbCr:
+		return "YCbCr colour"
+	case *image.CMYK:
+		return "CMYK colour"
+	}
+	return "RGB colour with alpha"
+}
+
+/* pathAt returns the file path around x in chars, as written in text and Markdown links. */
+func pathAt(chars []rune, x int) string {
+	stop := func(r rune) bool { return strings.ContainsRune(" \t\"'`()<>[]{},", r) }
+	if x >= len(chars) || (x > 0 && stop(chars[x])) {
+		x--
+	}
+	if x < 0 || stop(chars[x]) {
+		return ""
+	}
+	start, end := x, x
+	for start > 0 && !stop(chars[start-1]) {
+		start--
+	}
+	for end < len(chars) && !stop(chars[end]) {
+		end++
+	}
+	return string(chars[start:end])
+}
+
+func (e *Editor) previewImageAction() {
+	name := ""
+	if y := e.buf.cursor.y; y < len(e.buf.lines) {
+		name = pathAt(e.buf.lines[y].chars, e.buf.cursor.x)
+	}
+	if name != "" && !filepath.IsAbs(name) && e.buf.fileName != "" {
+		if p := filepath.Join(filepath.Dir(e.buf.fileName), name); fileExists(p) {
+			name = p
+		}
+	}
+	if name == "" || !isImage(name) || !fileExists(name) {
+		var ok bool
+		name, ok = e.historyPrompt(fileHistory, "Preview image: ", nil, completePath)
+		if !ok || name == "" {
+			return
+		}
+	}
+	if err := e.previewImage(name); err != nil {
+		e.setStatusMsg("%s: %s", name, err)
+		e.bell()
+	}
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
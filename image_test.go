@@ -0,0 +1,41 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/* hugePNG returns a small PNG file whose header declares an image of w by h pixels. */
+func hugePNG(t *testing.T, w, h uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	ihdr := data[8+8 : 8+8+13] // after the signature, the length and the type
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	return data
+}
+
+func TestPreviewHugeImage(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "huge.png")
+	if err := os.WriteFile(name, hugePNG(t, 100000, 100000), 0600); err != nil {
+		t.Fatal(err)
+	}
+	e := newEditor(NewHeadless(8, 40))
+	if err := e.previewImage(name); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.overlays) != 1 || !strings.Contains(strings.Join(e.overlays[0].lines, "\n"), "100000 x 100000 pixels") {
+		t.Error("no popup describes the image")
+	}
+}
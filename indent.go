@@ -0,0 +1,119 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Indentation rules
+ *
+ * Some file types break on the wrong invisible whitespace. Recipe lines of
+ * Makefiles must start with a tab, YAML does not allow tabs in indentation
+ * and Python does not allow mixing tabs and spaces. In YAML the tab key
+ * inserts spaces, and saving a file breaking its rule warns about the first
+ * line doing so.
+ */
+
+type indentRule int
+
+const (
+	indentAny    indentRule = iota
+	indentTabs              // recipe lines start with a tab
+	indentSpaces            // no tabs in indentation
+	indentNoMix             // no tabs and spaces in the same indentation
+)
+
+func indentRuleFor(name string) indentRule {
+	base := strings.ToLower(filepath.Base(name))
+	switch {
+	case base == "makefile" || base == "gnumakefile" || strings.HasSuffix(base, ".mk"):
+		return indentTabs
+	case strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml"):
+		return indentSpaces
+	case strings.HasSuffix(base, ".py"):
+		return indentNoMix
+	}
+	return indentAny
+}
+
+/* insertTab inserts a tab, or spaces up to the next tab stop where tabs are not allowed. */
+func (e *Editor) insertTab() {
+	if indentRuleFor(e.buf.fileName) != indentSpaces {
+		e.insertChar('\t')
+		return
+	}
+	rx := 0
+	if e.buf.cursor.y < len(e.buf.lines) {
+		rx = e.computeRx(e.buf.lines[e.buf.cursor.y].chars, e.buf.cursor.x)
+	}
+	for n := e.tabStop - rx%e.tabStop; n > 0; n-- {
+		e.insertChar(' ')
+	}
+}
+
+/* indentation returns the leading tabs and spaces of s. */
+func indentation(s []rune) string {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return string(s[:i])
+}
+
+/* isMakeRule reports whether s is a line of a Makefile starting a rule, which recipe lines follow. */
+func isMakeRule(s string) bool {
+	if s == "" || s[0] == ' ' || s[0] == '\t' || s[0] == '#' {
+		return false
+	}
+	i := strings.IndexByte(s, ':')
+	if i < 0 || strings.ContainsAny(s[:i], "=") {
+		return false
+	}
+	rest := s[i+1:]
+	return !strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, ":=")
+}
+
+/* indentationProblem returns the first line of b breaking the indentation rule of its file, and what is wrong, or -1. */
+func (b *buffer) indentationProblem() (int, string) {
+	rule := indentRuleFor(b.fileName)
+	if rule == indentAny {
+		return -1, ""
+	}
+	inRecipe := false
+	for y, l := range b.allLines() {
+		indent := indentation(l.chars)
+		switch rule {
+		case indentTabs:
+			text := string(l.chars)
+			switch {
+			case isMakeRule(text):
+				inRecipe = true
+			case strings.TrimSpace(text) == "" || strings.HasPrefix(text, "\t"):
+			case strings.HasPrefix(text, " ") && inRecipe:
+				return y, "recipe lines must start with a tab"
+			default:
+				inRecipe = false
+			}
+		case indentSpaces:
+			if strings.Contains(indent, "\t") {
+				return y, "tabs are not allowed in indentation"
+			}
+		case indentNoMix:
+			if strings.Contains(indent, "\t") && strings.Contains(indent, " ") {
+				return y, "indentation mixes tabs and spaces"
+			}
+		}
+	}
+	return -1, ""
+}
+
+/* checkIndentation warns about the first line of the current buffer breaking the indentation rule of its file. */
+func (e *Editor) checkIndentation() {
+	y, problem := e.buf.indentationProblem()
+	if y < 0 {
+		return
+	}
+	e.setStatusMsg("%s, but line %d: %s", e.statusMsg, y+1, problem)
+	e.bell()
+}
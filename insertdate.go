@@ -0,0 +1,88 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Insert dates
+ *
+ * The "insert_date" action inserts the date and time at the cursor in one of
+ * the formats of the date_formats setting, separated by |, which are offered
+ * to pick from when there are several. The formats use the conversions of
+ * strftime: %Y %y %m %d %e %H %I %M %S %p %a %A %b %B %j %Z %z %s %F %T and
+ * %%. The "insert_metadata" action inserts the file name, its path, the name
+ * of the user or an ISO 8601 time stamp.
+ */
+
+var defaultDateFormats = []string{"%Y-%m-%d", "%Y-%m-%d %H:%M", "%a %e %b %Y"}
+
+/* strftime formats t like strftime(3) formats it with format. */
+func strftime(format string, t time.Time) string {
+	layouts := map[byte]string{
+		'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'H': "15", 'I': "03",
+		'M': "04", 'S': "05", 'p': "PM", 'a': "Mon", 'A': "Monday", 'b': "Jan",
+		'B': "January", 'Z': "MST", 'z': "-0700", 'F': "2006-01-02", 'T': "15:04:05",
+	}
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch c := format[i]; c {
+		case '%':
+			sb.WriteByte('%')
+		case 'j':
+			fmt.Fprintf(&sb, "%03d", t.YearDay())
+		case 's':
+			fmt.Fprintf(&sb, "%d", t.Unix())
+		default:
+			if layout, ok := layouts[c]; ok {
+				sb.WriteString(t.Format(layout))
+			} else {
+				sb.WriteString("%" + string(c))
+			}
+		}
+	}
+	return sb.String()
+}
+
+/* insertChoice inserts the item picked from items, or the only one. */
+func (e *Editor) insertChoice(title string, items []string) {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	i := 0
+	if len(items) > 1 {
+		var ok bool
+		if i, ok = e.Pick(title, items); !ok {
+			return
+		}
+	}
+	e.insertText(items[i])
+}
+
+func (e *Editor) insertDate() {
+	now := time.Now()
+	items := make([]string, len(e.dateFormats))
+	for i, f := range e.dateFormats {
+		items[i] = strftime(f, now)
+	}
+	e.insertChoice("Insert date", items)
+}
+
+func (e *Editor) insertMetadata() {
+	items := []string{}
+	if e.buf.fileName != "" {
+		items = append(items, filepath.Base(e.buf.fileName), bufferPath(e.buf))
+	}
+	items = append(items, userName(), time.Now().Format(time.RFC3339))
+	e.insertChoice("Insert", items)
+}
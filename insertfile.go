@@ -0,0 +1,63 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Insert files
+ *
+ * The "insert_file" action prompts for a file, which may be compressed, remote
+ * or a URL like those the editor opens, and inserts its text at the cursor as
+ * one change that undo_replace undoes. Input starting with ! is kept for inserting the output of a
+ * command.
+ */
+
+/* readText returns the text of the file name, without carriage returns before line breaks. */
+func (e *Editor) readText(name string) (string, error) {
+	r, _, f, err := e.openDecompressed(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("binary file")
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+func (e *Editor) insertFile() {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	name, ok := e.historyPrompt(fileHistory, "Insert file: ", nil, completePath)
+	if !ok || name == "" {
+		return
+	}
+	if strings.HasPrefix(name, "!") {
+		e.setStatusMsg("inserting the output of commands is not supported yet")
+		e.bell()
+		return
+	}
+	text, err := e.readText(name)
+	if err != nil {
+		e.setStatusMsg("%s: %s", name, err)
+		e.bell()
+		return
+	}
+	b := e.buf
+	u := replaceSnapshot(b)
+	e.insertText(text)
+	e.keepReplaceUndo(u)
+	e.setStatusMsg("Inserted %s, %d lines", name, strings.Count(text, "\n"))
+}
@@ -0,0 +1,39 @@
+package editor
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInsertFile(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.txt")
+	if err := os.WriteFile(plain, []byte("one\r\ntwo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	packed := filepath.Join(dir, "packed.gz")
+	f, err := os.Create(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte("zipped\n"))
+	zw.Close()
+	f.Close()
+
+	bindings := `{"ctrl+b": "insert_file", "ctrl+o": "undo_replace"}`
+	for _, tc := range []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"\x02", plain + "\r"}, "one\ntwo\ntext\n"},
+		{[]string{"\x02", packed + "\r"}, "zipped\ntext\n"},
+		{[]string{"\x02", plain + "\r", "\x0f"}, "text\n"},
+	} {
+		if text := runKeys(t, "text\n", bindings, tc.keys...); text != tc.want {
+			t.Errorf("%q: text is %q, want %q", tc.keys, text, tc.want)
+		}
+	}
+}
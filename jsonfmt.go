@@ -0,0 +1,110 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * JSON formatting
+ *
+ * The "json_format" action indents the JSON of the selection, or else of the
+ * whole buffer, two spaces a level, and "json_minify" removes the space
+ * between its tokens. When the text is not valid JSON the cursor is put on
+ * the character at fault, which is highlighted until the next key.
+ */
+
+const problemStyle = "\x1b[41;97m"
+
+/* offsetPoint returns the point of the byte offset off of text, which starts at start. */
+func offsetPoint(text string, off int, start point) point {
+	if off < 0 {
+		off = 0
+	}
+	if off > len(text) {
+		off = len(text)
+	}
+	before := text[:off]
+	nl := strings.LastIndexByte(before, '\n')
+	p := point{y: start.y + strings.Count(before, "\n"), x: utf8.RuneCountInString(before[nl+1:])}
+	if nl < 0 {
+		p.x += start.x
+	}
+	return p
+}
+
+/* problemMarks adds the character at fault of the last action to marks. */
+func (e *Editor) problemMarks(marks map[int]map[int]string) map[int]map[int]string {
+	p := e.problem
+	if p == nil || p.y >= len(e.buf.lines) {
+		return marks
+	}
+	if marks == nil {
+		marks = map[int]map[int]string{}
+	}
+	if marks[p.y] == nil {
+		marks[p.y] = map[int]string{}
+	}
+	marks[p.y][e.lineRx(e.buf.lines[p.y], p.x)] = problemStyle
+	return marks
+}
+
+/* jsonTransform replaces the JSON of the selection or the buffer with what fn writes for it. */
+func (e *Editor) jsonTransform(fn func(*bytes.Buffer, []byte) error) {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	a, c, selected := e.buf.selection()
+	if !selected {
+		a, c = point{}, point{y: len(e.buf.lines)}
+	}
+	last := c.y
+	if last == len(e.buf.lines) {
+		last--
+	}
+	if !e.canEdit(a.y, last) {
+		return
+	}
+
+	text := strings.TrimRight(e.buf.textRange(a, c), " \t\r\n")
+	var out bytes.Buffer
+	if err := fn(&out, []byte(text)); err != nil {
+		off := len(text) // the end of the input
+		var se *json.SyntaxError
+		if errors.As(err, &se) && se.Offset > 0 && int(se.Offset) <= len(text) {
+			off = int(se.Offset) - 1
+		}
+		p := offsetPoint(text, off, a)
+		e.setCursor(p)
+		e.snapCursor()
+		e.problem = &p
+		e.setStatusMsg("json: line %d, column %d: %s", p.y+1+e.buf.hiddenAbove(), p.x+1, err)
+		e.bell()
+		return
+	}
+
+	if c.y == len(e.buf.lines) {
+		out.WriteByte('\n')
+	}
+	if selected {
+		e.replaceSelection(out.String())
+		return
+	}
+	e.replaceRange(a, c, out.String())
+	e.setCursor(point{})
+}
+
+func (e *Editor) jsonFormat() {
+	e.jsonTransform(func(out *bytes.Buffer, src []byte) error {
+		return json.Indent(out, src, "", "  ")
+	})
+}
+
+func (e *Editor) jsonMinify() {
+	e.jsonTransform(json.Compact)
+}
@@ -0,0 +1,88 @@
+package editor
+
+import (
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Center and right-align
+ *
+ * The "center_line" and "right_align_line" actions indent the line at the
+ * cursor, or the selected lines, with spaces so that the text is centered in
+ * the textwidth or ends at it, for headers of plain text documents. Space at
+ * the ends of the lines is removed, and lines wider than the textwidth lose
+ * their indentation.
+ */
+
+/* justifyLine indents the text of l to center it in width columns, or to end it at the last. */
+func (e *Editor) justifyLine(l string, width int, center bool) string {
+	text := strings.TrimSpace(l)
+	if text == "" {
+		return ""
+	}
+	pad := width - len(e.updateRow([]rune(text)))
+	if pad < 0 {
+		pad = 0
+	}
+	if center {
+		pad /= 2
+	}
+	return strings.Repeat(" ", pad) + text
+}
+
+func (e *Editor) justifyLines(center bool) {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	first, last, ok := e.buf.selectedLines()
+	if !ok {
+		first, last = e.buf.cursor.y, e.buf.cursor.y
+	}
+	if first >= len(e.buf.lines) {
+		e.bell()
+		return
+	}
+	if last >= len(e.buf.lines) {
+		last = len(e.buf.lines) - 1
+	}
+	if !e.canEdit(first, last) {
+		return
+	}
+
+	changed := false
+	for y := first; y <= last; y++ {
+		chars := e.buf.lines[y].chars
+		l := e.justifyLine(string(chars), e.textWidth, center)
+		if l == string(chars) {
+			continue
+		}
+		if y == e.buf.cursor.y {
+			/* keep the cursor on its character */
+			lead := len(chars) - len([]rune(strings.TrimLeft(string(chars), " \t")))
+			x := e.buf.cursor.x - lead + len(l) - len(strings.TrimLeft(l, " "))
+			if x < 0 {
+				x = 0
+			}
+			e.buf.cursor.x = x
+		}
+		e.buf.lines[y].chars = []rune(l)
+		e.buf.lines[y].render = e.updateRow(e.buf.lines[y].chars)
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	e.buf.dirty = true
+	e.changes++
+	e.snapCursor()
+}
+
+func (e *Editor) centerLine() {
+	e.justifyLines(true)
+}
+
+func (e *Editor) rightAlignLine() {
+	e.justifyLines(false)
+}
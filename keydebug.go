@@ -0,0 +1,68 @@
+package editor
+
+import "fmt"
+
+/*-----------------------------------------------------------------------------
+ * Key debugging
+ *
+ * The "debug_keys" action toggles showing the bytes the terminal sent for
+ * every key in the status message, with the name to use for the key in
+ * keymap.json. Sequences the editor does not know are shown as well, which
+ * tells what a terminal sends for keys that can not be bound yet.
+ */
+
+func (e *Editor) toggleDebugKeys() {
+	e.debugKeys = !e.debugKeys
+	e.keyBytes = nil
+	if e.debugKeys {
+		e.setStatusMsg("debug_keys: on, press keys to see what the terminal sends")
+	} else {
+		e.setStatusMsg("debug_keys: off")
+	}
+}
+
+/* keyName returns the name of k in keymap.json, or "" if it has none. */
+func keyName(k int) string {
+	for name, code := range keyNames {
+		if code == k {
+			return name
+		}
+	}
+	if k >= 1 && k <= 26 {
+		return "ctrl+" + string(rune('a'+k-1))
+	}
+	if k >= ' ' && k < kArrowUp && k != kBackSpace {
+		return string(rune(k))
+	}
+	if k >= kAlt+' ' && k < kAlt+kBackSpace {
+		return "alt+" + string(rune(k-kAlt))
+	}
+	return ""
+}
+
+/* describeKey tells the bytes read for the key k. */
+func describeKey(raw []byte, k int) string {
+	name := keyName(k)
+	if name == "" {
+		return fmt.Sprintf("debug_keys: %q has no name in keymap.json", raw)
+	}
+	return fmt.Sprintf("debug_keys: %q is %q in keymap.json", raw, name)
+}
+
+/* reportUnknownKey shows the bytes of a sequence readKey dropped because it is not a key it knows. */
+func (e *Editor) reportUnknownKey() {
+	if !e.debugKeys || len(e.keyBytes) == 0 {
+		return
+	}
+	/* the rest of the sequence arrives with it, and would be read as keys of its own */
+	if w, ok := e.term.(KeyWaiter); ok {
+		for w.WaitKey(0) {
+			if _, err := e.rawReadKey(); err != nil {
+				break
+			}
+		}
+	}
+	e.setStatusMsg("debug_keys: %q is not a key the editor knows", e.keyBytes)
+	e.keyBytes = e.keyBytes[:0]
+	e.refreshScreen()
+}
@@ -0,0 +1,75 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Keyboard layouts
+ *
+ * Terminals send the characters typed, not where the keys are, so bindings
+ * chosen for where the keys sit on a QWERTY keyboard end up scattered on
+ * other layouts. A key name starting with "pos:", such as "pos:ctrl+s", names
+ * the key at the position of s on a QWERTY keyboard, which is o on Dvorak and
+ * r on Colemak. The layout is set by "layout" in keymap.json or the
+ * keyboard_layout setting, and keymap.json can override bindings for a
+ * layout:
+ *
+ *	{
+ *		"layout": "dvorak",
+ *		"pos:ctrl+s": "save_as",
+ *		"layouts": {
+ *			"dvorak": {"ctrl+o": "open_file"},
+ *			"azerty": {"ctrl+w": "goto_line"}
+ *		}
+ *	}
+ */
+
+/* keyboardLayouts are the characters of the three letter rows of each layout, unshifted. */
+var keyboardLayouts = map[string][3]string{
+	"qwerty":  {"qwertyuiop[]", "asdfghjkl;'", "zxcvbnm,./"},
+	"dvorak":  {"',.pyfgcrl/=", "aoeuidhtns-", ";qjkxbmwvz"},
+	"colemak": {"qwfpgjluy;[]", "arstdhneio'", "zxcvbkm,./"},
+	"workman": {"qdrwbjfup;[]", "ashtgyneoi'", "zxmcvkl,./"},
+	"azerty":  {"azertyuiop^$", "qsdfghjklmù", "wxcvbn,;:!"},
+	"qwertz":  {"qwertzuiopü+", "asdfghjklöä", "yxcvbnm,.-"},
+}
+
+func parseLayout(name string) (string, error) {
+	name = strings.ToLower(name)
+	if _, ok := keyboardLayouts[name]; !ok {
+		return "", fmt.Errorf("unknown keyboard layout %q", name)
+	}
+	return name, nil
+}
+
+/* layoutKey translates a key name at a QWERTY position, the name after "pos:", to the key at that position in layout. */
+func layoutKey(layout, name string) (string, error) {
+	mods, c := "", name
+	if i := strings.LastIndex(name[:len(name)-1], "+"); i >= 0 {
+		mods, c = name[:i+1], name[i+1:]
+	}
+	rows, ok := keyboardLayouts[layout]
+	if !ok {
+		rows = keyboardLayouts["qwerty"]
+	}
+	for r, row := range keyboardLayouts["qwerty"] {
+		if i := strings.Index(row, strings.ToLower(c)); i >= 0 && len(c) == 1 {
+			return mods + string([]rune(rows[r])[i]), nil
+		}
+	}
+	return "", fmt.Errorf("%q is not a key of the letter rows", c)
+}
+
+/* resolveKey returns the key name of a binding, translating "pos:" names to the keyboard layout. */
+func (e *Editor) resolveKey(name string) (string, error) {
+	pos, ok := strings.CutPrefix(name, "pos:")
+	if !ok {
+		return name, nil
+	}
+	if pos == "" {
+		return "", fmt.Errorf("missing key after pos:")
+	}
+	return layoutKey(e.keyboardLayout, pos)
+}
@@ -0,0 +1,111 @@
+package editor
+
+import (
+	"math/rand"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Line filters
+ *
+ * Line filters rewrite the selected lines, or all lines of the buffer when
+ * nothing is selected, as one edit that "undo_replace" undoes. "uniq_lines"
+ * removes lines that repeat the line before them and "uniq_all_lines" lines
+ * that repeat any line before them. "reverse_lines" puts the lines in reverse
+ * order and "shuffle_lines" in random order.
+ */
+
+/* filterLines replaces the selected lines, or all lines, with what fn makes of them and reports whether it did. */
+func (e *Editor) filterLines(fn func([]string) []string) bool {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return false
+	}
+	first, last, ok := e.buf.selectedLines()
+	if !ok {
+		first, last = 0, len(e.buf.lines)-1
+	}
+	if last < first || !e.canEdit(first, last) {
+		return false
+	}
+
+	lines := make([]string, 0, last-first+1)
+	for y := first; y <= last; y++ {
+		lines = append(lines, string(e.buf.lines[y].chars))
+	}
+	out := fn(lines)
+	if strings.Join(out, "\n") == strings.Join(lines, "\n") {
+		return false
+	}
+
+	b := e.buf
+	u := replaceSnapshot(b)
+	e.replaceRange(point{y: first}, point{x: len(b.lines[last].chars), y: last}, strings.Join(out, "\n"))
+	e.keepReplaceUndo(u)
+	e.snapCursor()
+	return true
+}
+
+/* uniqLines returns lines without the lines equal to the one before them, or with all to any line before them. */
+func uniqLines(lines []string, all bool) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for i, l := range lines {
+		if (all && seen[l]) || (!all && i > 0 && l == lines[i-1]) {
+			continue
+		}
+		seen[l] = true
+		out = append(out, l)
+	}
+	return out
+}
+
+func (e *Editor) uniq(all bool) {
+	removed := -1 // until the lines are filtered
+	if !e.filterLines(func(lines []string) []string {
+		out := uniqLines(lines, all)
+		removed = len(lines) - len(out)
+		return out
+	}) {
+		if removed == 0 {
+			e.setStatusMsg("No duplicate lines")
+		}
+		return
+	}
+	e.setStatusMsg("Removed %d duplicate lines, undo_replace undoes it", removed)
+}
+
+func (e *Editor) uniqLinesAction() {
+	e.uniq(false)
+}
+
+func (e *Editor) uniqAllLinesAction() {
+	e.uniq(true)
+}
+
+func (e *Editor) reverseLines() {
+	n := 0
+	if e.filterLines(func(lines []string) []string {
+		n = len(lines)
+		out := make([]string, n)
+		for i, l := range lines {
+			out[n-1-i] = l
+		}
+		return out
+	}) {
+		e.setStatusMsg("Reversed %d lines, undo_replace undoes it", n)
+	}
+}
+
+func (e *Editor) shuffleLines() {
+	n := 0
+	if e.filterLines(func(lines []string) []string {
+		n = len(lines)
+		out := append([]string{}, lines...)
+		rand.Shuffle(n, func(i, j int) { out[i], out[j] = out[j], out[i] })
+		return out
+	}) {
+		e.setStatusMsg("Shuffled %d lines, undo_replace undoes it", n)
+	}
+}
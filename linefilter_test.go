@@ -0,0 +1,38 @@
+package editor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUniqLines(t *testing.T) {
+	tests := []struct {
+		lines []string
+		all   bool
+		want  []string
+	}{
+		{[]string{"a", "a", "b", "a"}, false, []string{"a", "b", "a"}},
+		{[]string{"a", "a", "b", "a"}, true, []string{"a", "b"}},
+		{[]string{"", "", "x", ""}, false, []string{"", "x", ""}},
+		{[]string{"", "", "x", ""}, true, []string{"", "x"}},
+		{[]string{}, false, []string{}},
+	}
+	for _, tt := range tests {
+		if got := uniqLines(tt.lines, tt.all); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("uniqLines(%q, %v) = %q, want %q", tt.lines, tt.all, got, tt.want)
+		}
+	}
+}
+
+func TestReverseLinesUndo(t *testing.T) {
+	e, _ := runTask(t, "a\nb\nc", func(e *Editor) {
+		e.reverseLines()
+		if got, want := e.buf.text(), "c\nb\nxa\n"; got != want {
+			t.Errorf("reverse_lines: got %q, want %q", got, want)
+		}
+		e.undoReplace()
+	})
+	if got, want := e.buf.text(), "xa\nb\nc\n"; got != want {
+		t.Errorf("undo_replace: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package editor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Links
+ *
+ * URLs in the text are drawn with the Link colour of the theme, underlined by
+ * default. The "open_url" action opens the URL under the cursor, or the first
+ * one after it on the line, with xdg-open, or open on macOS.
+ *
+ * Terminals that support OSC 8 hyperlinks also get the URLs, Markdown links
+ * and HTML anchors as links to click, without changing the text drawn. Links
+ * to relative paths point at the file next to the buffer's. The hyperlinks
+ * setting turns them on or off, or leaves it to the terminal with auto.
+ */
+
+var (
+	urlPattern      = regexp.MustCompile(`(?:https?|ftp)://[^\s<>"'()\[\]{}]+`)
+	mdLinkPattern   = regexp.MustCompile(`!?\[[^\]]*\]\(([^)\s]+)(?:\s[^)]*)?\)`)
+	htmlLinkPattern = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"']+)["'][^>]*>.*?</a>`)
+	uriScheme       = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+/* hyperlink is a span of a line that links to url. */
+type hyperlink struct {
+	start, end int // characters, or render columns once on the screen
+	url        string
+}
+
+/* findURLs returns the start and end index of the URLs in chars, without trailing punctuation. */
+func findURLs(chars []rune) [][2]int {
+	s := string(chars)
+	found := [][2]int{}
+	for _, m := range urlPattern.FindAllStringIndex(s, -1) {
+		u := strings.TrimRight(s[m[0]:m[1]], ".,;:!?")
+		start := len([]rune(s[:m[0]]))
+		found = append(found, [2]int{start, start + len([]rune(u))})
+	}
+	return found
+}
+
+/* linkMarks adds the URLs on the screen to marks, leaving columns that are already marked alone. */
+func (e *Editor) linkMarks(marks map[int]map[int]string) map[int]map[int]string {
+	if e.theme.Link == "" {
+		return marks
+	}
+	link := sgr(e.theme.Link)
+
+	for y := e.buf.fileY; y < e.buf.fileY+e.termRows && y < len(e.buf.lines); y++ {
+		chars := e.buf.lines[y].chars
+		for _, u := range findURLs(chars) {
+			if marks == nil {
+				marks = map[int]map[int]string{}
+			}
+			if marks[y] == nil {
+				marks[y] = map[int]string{}
+			}
+			for x := u[0]; x < u[1]; x++ {
+				rx := e.lineRx(e.buf.lines[y], x)
+				if _, ok := marks[y][rx]; !ok {
+					marks[y][rx] = link
+				}
+			}
+		}
+	}
+	return marks
+}
+
+/* findLinks returns the Markdown links, HTML anchors and URLs in chars, in order. */
+func findLinks(chars []rune) []hyperlink {
+	s := string(chars)
+	links := []hyperlink{}
+	for _, re := range []*regexp.Regexp{mdLinkPattern, htmlLinkPattern} {
+		for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+			start := len([]rune(s[:m[0]]))
+			links = append(links, hyperlink{start, start + len([]rune(s[m[0]:m[1]])), s[m[2]:m[3]]})
+		}
+	}
+	for _, u := range findURLs(chars) {
+		inside := false
+		for _, l := range links {
+			inside = inside || (u[0] < l.end && u[1] > l.start)
+		}
+		if !inside {
+			links = append(links, hyperlink{u[0], u[1], string(chars[u[0]:u[1]])})
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].start < links[j].start })
+	return links
+}
+
+/* hyperlinkTarget returns the URI for the link target url of a buffer editing name, "" if there is none. */
+func hyperlinkTarget(url, name string) string {
+	if strings.IndexFunc(url, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+		return ""
+	}
+	if uriScheme.MatchString(url) {
+		return url
+	}
+	if strings.HasPrefix(url, "#") || name == "" {
+		return ""
+	}
+	path := url
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(name), path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	host, _ := os.Hostname()
+	return "file://" + host + filepath.ToSlash(path)
+}
+
+/* supportsHyperlinks reports whether the terminal is known to draw OSC 8 hyperlinks. */
+func supportsHyperlinks() bool {
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch program {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper":
+		return true
+	}
+	if vte, _ := strconv.Atoi(os.Getenv("VTE_VERSION")); vte >= 5000 || os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" {
+		return true
+	}
+	for _, t := range []string{"kitty", "foot", "alacritty", "wezterm", "contour"} {
+		if strings.Contains(term, t) {
+			return true
+		}
+	}
+	return false
+}
+
+/* hyperlinks returns the links on the rows of the screen in render columns, by line, or nil when they are not drawn. */
+func (e *Editor) hyperlinks() map[int][]hyperlink {
+	if !e.hyperlinksOn {
+		return nil
+	}
+	var rows map[int][]hyperlink
+	for y := e.buf.fileY; y < e.buf.fileY+e.termRows && y < len(e.buf.lines); y++ {
+		l := e.buf.lines[y]
+		for _, h := range findLinks(l.chars) {
+			target := hyperlinkTarget(h.url, e.buf.fileName)
+			if target == "" {
+				continue
+			}
+			if rows == nil {
+				rows = map[int][]hyperlink{}
+			}
+			rows[y] = append(rows[y], hyperlink{e.lineRx(l, h.start), e.lineRx(l, h.end), target})
+		}
+	}
+	return rows
+}
+
+/* urlAtCursor returns the URL under the cursor, or the first one after it on the line. */
+func (e *Editor) urlAtCursor() string {
+	if e.buf.cursor.y >= len(e.buf.lines) {
+		return ""
+	}
+	chars := e.buf.lines[e.buf.cursor.y].chars
+	for _, u := range findURLs(chars) {
+		if e.buf.cursor.x < u[1] {
+			return string(chars[u[0]:u[1]])
+		}
+	}
+	return ""
+}
+
+func (e *Editor) openLink() {
+	url := e.urlAtCursor()
+	if url == "" {
+		e.setStatusMsg("No URL at the cursor")
+		e.bell()
+		return
+	}
+
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	cmd := exec.Command(opener, url)
+	if err := cmd.Start(); err != nil {
+		e.setStatusMsg("%s: %s", opener, err)
+		return
+	}
+	go cmd.Wait()
+	e.setStatusMsg("Opening %s", url)
+}
@@ -0,0 +1,220 @@
+package editor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*-----------------------------------------------------------------------------
+ * Macros
+ *
+ * The "record_macro" action, ctrl+r by default, starts recording the keys
+ * typed and stops when it is used again, and "play_macro", ctrl+t, plays the
+ * recorded keys back. "save_macro" names the last macro and keeps it in
+ * macros.json in the configuration directory, which makes it an action of its
+ * own that keymap.json can bind to a key, in this and later sessions. The keys
+ * are kept as text, with special keys written as their key names in angle
+ * brackets and < as <<, for example:
+ *
+ *	{"quote_line": "<home>\"<end>\"<down>"}
+ */
+
+func macrosFile() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "macros.json")
+}
+
+/* macroText writes the keys as macro text. */
+func macroText(keys []int) string {
+	var sb strings.Builder
+	for _, k := range keys {
+		switch {
+		case k == '<':
+			sb.WriteString("<<")
+		case k < kArrowUp && k != kBackSpace && unicode.IsPrint(rune(k)):
+			sb.WriteRune(rune(k))
+		default:
+			sb.WriteString("<" + keyLabel(k) + ">")
+		}
+	}
+	return sb.String()
+}
+
+/* parseMacroText reads the keys of macro text. */
+func parseMacroText(s string) ([]int, error) {
+	keys := []int{}
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "<<") {
+			keys = append(keys, '<')
+			s = s[2:]
+			continue
+		}
+		if s[0] == '<' {
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				return nil, fmt.Errorf("missing > after %q", s)
+			}
+			k, err := parseMacroKey(s[1:end])
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+			s = s[end+1:]
+			continue
+		}
+		r := []rune(s)[0]
+		keys = append(keys, int(r))
+		s = s[len(string(r)):]
+	}
+	return keys, nil
+}
+
+/* parseMacroKey is parseKey for the labels of keys keymap.json has no name for as well. */
+func parseMacroKey(name string) (int, error) {
+	if k, err := parseKey(name); err == nil {
+		return k, nil
+	}
+	if c, ok := strings.CutPrefix(name, "ctrl+"); ok && len(c) == 1 && c[0] >= '@' && c[0] <= '_' {
+		return int(c[0] - '@'), nil
+	}
+	if code, ok := strings.CutPrefix(name, "key "); ok {
+		if k, err := strconv.ParseInt(code, 0, 32); err == nil {
+			return int(k), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown key %q", name)
+}
+
+/* loadMacros makes actions of the macros in macros.json. */
+func (e *Editor) loadMacros() {
+	if e.isolated || macrosFile() == "" {
+		return
+	}
+	data, err := os.ReadFile(macrosFile())
+	if err != nil {
+		return
+	}
+	texts := map[string]string{}
+	if err := json.Unmarshal(data, &texts); err != nil {
+		e.setStatusMsg("macros: %s", err)
+		return
+	}
+	for name, text := range texts {
+		keys, err := parseMacroText(text)
+		if err != nil {
+			e.setStatusMsg("macro %s: %s", name, err)
+			continue
+		}
+		e.defineMacro(name, keys)
+	}
+}
+
+/* defineMacro makes the action name play keys. */
+func (e *Editor) defineMacro(name string, keys []int) {
+	if e.macros == nil {
+		e.macros = map[string][]int{}
+	}
+	e.macros[name] = keys
+	e.actionDispatch[name] = func() { e.stopAfterAction(e.playKeys(keys)) }
+}
+
+func (e *Editor) recordMacro() {
+	if e.macro == nil {
+		e.macro = []int{}
+		e.setStatusMsg("Recording a macro, record_macro again stops")
+		return
+	}
+	keys := e.macro
+	if n := len(keys); n > 0 && e.keyBindings[keys[n-1]] == "record_macro" {
+		keys = keys[:n-1] // the key that stopped the recording
+	}
+	e.macro = nil
+	e.lastMacro = keys
+	e.setStatusMsg("Recorded a macro of %d keys", len(keys))
+}
+
+func (e *Editor) playMacro() {
+	switch {
+	case e.macro != nil:
+		e.setStatusMsg("can not play a macro while recording one")
+		e.bell()
+	case len(e.lastMacro) == 0:
+		e.setStatusMsg("No macro recorded")
+		e.bell()
+	default:
+		e.stopAfterAction(e.playKeys(e.lastMacro))
+	}
+}
+
+/* playKeys handles keys as if they were typed, and returns whether one of them quit the editor or why keys could not be read. */
+func (e *Editor) playKeys(keys []int) (bool, error) {
+	if e.replaying {
+		e.setStatusMsg("can not play a macro from a macro")
+		e.bell()
+		return false, nil
+	}
+	e.replaying = true
+	e.replayKeys = append([]int{}, keys...)
+	defer func() {
+		e.replaying = false
+		e.replayKeys = nil
+	}()
+	for len(e.replayKeys) > 0 {
+		if quit, err := e.processKey(e.isReadonly()); quit || err != nil {
+			return quit, err
+		}
+	}
+	return false, nil
+}
+
+func (e *Editor) saveMacro() {
+	if len(e.lastMacro) == 0 {
+		e.setStatusMsg("No macro recorded")
+		e.bell()
+		return
+	}
+	name, ok := e.Prompt("Save macro as: ", func(s string) error {
+		if s == "" || strings.ContainsAny(s, " \t") {
+			return fmt.Errorf("a name without spaces")
+		}
+		if _, isAction := e.actionDispatch[s]; isAction && e.macros[s] == nil {
+			return fmt.Errorf("%s is an action", s)
+		}
+		return nil
+	}, nil)
+	if !ok {
+		return
+	}
+	e.defineMacro(name, e.lastMacro)
+
+	path := macrosFile()
+	if e.isolated || path == "" {
+		e.setStatusMsg("Macro %s defined for this session", name)
+		return
+	}
+	texts := map[string]string{}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &texts)
+	}
+	texts[name] = macroText(e.lastMacro)
+	data, err := json.MarshalIndent(texts, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(path, append(data, '\n'), 0600)
+	}
+	if err != nil {
+		e.setStatusMsg("saving macro %s: %s", name, err)
+		return
+	}
+	e.setStatusMsg("Macro %s saved, bind it to a key in keymap.json", name)
+}
@@ -0,0 +1,21 @@
+package editor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMacroQuits(t *testing.T) {
+	h := NewHeadless(8, 40)
+	h.Type("\x14") // play_macro
+	h.Type("x")
+	e := New(WithTerminal(h))
+	e.isolated = true
+	e.lastMacro = []int{'a', ctrlKey('q'), ctrlKey('q')} // the second quits with unsaved changes
+	if err := e.Run(context.Background(), []byte("text\n")); err != nil {
+		t.Fatalf("the editor did not quit: %v", err)
+	}
+	if got, want := e.linesToString(), "atext\n"; got != want {
+		t.Errorf("text is %q, want %q", got, want)
+	}
+}
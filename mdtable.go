@@ -0,0 +1,207 @@
+package editor
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * Markdown tables
+ *
+ * The "format_table" action formats the markdown table around the cursor, the
+ * lines with a | next to it: cells are padded to the widest in their column
+ * as the separator row aligns them, every row gets the pipes at both ends and
+ * as many cells as the widest, and the separator row is written with dashes
+ * across the column. The cursor stays in its cell.
+ */
+
+var tableSeparatorRx = regexp.MustCompile(`^:?-+:?$`)
+
+/* tableCells splits a table row into its trimmed cells, leaving \| and pipes in code spans alone, and returns the cell at byte x. */
+func tableCells(row string, x int) ([]string, int) {
+	s := strings.TrimSpace(row)
+	lead := strings.Index(row, s)
+	s = strings.TrimPrefix(s, "|")
+	if strings.HasSuffix(s, "|") && !strings.HasSuffix(s, `\|`) {
+		s = s[:len(s)-1]
+	}
+	offset := len(row) - len(strings.TrimLeft(row[lead:], "|")) // bytes before s in row
+
+	cells := []string{}
+	at := 0
+	start, code := 0, false
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch {
+			case s[i] == '\\':
+				i++
+				continue
+			case s[i] == '`':
+				code = !code
+				continue
+			case s[i] != '|' || code:
+				continue
+			}
+		}
+		if x >= offset+start {
+			at = len(cells)
+		}
+		cells = append(cells, strings.TrimSpace(s[start:i]))
+		start = i + 1
+	}
+	return cells, at
+}
+
+/* formatTable formats the rows of a markdown table, indented by indent. */
+func formatTable(rows [][]string, indent string) []string {
+	cols := 0
+	for _, r := range rows {
+		if len(r) > cols {
+			cols = len(r)
+		}
+	}
+	separator := -1
+	align := make([]string, cols) // "l", "c", "r" or "" for each column
+	for i, r := range rows {
+		isSep := len(r) > 0
+		for _, c := range r {
+			isSep = isSep && tableSeparatorRx.MatchString(c)
+		}
+		if isSep && separator < 0 {
+			separator = i
+			for j, c := range r {
+				switch {
+				case strings.HasPrefix(c, ":") && strings.HasSuffix(c, ":"):
+					align[j] = "c"
+				case strings.HasSuffix(c, ":"):
+					align[j] = "r"
+				case strings.HasPrefix(c, ":"):
+					align[j] = "l"
+				}
+			}
+		}
+	}
+
+	widths := make([]int, cols)
+	for i := range widths {
+		widths[i] = 3
+	}
+	for i, r := range rows {
+		for j, c := range r {
+			if n := utf8.RuneCountInString(c); i != separator && n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	out := make([]string, len(rows))
+	for i, r := range rows {
+		var sb strings.Builder
+		sb.WriteString(indent + "|")
+		for j := 0; j < cols; j++ {
+			c := ""
+			if j < len(r) {
+				c = r[j]
+			}
+			w := widths[j]
+			if i == separator {
+				dashes := w
+				if align[j] == "l" || align[j] == "c" {
+					sb.WriteString(" :")
+					dashes--
+				} else {
+					sb.WriteString(" ")
+				}
+				if align[j] == "r" || align[j] == "c" {
+					dashes--
+				}
+				sb.WriteString(strings.Repeat("-", dashes))
+				if align[j] == "r" || align[j] == "c" {
+					sb.WriteString(":")
+				}
+				sb.WriteString(" |")
+				continue
+			}
+			pad := w - utf8.RuneCountInString(c)
+			left := 0
+			switch align[j] {
+			case "r":
+				left = pad
+			case "c":
+				left = pad / 2
+			}
+			sb.WriteString(" " + strings.Repeat(" ", left) + c + strings.Repeat(" ", pad-left) + " |")
+		}
+		out[i] = sb.String()
+	}
+	return out
+}
+
+func (e *Editor) formatTable() {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	y := e.buf.cursor.y
+	isRow := func(y int) bool {
+		return y >= 0 && y < len(e.buf.lines) && strings.ContainsRune(string(e.buf.lines[y].chars), '|')
+	}
+	if !isRow(y) {
+		e.setStatusMsg("No table at the cursor")
+		e.bell()
+		return
+	}
+	first, last := y, y
+	for isRow(first - 1) {
+		first--
+	}
+	for isRow(last + 1) {
+		last++
+	}
+	if !e.canEdit(first, last) {
+		return
+	}
+
+	rows := [][]string{}
+	cell := 0
+	for r := first; r <= last; r++ {
+		l := string(e.buf.lines[r].chars)
+		x := -1
+		if r == y {
+			x = len(string(e.buf.lines[r].chars[:e.buf.cursor.x]))
+		}
+		cells, at := tableCells(l, x)
+		if r == y {
+			cell = at
+		}
+		rows = append(rows, cells)
+	}
+	first0 := string(e.buf.lines[first].chars)
+	indent := first0[:len(first0)-len(strings.TrimLeft(first0, " \t"))]
+
+	for i, l := range formatTable(rows, indent) {
+		e.buf.lines[first+i].chars = []rune(l)
+		e.buf.lines[first+i].render = e.updateRow(e.buf.lines[first+i].chars)
+	}
+	e.buf.dirty = true
+	e.changes++
+
+	/* put the cursor at the start of the text of its cell */
+	chars := e.buf.lines[y].chars
+	pipes, code := 0, false
+	for x, r := range chars {
+		if r == '`' {
+			code = !code
+		}
+		if r == '|' && !code && (x == 0 || chars[x-1] != '\\') {
+			if pipes == cell {
+				e.setCursor(point{x: x + 2, y: y})
+				break
+			}
+			pipes++
+		}
+	}
+	e.snapCursor()
+}
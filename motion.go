@@ -0,0 +1,80 @@
+package editor
+
+import "sort"
+
+/*-----------------------------------------------------------------------------
+ * Motions
+ *
+ * "line_start", home and ctrl+a, goes to the first character of the line
+ * that is not a space or a tab, and from there, or from within the
+ * indentation, to column 0, so pressing it twice toggles between the two.
+ * "first_nonblank_down" goes to the first such character of the next line.
+ *
+ * Moving up and down keeps to a goal column, the screen column the cursor
+ * was in before the first of a run of vertical moves, so passing through
+ * short lines puts the cursor back in that column on longer ones.
+ */
+
+type goalColumn struct {
+	rx int   // screen column to keep to
+	at point // where the last vertical move left the cursor
+}
+
+/* firstNonblank returns the column of the first character of line y that is not a space or a tab. */
+func (b *buffer) firstNonblank(y int) int {
+	if y >= len(b.lines) {
+		return 0
+	}
+	return len([]rune(indentation(b.lines[y].chars)))
+}
+
+/* goalRx returns the column vertical moves keep to, the column of the cursor unless it was last moved vertically. */
+func (e *Editor) goalRx() int {
+	b := e.buf
+	if b.goal != nil && b.goal.at == b.cursor {
+		return b.goal.rx
+	}
+	if b.cursor.y >= len(b.lines) {
+		return 0
+	}
+	l := b.lines[b.cursor.y]
+	if b.cursor.x > len(l.chars) {
+		return e.lineRx(l, len(l.chars)) // set past the end by moves that do not snap
+	}
+	return e.lineRx(l, b.cursor.x)
+}
+
+/* lineCx returns the character of l at screen column rx, or the end of the line if it is shorter. */
+func (e *Editor) lineCx(l line, rx int) int {
+	return sort.Search(len(l.chars), func(x int) bool { return e.lineRx(l, x+1) > rx })
+}
+
+/* moveVertically moves the cursor to line y, in the goal column. */
+func (e *Editor) moveVertically(y int) {
+	b := e.buf
+	rx := e.goalRx()
+	b.cursor.y, b.cursor.x = y, 0
+	if y < len(b.lines) {
+		b.cursor.x = e.lineCx(b.lines[y], rx)
+	}
+	b.goal = &goalColumn{rx: rx, at: b.cursor}
+}
+
+func (e *Editor) lineStart() {
+	b := e.buf
+	if x := b.firstNonblank(b.cursor.y); b.cursor.x > x || (b.cursor.x == 0 && x > 0) {
+		b.cursor.x = x
+		return
+	}
+	b.cursor.x = 0
+}
+
+func (e *Editor) firstNonblankDown() {
+	b := e.buf
+	if b.cursor.y+1 >= len(b.lines) {
+		e.bell()
+		return
+	}
+	b.cursor.y++
+	b.cursor.x = b.firstNonblank(b.cursor.y)
+}
@@ -0,0 +1,35 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPageMovesAfterLineEnd(t *testing.T) {
+	for _, key := range []string{"\x1b[6~", "\x1b[5~"} {
+		h := NewHeadless(6, 40)
+		h.Type("\x05" + key + "X")
+		text, err := h.Run([]byte("hello\nb\nc\nd\ne\nf\ng\nh\n"))
+		if err != nil {
+			t.Fatalf("%q: %v", key, err)
+		}
+		if !strings.Contains(text, "X") {
+			t.Errorf("%q: X was not typed, text is %q", key, text)
+		}
+	}
+}
+
+func TestGoalColumn(t *testing.T) {
+	h := NewHeadless(8, 40)
+	h.Type("\x05")
+	h.Type("\x1b[B")
+	h.Type("\x1b[B")
+	h.Type("X")
+	text, err := h.Run([]byte("abcdef\nab\nabcdefgh\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "abcdef\nab\nabcdefXgh\n"; text != want {
+		t.Errorf("text is %q, want %q", text, want)
+	}
+}
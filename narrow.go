@@ -0,0 +1,129 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Narrowing
+ *
+ * The "narrow" action asks for a range of lines and hides the rest of the
+ * buffer, so that moving, searching and editing, by keys, scripts or plugins,
+ * only reach those lines. The hidden lines are kept aside and are still
+ * saved with the buffer. "widen" shows the whole buffer again. Line numbers
+ * in the status bar stay those of the whole buffer.
+ */
+
+type narrowing struct {
+	above []line // lines hidden above the shown ones
+	below []line // lines hidden below the shown ones
+}
+
+/* hiddenAbove returns the number of lines narrowing hides above the shown ones. */
+func (b *buffer) hiddenAbove() int {
+	if b.narrow == nil {
+		return 0
+	}
+	return len(b.narrow.above)
+}
+
+/* allLines returns the lines of b, the hidden ones included. */
+func (b *buffer) allLines() []line {
+	if b.narrow == nil {
+		return b.lines
+	}
+	all := make([]line, 0, len(b.narrow.above)+len(b.lines)+len(b.narrow.below))
+	all = append(all, b.narrow.above...)
+	all = append(all, b.lines...)
+	return append(all, b.narrow.below...)
+}
+
+/* parseLineRange parses "from-to" or a single line, one based, into zero based lines within n lines. */
+func parseLineRange(s string, n int) (int, int, error) {
+	from, to, found := strings.Cut(strings.TrimSpace(s), "-")
+	a, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("not a line range, such as 10-20")
+	}
+	b := a
+	if found {
+		if b, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return 0, 0, fmt.Errorf("not a line range, such as 10-20")
+		}
+	}
+	if a < 1 || b < a || b > n {
+		return 0, 0, fmt.Errorf("lines 1 to %d", n)
+	}
+	return a - 1, b - 1, nil
+}
+
+func (e *Editor) narrow() {
+	if e.collab != nil {
+		e.setStatusMsg("can not narrow in a collaboration session")
+		e.bell()
+		return
+	}
+	if len(e.buf.protected) > 0 {
+		e.setStatusMsg("can not narrow a buffer with protected lines")
+		e.bell()
+		return
+	}
+	if len(e.buf.lines) == 0 {
+		e.setStatusMsg("buffer is empty")
+		return
+	}
+	input, ok := e.Prompt("Narrow to lines: ", func(s string) error {
+		_, _, err := parseLineRange(s, len(e.buf.lines))
+		return err
+	}, nil)
+	if !ok {
+		return
+	}
+	from, to, _ := parseLineRange(input, len(e.buf.lines))
+	e.narrowTo(from, to)
+	e.setStatusMsg("Narrowed to %d lines, widen shows the whole buffer", to-from+1)
+}
+
+/* narrowTo hides the lines of the current buffer outside from to to, which are shown ones. */
+func (e *Editor) narrowTo(from, to int) {
+	b := e.buf
+	n := b.narrow
+	if n == nil {
+		n = &narrowing{}
+	}
+	above := append(append([]line{}, n.above...), b.lines[:from]...)
+	below := append(append([]line{}, b.lines[to+1:]...), n.below...)
+	b.narrow = &narrowing{above: above, below: below}
+	b.lines = append([]line{}, b.lines[from:to+1]...)
+
+	b.cursor.y -= from
+	if b.cursor.y < 0 || b.cursor.y >= len(b.lines) {
+		b.cursor = point{}
+	}
+	b.fileY = 0
+	e.snapCursor()
+}
+
+/* widen shows the hidden lines of the current buffer again. */
+func (e *Editor) widen() {
+	b := e.buf
+	if b.narrow == nil {
+		return
+	}
+	above := len(b.narrow.above)
+	b.lines = b.allLines()
+	b.narrow = nil
+	b.cursor.y += above
+	b.fileY += above
+}
+
+func (e *Editor) widenAction() {
+	if e.buf.narrow == nil {
+		e.setStatusMsg("buffer is not narrowed")
+		return
+	}
+	e.widen()
+	e.setStatusMsg("Showing the whole buffer")
+}
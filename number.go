@@ -0,0 +1,114 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * Increment numbers
+ *
+ * The "increment_number" and "decrement_number" actions add one to or subtract
+ * one from the number under or after the cursor on its line, and leave the
+ * cursor on its last digit. Decimal numbers keep their leading zeros and hex
+ * numbers, 0x1f, keep their width and case. With the ctrl+u count prefix they
+ * add or subtract the count, since the key is handled that many times.
+ */
+
+var numberRx = regexp.MustCompile(`0[xX][0-9a-fA-F]+|-?[0-9]+`)
+
+/* findNumber returns the rune offsets of the number under or after x in chars. */
+func findNumber(chars []rune, x int) (int, int, bool) {
+	s := string(chars)
+	for _, m := range numberRx.FindAllStringIndex(s, -1) {
+		from := utf8.RuneCountInString(s[:m[0]])
+		to := from + utf8.RuneCountInString(s[m[0]:m[1]])
+		if chars[from] == '-' && from > 0 && (unicode.IsLetter(chars[from-1]) || unicode.IsDigit(chars[from-1])) {
+			from++ // a minus, not a sign
+		}
+		if to > x {
+			return from, to, true
+		}
+	}
+	return 0, 0, false
+}
+
+/* addToNumber returns the number num plus delta, written like num. */
+func addToNumber(num string, delta int64) (string, error) {
+	if hex, ok := strings.CutPrefix(strings.ToLower(num), "0x"); ok {
+		n, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return "", err
+		}
+		out := fmt.Sprintf("%0*x", len(hex), n+uint64(delta))
+		if strings.ToLower(num[2:]) != num[2:] {
+			out = strings.ToUpper(out)
+		}
+		return num[:2] + out, nil
+	}
+
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	sum := n + delta
+	if (delta > 0 && sum < n) || (delta < 0 && sum > n) {
+		return "", fmt.Errorf("%s%+d does not fit", num, delta)
+	}
+	digits := strings.TrimPrefix(num, "-")
+	if len(digits) > 1 && digits[0] == '0' { // keep the width of leading zeros
+		if sum < 0 {
+			return fmt.Sprintf("-%0*d", len(digits), -sum), nil
+		}
+		return fmt.Sprintf("%0*d", len(digits), sum), nil
+	}
+	return strconv.FormatInt(sum, 10), nil
+}
+
+func (e *Editor) addToNumberAtCursor(delta int64) {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	c := e.buf.cursor
+	if c.y >= len(e.buf.lines) {
+		e.bell()
+		return
+	}
+	chars := e.buf.lines[c.y].chars
+	from, to, ok := findNumber(chars, c.x)
+	if !ok {
+		e.setStatusMsg("No number at or after the cursor")
+		e.bell()
+		return
+	}
+	if !e.canEdit(c.y, c.y) {
+		return
+	}
+	num, err := addToNumber(string(chars[from:to]), delta)
+	if err != nil {
+		e.setStatusMsg("%s", err)
+		e.bell()
+		return
+	}
+
+	l := &e.buf.lines[c.y]
+	l.chars = append(append(append([]rune{}, chars[:from]...), []rune(num)...), chars[to:]...)
+	l.render = e.updateRow(l.chars)
+	e.buf.dirty = true
+	e.changes++
+	e.setCursor(point{x: from + len([]rune(num)) - 1, y: c.y})
+}
+
+func (e *Editor) incrementNumber() {
+	e.addToNumberAtCursor(1)
+}
+
+func (e *Editor) decrementNumber() {
+	e.addToNumberAtCursor(-1)
+}
@@ -0,0 +1,105 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Numbering lines
+ *
+ * The "number_lines" action puts an incrementing number in front of the text
+ * of each selected line, after its indentation. It asks for the numbering as
+ * "start,step,width,separator", all optional: "1,1,0,. " numbers the lines
+ * "1. ", "2. " and so on, "10,10" counts in tens, a width of 3 pads the
+ * numbers with spaces to three digits and 03 pads them with zeros. The
+ * separator is the rest of the input after the third comma.
+ */
+
+type numbering struct {
+	start, step int
+	width       int
+	zeros       bool // pad with zeros rather than spaces
+	sep         string
+}
+
+/* parseNumbering reads the numbering given to number_lines. */
+func parseNumbering(s string) (numbering, error) {
+	n := numbering{start: 1, step: 1, sep: ". "}
+	fields := strings.SplitN(s, ",", 4)
+	for i, f := range fields {
+		if i == 3 {
+			n.sep = f
+			break
+		}
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return n, fmt.Errorf("%q is not a number, give start,step,width,separator", f)
+		}
+		switch i {
+		case 0:
+			n.start = v
+		case 1:
+			n.step = v
+		case 2:
+			if v < 0 || v > 20 {
+				return n, fmt.Errorf("width must be from 0 to 20")
+			}
+			n.width, n.zeros = v, strings.HasPrefix(f, "0") && v > 0
+		}
+	}
+	return n, nil
+}
+
+/* label returns the number of line i, counting from 0, with its padding and separator. */
+func (n numbering) label(i int) string {
+	v := n.start + i*n.step
+	if n.zeros {
+		return fmt.Sprintf("%0*d%s", n.width, v, n.sep)
+	}
+	return fmt.Sprintf("%*d%s", n.width, v, n.sep)
+}
+
+func (e *Editor) numberLines() {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	first, last, ok := e.buf.selectedLines()
+	if !ok {
+		e.setStatusMsg("Select the lines first, set_mark starts a selection")
+		e.bell()
+		return
+	}
+	if !e.canEdit(first, last) {
+		return
+	}
+
+	input, ok := e.Prompt("Number lines (start,step,width,separator): ", func(s string) error {
+		_, err := parseNumbering(s)
+		return err
+	}, nil)
+	if !ok {
+		return
+	}
+	n, _ := parseNumbering(input)
+
+	for y := first; y <= last; y++ {
+		chars := e.buf.lines[y].chars
+		l := string(chars)
+		text := strings.TrimLeft(l, " \t")
+		indent := l[:len(l)-len(text)]
+		e.buf.lines[y].chars = []rune(indent + n.label(y-first) + text)
+		e.buf.lines[y].render = e.updateRow(e.buf.lines[y].chars)
+	}
+	e.buf.dirty = true
+	e.changes++
+	e.snapCursor()
+	e.setStatusMsg("Numbered %d lines", last-first+1)
+}
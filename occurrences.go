@@ -0,0 +1,65 @@
+package editor
+
+/*-----------------------------------------------------------------------------
+ * Occurrences
+ *
+ * When the cursor rests on a word for a moment, the other occurrences of the
+ * word on the screen are highlighted, until the cursor moves or the text
+ * changes. The highlight_occurrences setting turns it off.
+ */
+
+const occurrenceStyle = "\x1b[1;4m"
+
+type occurrence struct {
+	buf     *buffer
+	at      point // cursor the word was found at
+	changes int   // e.changes when it was found
+	word    []rune
+}
+
+/* findOccurrences notes the word under the cursor for occurrenceMarks. */
+func (e *Editor) findOccurrences() {
+	e.occurrence = nil
+	b := e.buf
+	if e.occurrencesOff || b.cursor.y >= len(b.lines) {
+		return
+	}
+	chars := b.lines[b.cursor.y].chars
+	from, to, ok := wordAt(chars, b.cursor.x)
+	if !ok {
+		return
+	}
+	e.occurrence = &occurrence{buf: b, at: b.cursor, changes: e.changes, word: chars[from:to]}
+}
+
+/* occurrenceMarks highlights the whole-word occurrences of the word under the cursor on the screen. */
+func (e *Editor) occurrenceMarks(marks map[int]map[int]string) map[int]map[int]string {
+	o := e.occurrence
+	if o == nil || o.buf != e.buf || o.at != e.buf.cursor || o.changes != e.changes {
+		return marks
+	}
+	n := len(o.word)
+	for y := e.buf.fileY; y < e.buf.fileY+e.termRows && y < len(e.buf.lines); y++ {
+		l := e.buf.lines[y]
+		for x := 0; x+n <= len(l.chars); x++ {
+			if l.chars[x] != o.word[0] || (x > 0 && isWordChar(l.chars[x-1])) || (x+n < len(l.chars) && isWordChar(l.chars[x+n])) ||
+				string(l.chars[x:x+n]) != string(o.word) {
+				continue
+			}
+			if marks == nil {
+				marks = map[int]map[int]string{}
+			}
+			if marks[y] == nil {
+				marks[y] = map[int]string{}
+			}
+			for i := x; i < x+n; i++ {
+				rx := e.lineRx(l, i)
+				if _, ok := marks[y][rx]; !ok {
+					marks[y][rx] = occurrenceStyle
+				}
+			}
+			x += n - 1
+		}
+	}
+	return marks
+}
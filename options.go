@@ -0,0 +1,397 @@
+package editor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Options
+ */
+
+// Option configures an editor created by New.
+type Option func(*Editor)
+
+// WithTerminal runs the editor in term instead of the controlling terminal.
+func WithTerminal(term Terminal) Option {
+	return func(e *Editor) { e.term = term }
+}
+
+// WithReadOnly does not allow the buffer to be edited.
+func WithReadOnly() Option {
+	return func(e *Editor) { e.readonly = true }
+}
+
+// WithSaveWriter makes saving the buffer the editor was started on write its
+// contents to w instead of a file. Every save writes the whole buffer.
+func WithSaveWriter(w io.Writer) Option {
+	return func(e *Editor) { e.saveWriter = w }
+}
+
+// SaveFunc saves the contents of the buffer editing the file name. An error
+// is shown to the user and leaves the buffer unsaved.
+type SaveFunc func(name string, data []byte) error
+
+// WithSaveFunc makes saving a buffer call fn instead of writing the file.
+func WithSaveFunc(fn SaveFunc) Option {
+	return func(e *Editor) { e.saveFunc = fn }
+}
+
+// WithHook registers fn to be called every time the hook h is run in this
+// editor. The callback runs on the editor's main loop.
+func WithHook(h Hook, fn func(HookEvent)) Option {
+	return func(e *Editor) { e.addHook(h, fn) }
+}
+
+// WithEvents makes the editor send an event on ch every time a hook is run,
+// for example when the text changes or a buffer is saved. Events are dropped
+// when ch is full, so the editor never waits for the receiver.
+func WithEvents(ch chan<- HookEvent) Option {
+	return func(e *Editor) { e.events = ch }
+}
+
+// WithStatusProvider adds the text returned by fn, such as a validation
+// state, to the status bar. It is called every time the screen is drawn and
+// the text is shortened when there is not enough room.
+func WithStatusProvider(fn func(*Editor) string) Option {
+	return func(e *Editor) { e.statusProviders = append(e.statusProviders, fn) }
+}
+
+// WithKeymapFile loads key bindings from path instead of keymap.json in the
+// configuration directory. The file holds a JSON object mapping key names,
+// such as "ctrl+b", to action names.
+func WithKeymapFile(path string) Option {
+	return func(e *Editor) { e.keymapFile = path }
+}
+
+// WithFiles opens the named files in buffers of their own next to the
+// buffer of the source the editor is run on, which stays the current one.
+func WithFiles(names ...string) Option {
+	return func(e *Editor) { e.files = append(e.files, names...) }
+}
+
+// WithLine puts the cursor on line n, counting from 1, of the source the
+// editor is run on.
+func WithLine(n int) Option {
+	return func(e *Editor) { e.startLine = n }
+}
+
+// WithTabStop sets the number of columns a tab is drawn as.
+func WithTabStop(n int) Option {
+	return func(e *Editor) {
+		if n > 0 {
+			e.tabStop = n
+		}
+	}
+}
+
+// WithColorColumns draws rulers at the one based columns cols and colours the
+// characters past the last of them.
+func WithColorColumns(cols ...int) Option {
+	return func(e *Editor) {
+		e.colorColumns = nil
+		for _, c := range cols {
+			if c > 0 {
+				e.colorColumns = append(e.colorColumns, c)
+			}
+		}
+		sort.Ints(e.colorColumns)
+	}
+}
+
+// WithTheme sets the colours used to draw the screen.
+func WithTheme(t Theme) Option {
+	return func(e *Editor) { e.theme = t }
+}
+
+// WithStatusTimeout sets how long status messages are shown.
+func WithStatusTimeout(d time.Duration) Option {
+	return func(e *Editor) { e.statusMsgTimeout = d.Seconds() }
+}
+
+// WithEscapeTimeout sets how long the editor waits for the rest of an escape
+// sequence before it takes an escape as the escape key. Slow connections may
+// need more than the default 50 milliseconds.
+func WithEscapeTimeout(d time.Duration) Option {
+	return func(e *Editor) { e.escTimeout = d }
+}
+
+/* setOption changes the setting name, as done by editor.set in init.lua. */
+func (e *Editor) setOption(name, value string) error {
+	switch name {
+	case "bell":
+		mode, err := parseBellMode(value)
+		if err != nil {
+			return err
+		}
+		e.bellMode = mode
+		return nil
+
+	case "sudo_command":
+		e.sudoCommand = strings.Fields(value)
+		return nil
+
+	case "autosave":
+		on, err := parseFlag(value)
+		if err != nil {
+			return err
+		}
+		e.autosave = on
+		return nil
+
+	case "escape_timeout": // milliseconds
+		ms, err := strconv.Atoi(value)
+		if err != nil || ms < 0 {
+			return fmt.Errorf("escape_timeout must be a number of milliseconds")
+		}
+		e.escTimeout = time.Duration(ms) * time.Millisecond
+		return nil
+
+	case "swatches":
+		on, err := parseFlag(value)
+		if err != nil {
+			return err
+		}
+		e.swatchesOff = !on
+		return nil
+
+	case "audit_log":
+		e.auditLog = value
+		return nil
+
+	case "pprof": // address to serve the pprof endpoints on, or off
+		return e.startPprof(value)
+
+	case "low_bandwidth":
+		on, err := parseFlag(value)
+		if err != nil {
+			return err
+		}
+		e.lowBandwidth = on
+		e.invalidateScreen()
+		return nil
+
+	case "profile":
+		return e.setProfile(value)
+
+	case "elastic_tabstops":
+		on, err := parseFlag(value)
+		if err != nil {
+			return err
+		}
+		e.setElasticTabstops(on)
+		return nil
+
+	case "templates":
+		on, err := parseFlag(value)
+		if err != nil {
+			return err
+		}
+		e.templatesOff = !on
+		return nil
+
+	case "date_formats": // separated by |
+		formats := strings.Split(value, "|")
+		for _, f := range formats {
+			if f == "" {
+				return fmt.Errorf("date_formats must be formats separated by |")
+			}
+		}
+		e.dateFormats = formats
+		return nil
+
+	case "textwidth":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("textwidth must be a positive number of columns")
+		}
+		e.textWidth = n
+		return nil
+
+	case "highlight_occurrences":
+		on, err := parseFlag(value)
+		if err != nil {
+			return err
+		}
+		e.occurrencesOff = !on
+		return nil
+
+	case "git_status":
+		on, err := parseFlag(value)
+		if err != nil {
+			return err
+		}
+		e.setGitStatus(on)
+		return nil
+
+	case "hyperlinks": // on, off or auto
+		on := supportsHyperlinks()
+		if value != "auto" {
+			var err error
+			if on, err = parseFlag(value); err != nil {
+				return fmt.Errorf("hyperlinks must be on, off or auto")
+			}
+		}
+		e.hyperlinksOn = on
+		return nil
+
+	case "image_preview":
+		proto, err := parseImagePreview(value)
+		if err != nil {
+			return err
+		}
+		e.imagePreview = proto
+		return nil
+
+	case "keyboard_layout":
+		layout, err := parseLayout(value)
+		if err != nil {
+			return err
+		}
+		e.keyboardLayout = layout
+		return nil
+
+	case "auto_wrap":
+		mode, err := parseWrapMode(value)
+		if err != nil {
+			return err
+		}
+		e.autoWrap = mode
+		return nil
+
+	case "color_column":
+		cols, err := parseColorColumns(value)
+		if err != nil {
+			return err
+		}
+		e.colorColumns = cols
+		return nil
+
+	case "theme":
+		t, err := LoadTheme(value)
+		if err != nil {
+			return err
+		}
+		e.theme = t
+		return nil
+
+	case "title":
+		on, err := parseFlag(value)
+		if err != nil {
+			return err
+		}
+		e.titleOff = !on
+		if !on {
+			e.restoreTitle()
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown setting %q", name)
+}
+
+/* parseFlag parses the value of an on/off setting. */
+func parseFlag(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not on or off", value)
+}
+
+// Theme holds the SGR parameters, such as "1;34" or "48;5;236", the screen
+// is drawn with. An empty string draws in the terminal's default colours.
+type Theme struct {
+	Text      string // buffer text
+	EmptyLine string // the ~ on lines past the end of the buffer
+	StatusBar string
+	StatusMsg string
+	Link      string // URLs in the text, drawn on top of Text
+	Ruler     string // the color columns
+	LongLine  string // characters past the last color column
+}
+
+// DefaultTheme is the theme used when none is given.
+var DefaultTheme = Theme{StatusBar: "7", Link: "4", Ruler: "100", LongLine: "31"}
+
+// Themes are the themes that can be chosen by name.
+var Themes = map[string]Theme{
+	"default": DefaultTheme,
+	"dark":    {Text: "37", EmptyLine: "34", StatusBar: "30;46", StatusMsg: "36", Link: "4;36", Ruler: "48;5;236", LongLine: "91"},
+	"light":   {Text: "30", EmptyLine: "37", StatusBar: "97;44", StatusMsg: "34", Link: "4;34", Ruler: "48;5;254", LongLine: "31"},
+}
+
+/* sgr returns the escape sequence selecting the graphic rendition params. */
+func sgr(params string) string {
+	return "\x1b[" + params + "m"
+}
+
+/*-----------------------------------------------------------------------------
+ * Keymap
+ */
+
+/* loadKeymap binds the keys in the keymap file, if there is one. */
+func (e *Editor) loadKeymap() {
+	path := e.keymapFile
+	if path == "" {
+		if e.isolated || configDir() == "" {
+			return
+		}
+		path = filepath.Join(configDir(), "keymap.json")
+		if _, err := os.Stat(path); err != nil {
+			return
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		e.setStatusMsg("keymap: %s", err)
+		return
+	}
+
+	var keymap struct {
+		Layout  string                       `json:"layout"`
+		Layouts map[string]map[string]string `json:"layouts"`
+	}
+	bindings := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &keymap); err != nil {
+		e.setStatusMsg("keymap %s: %s", path, err)
+		return
+	}
+	json.Unmarshal(data, &bindings)
+	if keymap.Layout != "" {
+		layout, err := parseLayout(keymap.Layout)
+		if err != nil {
+			e.setStatusMsg("keymap %s: %s", path, err)
+		} else {
+			e.keyboardLayout = layout
+		}
+	}
+
+	for key, raw := range bindings {
+		if key == "layout" || key == "layouts" {
+			continue
+		}
+		var action string
+		if err := json.Unmarshal(raw, &action); err != nil {
+			e.setStatusMsg("keymap %s: the action for %q is not a string", path, key)
+			continue
+		}
+		if err := e.bindKey(key, action); err != nil {
+			e.setStatusMsg("keymap %s: %s", path, err)
+		}
+	}
+	for key, action := range keymap.Layouts[e.keyboardLayout] {
+		if err := e.bindKey(key, action); err != nil {
+			e.setStatusMsg("keymap %s: %s", path, err)
+		}
+	}
+}
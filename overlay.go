@@ -0,0 +1,200 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Overlays
+ *
+ * Overlays are bordered boxes drawn over the buffer, used for pickers, help
+ * and dialogs. They are stacked; the topmost one is drawn last and gets the
+ * keys the user types until it closes.
+ */
+
+type overlay struct {
+	title       string
+	lines       []string
+	sel         int              // highlighted line, -1 for none
+	top         int              // first line shown
+	titleCursor bool             // put the cursor after the title, as for typed input
+	key         func(k int) bool // handles a key, returns false to close the overlay
+}
+
+func (e *Editor) openOverlay(o *overlay) {
+	e.overlays = append(e.overlays, o)
+}
+
+func (e *Editor) closeOverlay(o *overlay) {
+	for i, q := range e.overlays {
+		if q == o {
+			e.overlays = append(e.overlays[:i], e.overlays[i+1:]...)
+			return
+		}
+	}
+}
+
+func (e *Editor) topOverlay() *overlay {
+	if len(e.overlays) == 0 {
+		return nil
+	}
+	return e.overlays[len(e.overlays)-1]
+}
+
+/* overlayKey gives k to the topmost overlay and reports whether there was one. */
+func (e *Editor) overlayKey(k int) bool {
+	o := e.topOverlay()
+	if o == nil {
+		return false
+	}
+	if !o.key(k) {
+		e.closeOverlay(o)
+	}
+	return true
+}
+
+/* runOverlay opens o and reads keys for it until it closes. */
+func (e *Editor) runOverlay(o *overlay) error {
+	e.openOverlay(o)
+	defer e.closeOverlay(o)
+
+	for {
+		e.refreshScreen()
+		k, err := e.readKey()
+		if err != nil {
+			return err
+		}
+		if !o.key(k) {
+			return nil
+		}
+	}
+}
+
+/* scrollKey moves the highlighted line, or scrolls if there is none, and reports whether k was a movement key. */
+func (o *overlay) scrollKey(k int) bool {
+	switch k {
+	case kArrowUp, ctrlKey('p'):
+		if o.sel > 0 {
+			o.sel--
+		} else if o.sel < 0 && o.top > 0 {
+			o.top--
+		}
+	case kArrowDown, ctrlKey('n'):
+		if o.sel >= 0 && o.sel < len(o.lines)-1 {
+			o.sel++
+		} else if o.sel < 0 && o.top < len(o.lines)-1 {
+			o.top++
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+/* drawOverlays draws the overlays bottom up and reports whether the cursor was placed. */
+func (e *Editor) drawOverlays(scrBuf *bytes.Buffer) bool {
+	placed := false
+	for _, o := range e.overlays {
+		placed = e.drawOverlay(scrBuf, o)
+	}
+	return placed
+}
+
+func (e *Editor) drawOverlay(scrBuf *bytes.Buffer, o *overlay) bool {
+	width := len([]rune(o.title)) + 2
+	for _, l := range o.lines {
+		if n := len([]rune(l)); n > width {
+			width = n
+		}
+	}
+	if width > e.termCols-4 {
+		width = e.termCols - 4
+	}
+	height := len(o.lines)
+	if height > e.termRows-2 {
+		height = e.termRows - 2
+	}
+	if width < 1 || height < 0 {
+		return false
+	}
+
+	if o.sel >= 0 {
+		if o.sel < o.top {
+			o.top = o.sel
+		}
+		if o.sel >= o.top+height {
+			o.top = o.sel - height + 1
+		}
+	}
+
+	row := (e.termRows-height-2)/2 + 1 // screen row of the top border, one based
+	col := (e.termCols-width-4)/2 + 1
+
+	fit := func(s string) string {
+		r := []rune(s)
+		if len(r) > width {
+			r = r[:width]
+		}
+		return string(r) + strings.Repeat(" ", width-len(r))
+	}
+
+	title := []rune(o.title)
+	if len(title) > width {
+		title = title[len(title)-width:]
+	}
+	fmt.Fprintf(scrBuf, "\x1b[%d;%dH┌─%s%s─┐", row, col, string(title), strings.Repeat("─", width-len(title)))
+	for i := 0; i < height; i++ {
+		text := ""
+		if o.top+i < len(o.lines) {
+			text = o.lines[o.top+i]
+		}
+		fmt.Fprintf(scrBuf, "\x1b[%d;%dH│ ", row+1+i, col)
+		if o.top+i == o.sel {
+			fmt.Fprint(scrBuf, "\x1b[7m"+fit(text)+"\x1b[m")
+		} else {
+			fmt.Fprint(scrBuf, fit(text))
+		}
+		fmt.Fprint(scrBuf, " │")
+	}
+	fmt.Fprintf(scrBuf, "\x1b[%d;%dH└─%s─┘", row+height+1, col, strings.Repeat("─", width))
+
+	if o.titleCursor {
+		fmt.Fprintf(scrBuf, "\x1b[%d;%dH", row, col+2+len(title))
+	}
+	return o.titleCursor
+}
+
+// ShowPopup shows lines in a box over the buffer until the user closes it
+// with escape, enter or q. ShowPopup must be called on the editor's main
+// loop and returns at once.
+func (e *Editor) ShowPopup(title string, lines []string) {
+	o := &overlay{title: title, lines: lines, sel: -1}
+	o.key = func(k int) bool {
+		if o.scrollKey(k) {
+			return true
+		}
+		return k != '\x1b' && k != '\r' && k != 'q'
+	}
+	e.openOverlay(o)
+}
+
+// Confirm asks the user a yes or no question in a dialog and reports whether
+// the answer was yes. Confirm must be called on the editor's main loop.
+func (e *Editor) Confirm(question string) bool {
+	yes := false
+	o := &overlay{title: "Confirm", lines: []string{question, "", "y: yes   n: no"}, sel: -1}
+	o.key = func(k int) bool {
+		switch k {
+		case 'y', 'Y':
+			yes = true
+			return false
+		case 'n', 'N', '\x1b':
+			return false
+		}
+		return true
+	}
+	e.runOverlay(o)
+	return yes
+}
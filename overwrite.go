@@ -0,0 +1,63 @@
+package editor
+
+import "strings"
+
+/*-----------------------------------------------------------------------------
+ * Overwrite mode
+ *
+ * The insert key, or the "toggle_overwrite" action, switches between
+ * inserting what is typed and overwriting the character under the cursor
+ * with it, also for pasted text. Typing at the end of a line adds to it in
+ * both modes, and the status bar says "overwrite" while overwriting.
+ */
+
+func (e *Editor) toggleOverwrite() {
+	e.overwrite = !e.overwrite
+	if e.overwrite {
+		e.setStatusMsg("Overwrite mode, insert goes back to inserting")
+	} else {
+		e.setStatusMsg("Insert mode")
+	}
+}
+
+/* typeChar inserts the typed character key at the cursor, or overwrites the character there in overwrite mode. */
+func (e *Editor) typeChar(key int) {
+	b := e.buf
+	if !e.overwrite || b.cursor.y >= len(b.lines) || b.cursor.x >= len(b.lines[b.cursor.y].chars) {
+		e.insertChar(key)
+		return
+	}
+	if !e.canEdit(b.cursor.y, b.cursor.y) {
+		return
+	}
+	l := &b.lines[b.cursor.y]
+	l.chars[b.cursor.x] = rune(key)
+	l.render = e.updateRow(l.chars)
+	b.cursor.x++
+	b.dirty = true
+	e.changes++
+	e.autoWrapLine()
+}
+
+/* overwriteText overwrites the text after the cursor with s line by line, adding to lines that are shorter, and puts the cursor after it. */
+func (e *Editor) overwriteText(s string) {
+	for i, part := range strings.Split(s, "\n") {
+		if i > 0 {
+			e.insertText("\n")
+		}
+		b := e.buf
+		c := b.cursor
+		if part == "" || !e.canEdit(c.y, c.y) {
+			continue
+		}
+		end := c
+		if c.y < len(b.lines) {
+			end.x += len([]rune(part))
+			if n := len(b.lines[c.y].chars); end.x > n {
+				end.x = n
+			}
+		}
+		e.replaceRange(c, end, part)
+		e.setCursor(point{x: c.x + len([]rune(part)), y: c.y})
+	}
+}
@@ -0,0 +1,82 @@
+package editor
+
+import "unicode"
+
+/*-----------------------------------------------------------------------------
+ * Pasting
+ *
+ * Terminals without bracketed paste send pasted text as if it was typed, but
+ * far faster than anyone types. When a typed character arrives with more
+ * input already waiting, the characters, tabs and line breaks that follow
+ * at once are read ahead. At least pasteMinimum of them are taken for a
+ * paste and inserted as one piece of text, overwriting in overwrite mode,
+ * without matching brackets, wrapping lines or redrawing the screen for
+ * every character. Fewer are typing that happened to arrive together, such
+ * as over a slow connection, and are handled key by key. The first key that
+ * is not text ends the paste and is handled as usual.
+ */
+
+const pasteMinimum = 16 // characters arriving at once that make a paste
+
+/* pasteKey returns the character key stands for in pasted text, and whether it is text. */
+func pasteKey(key int) (rune, bool) {
+	switch {
+	case key == '\r', key == ctrlKey('j'):
+		return '\n', true
+	case key == '\t':
+		return '\t', true
+	case key < kArrowUp && unicode.IsPrint(rune(key)):
+		return rune(key), true
+	}
+	return 0, false
+}
+
+/* pasting reports whether more input than the key just read is already waiting. */
+func (e *Editor) pasting() bool {
+	w, ok := e.term.(KeyWaiter)
+	return ok && len(e.replayKeys) == 0 && len(e.unread) == 0 && !e.replaying && w.WaitKey(0)
+}
+
+/* unreadKeys puts keys back to be read again, as if they had not been read. */
+func (e *Editor) unreadKeys(keys []int) {
+	if len(keys) == 0 {
+		return
+	}
+	e.unread = append(append([]int{}, keys...), e.unread...)
+	e.keysRead = e.keysRead[:len(e.keysRead)-len(keys)]
+	if e.macro != nil {
+		e.macro = e.macro[:len(e.macro)-len(keys)]
+	}
+}
+
+/* pasteBurst inserts key and the text arriving at once after it as one paste, and reports whether it did. */
+func (e *Editor) pasteBurst(key int) bool {
+	r, ok := pasteKey(key)
+	if !ok || !e.pasting() {
+		return false
+	}
+	keys, text := []int{key}, []rune{r}
+	for e.pasting() {
+		k, err := e.readKey()
+		if err != nil {
+			break
+		}
+		keys = append(keys, k)
+		r, ok := pasteKey(k)
+		if !ok {
+			break
+		}
+		text = append(text, r)
+	}
+	if len(text) < pasteMinimum {
+		e.unreadKeys(keys[1:])
+		return false
+	}
+	e.unreadKeys(keys[len(text):]) // the key ending the paste
+	if e.overwrite {
+		e.overwriteText(string(text))
+	} else {
+		e.insertText(string(text))
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package editor
+
+import "testing"
+
+func TestPaste(t *testing.T) {
+	long := "0123456789abcdefghij"
+	for _, tc := range []struct {
+		name  string
+		input func(h *Headless)
+		want  string
+	}{
+		{"typed keys", func(h *Headless) { h.Type("\x1b[2~"); h.Type("XY") }, "XYcd\n"},
+		{"typed control keys", func(h *Headless) { h.Type("ab\x05\x01c") }, "cababcd\n"},
+		{"short paste", func(h *Headless) { h.Paste("XY") }, "XYabcd\n"},
+		{"paste", func(h *Headless) { h.Paste(long) }, long + "abcd\n"},
+		{"paste overwriting", func(h *Headless) { h.Type("\x1b[2~"); h.Paste(long) }, long + "\n"},
+		{"paste overwriting lines", func(h *Headless) { h.Type("\x1b[2~"); h.Paste("0123456789\nXYZ" + long) }, "0123456789\nXYZ" + long + "\n"},
+	} {
+		h := NewHeadless(8, 40)
+		tc.input(h)
+		text, err := h.Run([]byte("abcd\n"))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if text != tc.want {
+			t.Errorf("%s: text is %q, want %q", tc.name, text, tc.want)
+		}
+	}
+}
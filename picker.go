@@ -0,0 +1,89 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+/*-----------------------------------------------------------------------------
+ * Prompts and pickers
+ */
+
+// Prompt asks the user for a line of input on the status line and reports
+// whether it was entered rather than cancelled. validate, if not nil, must
+// accept the input before it is returned. complete, if not nil, returns the
+// completions of the input that tab cycles through. Prompt must be called on
+// the editor's main loop.
+func (e *Editor) Prompt(msg string, validate func(string) error, complete func(string) []string) (string, bool) {
+	input, err := e.readPrompt(strings.ReplaceAll(msg, "%", "%%")+"%s", nil, validate, complete)
+	return input, err == nil
+}
+
+/* completeFrom returns a completion function offering the words starting with the input. */
+func completeFrom(words []string) func(string) []string {
+	return func(input string) []string {
+		matches := []string{}
+		for _, w := range words {
+			if strings.HasPrefix(w, input) {
+				matches = append(matches, w)
+			}
+		}
+		return matches
+	}
+}
+
+// Pick lets the user choose one of items from a list drawn over the buffer.
+// Typing filters the list. It returns the index of the chosen item and
+// whether one was chosen. Pick must be called on the editor's main loop.
+func (e *Editor) Pick(title string, items []string) (int, bool) {
+	var query []rune
+	var shown []int // indexes of the items matching the query
+	chosen := -1
+
+	o := &overlay{titleCursor: true}
+	filter := func() {
+		q := strings.ToLower(string(query))
+		shown = shown[:0]
+		o.lines = o.lines[:0]
+		for i, item := range items {
+			if strings.Contains(strings.ToLower(item), q) {
+				shown = append(shown, i)
+				o.lines = append(o.lines, item)
+			}
+		}
+		o.sel, o.top = 0, 0
+		o.title = fmt.Sprintf("%s (%d/%d): %s", title, len(shown), len(items), string(query))
+	}
+	filter()
+
+	o.key = func(k int) bool {
+		if o.scrollKey(k) {
+			return true
+		}
+		switch {
+		case k == '\x1b':
+			return false
+		case k == '\r':
+			if len(shown) == 0 {
+				return true
+			}
+			chosen = shown[o.sel]
+			return false
+		case k == kDelete || k == ctrlKey('h') || k == kBackSpace:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				filter()
+			}
+		case unicode.IsPrint(rune(k)):
+			query = append(query, rune(k))
+			filter()
+		}
+		return true
+	}
+
+	if err := e.runOverlay(o); err != nil || chosen < 0 {
+		return -1, false
+	}
+	return chosen, true
+}
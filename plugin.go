@@ -0,0 +1,369 @@
+package editor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+/*-----------------------------------------------------------------------------
+ * Plugins
+ *
+ * Plugins are external processes that talk to the editor using JSON-RPC 2.0,
+ * one message per line. Executables in plugins in the configuration directory
+ * are started with the editor and use their stdin/stdout. Other processes can
+ * connect to the unix socket named by the EDITOR_PLUGIN_SOCKET environment
+ * variable.
+ *
+ * Requests sent by a plugin (lines and columns are zero based):
+ *
+ *	register_command {"name": "...", "key": "ctrl+g"}
+ *	subscribe        {"events": ["BufOpen", "BufWritePost", "TextChanged", ...]}
+ *	buffer_info      {}
+ *	get_lines        {"start": 0, "end": 10}
+ *	set_line         {"line": 0, "text": "..."}
+ *	insert_line      {"line": 0, "text": "..."}
+ *	delete_line      {"line": 0}
+ *	get_cursor       {}
+ *	set_cursor       {"line": 0, "col": 0}
+ *	status           {"message": "..."}
+ *	prompt           {"message": "Name: ", "completions": ["..."]}
+ *	pick             {"title": "...", "items": ["...", ...]}
+ *
+ * Notifications sent by the editor:
+ *
+ *	command {"name": "..."}
+ *	event   {"event": "TextChanged", "file_name": "...", "line": 0, "col": 0, "line_count": 1}
+ */
+
+type plugin struct {
+	e      *Editor
+	name   string
+	mu     sync.Mutex    // serializes writes to the plugin
+	enc    *json.Encoder // encodes messages to the plugin
+	closer io.Closer     // closes the connection to the plugin
+	cmd    *exec.Cmd     // the plugin process, nil for socket plugins
+	events map[Hook]bool // subscribed events
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcEditorError    = -32000
+)
+
+func (e *Editor) startPlugins() {
+	if dir := configDir(); dir != "" {
+		dir = filepath.Join(dir, "plugins")
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+				continue
+			}
+			if err := e.startPluginProcess(filepath.Join(dir, entry.Name())); err != nil {
+				e.setStatusMsg("plugin %s: %s", entry.Name(), err)
+			}
+		}
+	}
+
+	if path := os.Getenv("EDITOR_PLUGIN_SOCKET"); path != "" {
+		os.Remove(path)
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			e.setStatusMsg("plugin socket: %s", err)
+			return
+		}
+		e.pluginListener = l
+
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				p := newPlugin(e, conn.RemoteAddr().String(), conn, conn, nil)
+				e.queueTask(func() { e.plugins = append(e.plugins, p) })
+				go p.serve(conn)
+			}
+		}()
+	}
+}
+
+func (e *Editor) startPluginProcess(path string) error {
+	cmd := exec.Command(path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	p := newPlugin(e, filepath.Base(path), stdin, stdin, cmd)
+	e.plugins = append(e.plugins, p)
+	go p.serve(stdout)
+	return nil
+}
+
+func (e *Editor) stopPlugins() {
+	if e.pluginListener != nil {
+		e.pluginListener.Close()
+		os.Remove(e.pluginListener.Addr().String())
+		e.pluginListener = nil
+	}
+
+	for _, p := range e.plugins {
+		p.closer.Close()
+		if p.cmd != nil {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+		}
+	}
+	e.plugins = nil
+}
+
+func newPlugin(e *Editor, name string, w io.Writer, closer io.Closer, cmd *exec.Cmd) *plugin {
+	return &plugin{
+		e:      e,
+		name:   name,
+		enc:    json.NewEncoder(w),
+		closer: closer,
+		cmd:    cmd,
+		events: map[Hook]bool{},
+	}
+}
+
+/* serve reads requests from the plugin and handles them on the main loop. */
+func (p *plugin) serve(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	for scanner.Scan() {
+		var req rpcRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			p.send(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+		p.e.queueTask(func() {
+			result, rerr := p.handle(req)
+			if req.ID == nil {
+				return // notifications get no response
+			}
+			p.send(rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr})
+		})
+	}
+
+	p.e.queueTask(func() { p.remove() })
+}
+
+func (p *plugin) remove() {
+	for i, q := range p.e.plugins {
+		if q == p {
+			p.e.plugins = append(p.e.plugins[:i], p.e.plugins[i+1:]...)
+			break
+		}
+	}
+	for name, owner := range p.e.pluginCommands {
+		if owner == p {
+			delete(p.e.actionDispatch, name)
+			delete(p.e.pluginCommands, name)
+		}
+	}
+}
+
+func (p *plugin) send(msg interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enc.Encode(msg)
+}
+
+func (p *plugin) notify(method string, params interface{}) {
+	p.send(rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+/* pluginEvent sends a hook event to the plugins subscribed to it. */
+func (e *Editor) pluginEvent(ev HookEvent) {
+	for _, p := range e.plugins {
+		if !p.events[ev.Hook] {
+			continue
+		}
+		p.notify("event", map[string]interface{}{
+			"event":      ev.Hook,
+			"file_name":  ev.FileName,
+			"line":       ev.Line,
+			"col":        ev.Col,
+			"line_count": ev.LineCount,
+		})
+	}
+}
+
+func (p *plugin) handle(req rpcRequest) (interface{}, *rpcError) {
+	var params struct {
+		Name        string   `json:"name"`
+		Key         string   `json:"key"`
+		Events      []string `json:"events"`
+		Start       int      `json:"start"`
+		End         int      `json:"end"`
+		Line        int      `json:"line"`
+		Col         int      `json:"col"`
+		Text        string   `json:"text"`
+		Message     string   `json:"message"`
+		Completions []string `json:"completions"`
+		Title       string   `json:"title"`
+		Items       []string `json:"items"`
+	}
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+
+	switch req.Method {
+	case "register_command":
+		if params.Name == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "missing command name"}
+		}
+		if _, ok := p.e.actionDispatch[params.Name]; ok && p.e.pluginCommands[params.Name] != p {
+			return nil, &rpcError{Code: rpcEditorError, Message: fmt.Sprintf("command %q already exists", params.Name)}
+		}
+		name := params.Name
+		p.e.actionDispatch[name] = func() { p.notify("command", map[string]string{"name": name}) }
+		p.e.pluginCommands[name] = p
+		if params.Key != "" {
+			if err := p.e.bindKey(params.Key, name); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+		}
+		return true, nil
+
+	case "subscribe":
+		for _, event := range params.Events {
+			if !hookNames[Hook(event)] {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("unknown event %q", event)}
+			}
+			p.events[Hook(event)] = true
+		}
+		return true, nil
+
+	case "buffer_info":
+		return map[string]interface{}{
+			"file_name":  p.e.buf.fileName,
+			"dirty":      p.e.buf.dirty,
+			"readonly":   p.e.isReadonly(),
+			"line_count": len(p.e.buf.lines),
+		}, nil
+
+	case "get_lines":
+		start, end := params.Start, params.End
+		if end <= 0 || end > len(p.e.buf.lines) {
+			end = len(p.e.buf.lines)
+		}
+		if start < 0 || start > end {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "invalid line range"}
+		}
+		lines := []string{}
+		for _, l := range p.e.buf.lines[start:end] {
+			lines = append(lines, string(l.chars))
+		}
+		return lines, nil
+
+	case "set_line", "insert_line", "delete_line":
+		if p.e.isReadonly() {
+			return nil, &rpcError{Code: rpcEditorError, Message: "buffer is read-only"}
+		}
+		switch req.Method {
+		case "set_line":
+			if params.Line < 0 || params.Line >= len(p.e.buf.lines) {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: "line out of range"}
+			}
+			if p.e.buf.lineProtected(params.Line) {
+				return nil, &rpcError{Code: rpcEditorError, Message: "line is protected"}
+			}
+			p.e.buf.lines[params.Line].chars = []rune(params.Text)
+			p.e.buf.lines[params.Line].render = p.e.updateRow(p.e.buf.lines[params.Line].chars)
+			p.e.buf.dirty = true
+			p.e.changes++
+		case "insert_line":
+			if params.Line < 0 || params.Line > len(p.e.buf.lines) {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: "line out of range"}
+			}
+			if !p.e.canInsertRow(params.Line) {
+				return nil, &rpcError{Code: rpcEditorError, Message: "line is protected"}
+			}
+			p.e.insertRow(params.Line, params.Text)
+		case "delete_line":
+			if params.Line < 0 || params.Line >= len(p.e.buf.lines) {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: "line out of range"}
+			}
+			if p.e.buf.lineProtected(params.Line) {
+				return nil, &rpcError{Code: rpcEditorError, Message: "line is protected"}
+			}
+			p.e.deleteRow(params.Line)
+		}
+		return true, nil
+
+	case "get_cursor":
+		return map[string]int{"line": p.e.buf.cursor.y, "col": p.e.buf.cursor.x}, nil
+
+	case "set_cursor":
+		p.e.setCursor(point{x: params.Col, y: params.Line})
+		p.e.snapCursor()
+		return true, nil
+
+	case "status":
+		p.e.setStatusMsg("%s", params.Message)
+		return true, nil
+
+	case "prompt":
+		var complete func(string) []string
+		if len(params.Completions) > 0 {
+			complete = completeFrom(params.Completions)
+		}
+		text, ok := p.e.Prompt(params.Message, nil, complete)
+		return map[string]interface{}{"text": text, "ok": ok}, nil
+
+	case "pick":
+		index, ok := p.e.Pick(params.Title, params.Items)
+		return map[string]interface{}{"index": index, "ok": ok}, nil
+	}
+
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)}
+}
@@ -0,0 +1,349 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+/*-----------------------------------------------------------------------------
+ * Markdown preview
+ *
+ * The "preview" action shows a Markdown buffer formatted instead of its text:
+ * headings, emphasis, code, links, lists, quotes and rules, with the code in
+ * fences highlighted. The preview is rendered from the buffer every time the
+ * screen is drawn, so it follows changes made by plugins, scripts and
+ * collaborators. The arrow and page keys scroll it and escape, q or the
+ * action again goes back to the text.
+ */
+
+type mdPreview struct {
+	top int // first rendered line shown
+}
+
+/* mdSpan is a piece of a rendered line drawn with the SGR parameters style. */
+type mdSpan struct {
+	text  string
+	style string
+}
+
+func isMarkdown(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+/* togglePreview switches the current buffer between its text and its preview. */
+func (e *Editor) togglePreview() {
+	if e.buf.preview != nil {
+		e.buf.preview = nil
+		return
+	}
+	if !isMarkdown(e.buf.fileName) {
+		e.setStatusMsg("Preview is only available for Markdown files")
+		e.bell()
+		return
+	}
+	e.buf.preview = &mdPreview{}
+}
+
+/* previewKey handles k while the preview is shown and reports whether it did. Bound keys, save and quit are left to the editor. */
+func (e *Editor) previewKey(k int) bool {
+	p := e.buf.preview
+	switch k {
+	case kArrowUp:
+		p.top--
+	case kArrowDown:
+		p.top++
+	case kPageUp:
+		p.top -= e.termRows
+	case kPageDown:
+		p.top += e.termRows
+	case kHome:
+		p.top = 0
+	case kEnd:
+		p.top = 1 << 30 // clamped when drawn
+	case '\x1b', 'q':
+		e.buf.preview = nil
+	case ctrlKey('q'), ctrlKey('s'):
+		return false
+	default:
+		_, bound := e.keyBindings[k]
+		return !bound
+	}
+	return true
+}
+
+func (e *Editor) drawPreview(scrBuf *bytes.Buffer) {
+	lines := renderMarkdown(e.buf.lines, e.termCols, e.theme)
+
+	p := e.buf.preview
+	if p.top > len(lines)-e.termRows {
+		p.top = len(lines) - e.termRows
+	}
+	if p.top < 0 {
+		p.top = 0
+	}
+
+	for y := 0; y < e.termRows; y++ {
+		fmt.Fprint(scrBuf, sgr(e.theme.Text))
+		if i := p.top + y; i < len(lines) {
+			room := e.termCols
+			for _, s := range lines[i] {
+				text := []rune(s.text)
+				if len(text) > room {
+					text = text[:room]
+				}
+				room -= len(text)
+				if s.style == "" {
+					fmt.Fprint(scrBuf, string(text))
+				} else {
+					fmt.Fprintf(scrBuf, "%s%s\x1b[m%s", sgr(s.style), string(text), sgr(e.theme.Text))
+				}
+			}
+		} else {
+			fmt.Fprint(scrBuf, sgr(e.theme.EmptyLine), "~")
+		}
+		fmt.Fprint(scrBuf, "\x1b[K") // clear to end of line
+		fmt.Fprint(scrBuf, "\x1b[m") // normal colour
+		fmt.Fprint(scrBuf, "\r\n")
+	}
+}
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdBullet   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdOrdered  = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdRule     = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	mdFence    = regexp.MustCompile("^\\s*(```|~~~)\\s*([\\w+-]*)")
+	mdLink     = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+	mdTaskItem = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+)
+
+/* renderMarkdown formats the lines of a Markdown document for a screen cols wide. */
+func renderMarkdown(src []line, cols int, theme Theme) [][]mdSpan {
+	out := [][]mdSpan{}
+	fence, lang := "", ""
+
+	for _, l := range src {
+		text := string(l.render) // with the tabs expanded
+
+		if m := mdFence.FindStringSubmatch(text); m != nil && (fence == "" || m[1] == fence) {
+			if fence == "" {
+				fence, lang = m[1], strings.ToLower(m[2])
+			} else {
+				fence, lang = "", ""
+			}
+			continue
+		}
+		if fence != "" {
+			out = append(out, append([]mdSpan{{text: "  "}}, highlightCode(text, lang)...))
+			continue
+		}
+
+		switch {
+		case mdHeading.MatchString(text):
+			m := mdHeading.FindStringSubmatch(text)
+			style := "1"
+			if len(m[1]) == 1 {
+				style = "1;4"
+			}
+			out = append(out, renderInline(m[2], style, theme))
+
+		case mdRule.MatchString(text):
+			out = append(out, []mdSpan{{text: strings.Repeat("─", cols), style: "2"}})
+
+		case mdBullet.MatchString(text):
+			m := mdBullet.FindStringSubmatch(text)
+			mark, item := "• ", m[2]
+			if t := mdTaskItem.FindStringSubmatch(item); t != nil {
+				mark, item = "☐ ", t[2]
+				if t[1] != " " {
+					mark = "☑ "
+				}
+			}
+			out = append(out, append([]mdSpan{{text: m[1] + mark}}, renderInline(item, "", theme)...))
+
+		case mdOrdered.MatchString(text):
+			m := mdOrdered.FindStringSubmatch(text)
+			out = append(out, append([]mdSpan{{text: m[1] + m[2] + " "}}, renderInline(m[3], "", theme)...))
+
+		case strings.HasPrefix(strings.TrimSpace(text), ">"):
+			quoted := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(text), ">"), " ")
+			out = append(out, append([]mdSpan{{text: "│ ", style: "2"}}, renderInline(quoted, "3", theme)...))
+
+		default:
+			out = append(out, renderInline(text, "", theme))
+		}
+	}
+	return out
+}
+
+/* renderInline formats the emphasis, code and links of text, on top of the SGR parameters base. */
+func renderInline(text, base string, theme Theme) []mdSpan {
+	spans := []mdSpan{}
+	bold, italic, code := false, false, false
+	cur := []rune{}
+
+	style := func() string {
+		params := []string{}
+		if base != "" {
+			params = append(params, base)
+		}
+		if bold {
+			params = append(params, "1")
+		}
+		if italic {
+			params = append(params, "3")
+		}
+		if code {
+			params = append(params, "7")
+		}
+		return strings.Join(params, ";")
+	}
+	flush := func() {
+		if len(cur) > 0 {
+			spans = append(spans, mdSpan{text: string(cur), style: style()})
+			cur = cur[:0]
+		}
+	}
+
+	rs := []rune(text)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\\' && i+1 < len(rs) && !code:
+			i++
+			cur = append(cur, rs[i])
+
+		case r == '`':
+			flush()
+			code = !code
+
+		case code:
+			cur = append(cur, r)
+
+		case r == '[':
+			if m := mdLink.FindStringSubmatchIndex(string(rs[i:])); m != nil && m[0] == 0 {
+				s := string(rs[i:])
+				flush()
+				spans = append(spans, mdSpan{text: s[m[2]:m[3]], style: strings.Trim(style()+";"+theme.Link, ";")})
+				i += len([]rune(s[:m[1]])) - 1
+				continue
+			}
+			cur = append(cur, r)
+
+		case (r == '*' || r == '_') && i+1 < len(rs) && rs[i+1] == r:
+			flush()
+			bold = !bold
+			i++
+
+		case r == '*' || (r == '_' && !inWord(rs, i)):
+			flush()
+			italic = !italic
+
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	return spans
+}
+
+/* inWord reports whether the character at i has a letter or digit on both sides, as in snake_case. */
+func inWord(rs []rune, i int) bool {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	return i > 0 && i+1 < len(rs) && isWord(rs[i-1]) && isWord(rs[i+1])
+}
+
+var codeKeywords = wordSet(`
+	break case catch class const continue def default defer do elif else
+	except export extends false finally fn for func function go if import
+	in interface let local nil none null package pub range return select
+	self static struct switch then this throw true try type use var while
+	yield`)
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+/* commentStart returns the marker starting a line comment in the language lang, "" if any common one will do. */
+func commentStart(lang string) string {
+	switch lang {
+	case "go", "c", "h", "cpp", "c++", "hpp", "java", "js", "javascript", "ts", "typescript", "rust", "rs", "swift", "kotlin", "kt":
+		return "//"
+	case "python", "py", "sh", "bash", "shell", "zsh", "ruby", "rb", "yaml", "yml", "toml", "perl", "pl":
+		return "#"
+	case "lua", "sql", "haskell":
+		return "--"
+	}
+	return ""
+}
+
+/* highlightCode colours the keywords, strings, numbers and comments of a line of code. */
+func highlightCode(text, lang string) []mdSpan {
+	spans := []mdSpan{}
+	comment := commentStart(lang)
+	rs := []rune(text)
+
+	for i := 0; i < len(rs); {
+		rest := string(rs[i:])
+		switch {
+		case comment != "" && strings.HasPrefix(rest, comment),
+			comment == "" && (strings.HasPrefix(rest, "//") || strings.HasPrefix(rest, "#")):
+			spans = append(spans, mdSpan{text: rest, style: "2"})
+			i = len(rs)
+
+		case rs[i] == '"' || rs[i] == '\'' || rs[i] == '`':
+			j := i + 1
+			for j < len(rs) && rs[j] != rs[i] {
+				if rs[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(rs) {
+				j++
+			} else {
+				j = len(rs)
+			}
+			spans = append(spans, mdSpan{text: string(rs[i:j]), style: "32"})
+			i = j
+
+		case unicode.IsDigit(rs[i]):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || unicode.IsLetter(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			spans = append(spans, mdSpan{text: string(rs[i:j]), style: "36"})
+			i = j
+
+		case unicode.IsLetter(rs[i]) || rs[i] == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			word := string(rs[i:j])
+			style := ""
+			if codeKeywords[word] {
+				style = "1;34"
+			}
+			spans = append(spans, mdSpan{text: word, style: style})
+			i = j
+
+		default:
+			spans = append(spans, mdSpan{text: string(rs[i])})
+			i++
+		}
+	}
+	return spans
+}
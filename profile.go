@@ -0,0 +1,96 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Render profiling
+ *
+ * The "debug_render" action toggles a box in the top right corner with the
+ * timings of the last frame: the wait for a key, handling it, scrolling,
+ * drawing into the screen buffer and writing it to the terminal, with the
+ * allocations made while drawing and the bytes written. The pprof setting
+ * starts the net/http/pprof endpoints on the given address.
+ */
+
+type renderStats struct {
+	wait   time.Duration // waiting for the key
+	handle time.Duration // handling the key, including the hooks it ran
+	scroll time.Duration
+	draw   time.Duration
+	write  time.Duration
+	allocs uint64 // allocations while drawing and writing
+	bytes  int    // written to the terminal
+	frames int
+}
+
+func mallocs() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.Mallocs
+}
+
+func (e *Editor) toggleRenderStats() {
+	if e.renderStats != nil {
+		e.renderStats = nil
+		return
+	}
+	e.renderStats = &renderStats{}
+}
+
+/* drawRenderStats draws the timings of the previous frame in the top right corner. */
+func (e *Editor) drawRenderStats(scrBuf *bytes.Buffer) {
+	st := e.renderStats
+	lines := []string{
+		fmt.Sprintf("frame  %8d", st.frames),
+		fmt.Sprintf("wait   %8s", st.wait.Round(time.Microsecond)),
+		fmt.Sprintf("handle %8s", st.handle.Round(time.Microsecond)),
+		fmt.Sprintf("scroll %8s", st.scroll.Round(time.Microsecond)),
+		fmt.Sprintf("draw   %8s", st.draw.Round(time.Microsecond)),
+		fmt.Sprintf("write  %8s", st.write.Round(time.Microsecond)),
+		fmt.Sprintf("allocs %8d", st.allocs),
+		fmt.Sprintf("bytes  %8d", st.bytes),
+	}
+	col := e.termCols - 17
+	if col < 1 || len(lines) > e.termRows {
+		return
+	}
+	for i, l := range lines {
+		fmt.Fprintf(scrBuf, "\x1b[%d;%dH\x1b[7m %s \x1b[m", i+1, col, l)
+	}
+}
+
+func (e *Editor) stopPprof() {
+	if e.pprofListener != nil {
+		e.pprofListener.Close()
+		e.pprofListener = nil
+	}
+}
+
+/* startPprof serves the pprof endpoints on addr, on a mux of its own, instead of any earlier address. */
+func (e *Editor) startPprof(addr string) error {
+	e.stopPprof()
+	if addr == "" || addr == "off" {
+		return nil
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	e.pprofListener = l
+	go http.Serve(l, mux)
+	return nil
+}
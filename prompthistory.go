@@ -0,0 +1,136 @@
+package editor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Prompt history
+ *
+ * The search, replace, grep and file name prompts remember what was entered in
+ * them, and up and down step through the earlier input of the same kind of
+ * prompt. The history is kept in prompt_history.json in the data directory, so
+ * it survives restarts. The "open_file" action prompts for a file to open, and
+ * tab completes the path. Images are previewed rather than opened.
+ */
+
+const promptHistoryKeep = 100 // entries kept for every kind of prompt
+
+const (
+	searchHistory  = "search"
+	replaceHistory = "replace"
+	fileHistory    = "file"
+)
+
+func promptHistoryFile() string {
+	dir := dataDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "prompt_history.json")
+}
+
+/* keepsPromptHistory reports whether the prompt history is read from and written to disk. */
+func (e *Editor) keepsPromptHistory() bool {
+	return !e.isolated && !e.secure && promptHistoryFile() != ""
+}
+
+/* history returns the earlier input of the prompts of kind, oldest first. */
+func (e *Editor) history(kind string) []string {
+	if e.promptHistory == nil {
+		e.promptHistory = map[string][]string{}
+		if e.keepsPromptHistory() {
+			if data, err := os.ReadFile(promptHistoryFile()); err == nil {
+				json.Unmarshal(data, &e.promptHistory)
+			}
+		}
+	}
+	return e.promptHistory[kind]
+}
+
+/* addHistory makes s the latest input of the prompts of kind and saves the history. */
+func (e *Editor) addHistory(kind, s string) {
+	if s == "" {
+		return
+	}
+	h := []string{}
+	for _, old := range e.history(kind) {
+		if old != s {
+			h = append(h, old)
+		}
+	}
+	h = append(h, s)
+	if len(h) > promptHistoryKeep {
+		h = h[len(h)-promptHistoryKeep:]
+	}
+	e.promptHistory[kind] = h
+
+	if !e.keepsPromptHistory() {
+		return
+	}
+	data, err := json.MarshalIndent(e.promptHistory, "", "  ")
+	if err != nil {
+		return
+	}
+	path := promptHistoryFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		e.setStatusMsg("prompt history: %s", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		e.setStatusMsg("prompt history: %s", err)
+	}
+}
+
+/* historyPrompt is Prompt with the history of the prompts of kind. */
+func (e *Editor) historyPrompt(kind, msg string, validate func(string) error, complete func(string) []string) (string, bool) {
+	input, err := e.readPrompt(strings.ReplaceAll(msg, "%", "%%")+"%s", e.history(kind), validate, complete)
+	if err != nil {
+		return "", false
+	}
+	e.addHistory(kind, input)
+	return input, true
+}
+
+/* completePath returns the files and directories whose path starts with input, directories ending in a slash. */
+func completePath(input string) []string {
+	dir, base := filepath.Split(input)
+	list := dir
+	if list == "" {
+		list = "."
+	}
+	entries, err := os.ReadDir(list)
+	if err != nil {
+		return nil
+	}
+	matches := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		matches = append(matches, dir+name)
+	}
+	return matches
+}
+
+func (e *Editor) openFileAction() {
+	name, ok := e.historyPrompt(fileHistory, "Open: ", nil, completePath)
+	if !ok || name == "" {
+		return
+	}
+	open := e.openBuffer
+	if isImage(name) && fileExists(name) {
+		open = e.previewImage
+	}
+	if err := open(name); err != nil {
+		e.setStatusMsg("%s: %s", name, err)
+		e.bell()
+	}
+}
@@ -0,0 +1,105 @@
+package editor
+
+import "errors"
+
+/*-----------------------------------------------------------------------------
+ * Protected regions
+ *
+ * Lines can be protected from editing within an otherwise editable buffer,
+ * for example a generated header. The regions move with the text when lines
+ * are inserted or deleted above them.
+ */
+
+/* lineRange is the lines from start to end, both included. */
+type lineRange struct {
+	start int
+	end   int
+}
+
+// ErrProtected is returned for edits touching a protected line.
+var ErrProtected = errors.New("line is protected")
+
+func (b *buffer) lineProtected(y int) bool {
+	for _, r := range b.protected {
+		if y >= r.start && y <= r.end {
+			return true
+		}
+	}
+	return false
+}
+
+/* canEdit reports whether the lines from and to, and those between them, may be changed. */
+func (e *Editor) canEdit(from, to int) bool {
+	for y := from; y <= to; y++ {
+		if e.buf.lineProtected(y) {
+			e.setStatusMsg("line %d is protected", y+1)
+			e.bell()
+			return false
+		}
+	}
+	return true
+}
+
+/* canInsertRow reports whether a line may be inserted at row, which is not allowed between two protected lines. */
+func (e *Editor) canInsertRow(row int) bool {
+	for _, r := range e.buf.protected {
+		if row > r.start && row <= r.end {
+			e.setStatusMsg("line %d is protected", row+1)
+			e.bell()
+			return false
+		}
+	}
+	return true
+}
+
+/* shiftProtected moves the regions after row by n lines when lines are inserted or deleted at row. */
+func (b *buffer) shiftProtected(row, n int) {
+	kept := b.protected[:0]
+	for _, r := range b.protected {
+		if r.start >= row {
+			r.start += n
+			if r.start < row {
+				r.start = row
+			}
+		}
+		if r.end >= row {
+			r.end += n
+		}
+		if r.end >= r.start {
+			kept = append(kept, r)
+		}
+	}
+	b.protected = kept
+}
+
+// Protect makes the lines from and to, both included, read-only.
+func (b *Buffer) Protect(from, to int) error {
+	if from < 0 || to < from || to >= len(b.b.lines) {
+		return ErrOutOfRange
+	}
+	b.b.protected = append(b.b.protected, lineRange{start: from, end: to})
+	return nil
+}
+
+// Unprotect makes the lines from and to, both included, editable again.
+func (b *Buffer) Unprotect(from, to int) {
+	kept := []lineRange{}
+	for _, r := range b.b.protected {
+		if r.end < from || r.start > to {
+			kept = append(kept, r)
+			continue
+		}
+		if r.start < from {
+			kept = append(kept, lineRange{start: r.start, end: from - 1})
+		}
+		if r.end > to {
+			kept = append(kept, lineRange{start: to + 1, end: r.end})
+		}
+	}
+	b.b.protected = kept
+}
+
+// Protected reports whether line i is protected.
+func (b *Buffer) Protected(i int) bool {
+	return b.b.lineProtected(i)
+}
@@ -0,0 +1,191 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Remote control
+ *
+ * When the EDITOR_SOCKET environment variable names a path the editor listens
+ * on a unix socket there. Clients send one command per line and get one line
+ * back, either "ok" or "error: <message>".
+ *
+ *	open /path/file:120   open a file in a new buffer, optionally at a line
+ *	save-all              save all buffers
+ *	eval <action>         run a named action
+ */
+
+func controlSocketPath() string {
+	return os.Getenv("EDITOR_SOCKET")
+}
+
+func (e *Editor) startControlSocket() {
+	path := controlSocketPath()
+	if path == "" {
+		return
+	}
+
+	/* leave the socket alone if another editor is serving it */
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		e.setStatusMsg("control socket %s is used by another editor", path)
+		return
+	}
+
+	os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		e.setStatusMsg("control socket: %s", err)
+		return
+	}
+	e.controlListener = l
+	stop := make(chan struct{})
+	e.controlStop = stop
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go e.serveControl(conn, stop)
+		}
+	}()
+}
+
+func (e *Editor) stopControlSocket() {
+	if e.controlListener != nil {
+		e.controlListener.Close()
+		os.Remove(e.controlListener.Addr().String())
+		e.controlListener = nil
+		close(e.controlStop)
+	}
+}
+
+/* serveControl runs the commands sent on conn until the client hangs up or stop is closed. */
+func (e *Editor) serveControl(conn net.Conn, stop <-chan struct{}) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		command := strings.TrimSpace(scanner.Text())
+		if command == "" {
+			continue
+		}
+
+		/* the editor may exit before it runs the command, so don't wait on it forever */
+		done := make(chan error, 1)
+		select {
+		case e.tasks <- func() { done <- e.controlCommand(command) }:
+		case <-stop:
+			return
+		case <-e.ctx.Done():
+			return
+		}
+
+		var err error
+		select {
+		case err = <-done:
+		case <-stop:
+			return
+		case <-e.ctx.Done():
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(conn, "error: %s\n", err)
+		} else {
+			fmt.Fprint(conn, "ok\n")
+		}
+	}
+}
+
+func (e *Editor) controlCommand(command string) error {
+	name, arg, _ := strings.Cut(command, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "open":
+		if arg == "" {
+			return errors.New("missing file name")
+		}
+		return e.controlOpen(arg)
+
+	case "save-all":
+		if e.readonly {
+			return errors.New("buffer is read-only")
+		}
+		if !e.saveAll() {
+			return errors.New(e.statusMsg)
+		}
+		return nil
+
+	case "eval":
+		if !e.runAction(arg) {
+			return fmt.Errorf("unknown action %q", arg)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q", name)
+}
+
+/* controlOpen opens name, which may end in :line, in a buffer of its own. */
+func (e *Editor) controlOpen(name string) error {
+	row := -1
+	if i := strings.LastIndex(name, ":"); i > 0 {
+		if n, err := strconv.Atoi(name[i+1:]); err == nil {
+			name, row = name[:i], n-1
+		}
+	}
+
+	if err := e.openBuffer(name); err != nil {
+		return err
+	}
+
+	if row >= 0 {
+		e.setCursor(point{y: row})
+		e.snapCursor()
+	}
+	e.setStatusMsg("opened %s", name)
+	return nil
+}
+
+// ErrNoServer is returned by OpenRemote when no editor is listening on the control socket.
+var ErrNoServer = errors.New("no running editor")
+
+// OpenRemote asks the editor listening on the EDITOR_SOCKET control socket to
+// open the file name, which may end in :line, in a new buffer.
+func OpenRemote(name string) error {
+	path := controlSocketPath()
+	if path == "" {
+		return ErrNoServer
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return ErrNoServer
+	}
+	defer conn.Close()
+
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(conn, "open %s\n", abs)
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if msg, ok := strings.CutPrefix(strings.TrimSpace(reply), "error: "); ok {
+		return errors.New(msg)
+	}
+	return nil
+}
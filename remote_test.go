@@ -0,0 +1,31 @@
+package editor
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestControlConnectionEndsWithEditor(t *testing.T) {
+	e := New(WithTerminal(NewHeadless(8, 40)))
+	stop := make(chan struct{})
+	client, server := net.Pipe()
+	defer client.Close()
+
+	served := make(chan struct{})
+	go func() {
+		e.serveControl(server, stop)
+		close(served)
+	}()
+
+	/* nothing runs the queued command, as when the editor has exited */
+	fmt.Fprint(client, "save-all\n")
+	close(stop)
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("control connection still waits on a stopped editor")
+	}
+}
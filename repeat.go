@@ -0,0 +1,51 @@
+package editor
+
+import "unicode"
+
+/*-----------------------------------------------------------------------------
+ * Repeat
+ *
+ * The keys of the last key press that changed the text are kept, together with
+ * whatever it read, such as the answers to the prompts of replace, and a run of
+ * typed text, new lines, tabs and backspaces is kept as one edit. The "repeat"
+ * action, ctrl+y by default, plays the last edit again at the cursor, like the
+ * . of vi.
+ */
+
+/* insertKey reports whether k is part of a run of typing. */
+func insertKey(k int) bool {
+	return k == '\r' || k == '\t' || k == kBackSpace || (k < kArrowUp && unicode.IsPrint(rune(k)))
+}
+
+/* noteEdit keeps the keys read for the key just handled as the last edit, if the text changed since changes. */
+func (e *Editor) noteEdit(changes int) {
+	if e.replaying {
+		return
+	}
+	keys := e.keysRead
+	if e.changes == changes || len(keys) == 0 {
+		e.editRun = false
+		return
+	}
+	run := len(keys) == 1 && insertKey(keys[0]) && e.keyBindings[keys[0]] == ""
+	if run && e.editRun {
+		e.lastEdit = append(e.lastEdit, keys...)
+	} else {
+		e.lastEdit = append([]int{}, keys...)
+	}
+	e.editRun = run
+}
+
+func (e *Editor) repeat() {
+	if len(e.lastEdit) == 0 {
+		e.setStatusMsg("Nothing to repeat")
+		e.bell()
+		return
+	}
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	e.stopAfterAction(e.playKeys(e.lastEdit))
+}
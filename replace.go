@@ -0,0 +1,194 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Replace
+ *
+ * The "replace" action replaces every occurrence of a string in the current
+ * buffer, or only in the selection if asked to. The "replace_all_buffers"
+ * action replaces every occurrence of a string in all open buffers that can
+ * be edited, leaving protected lines alone, and shows how many were replaced
+ * in each buffer. "undo_replace" puts back the text of the buffers the last
+ * replacement, or line filter, changed, unless they have been edited since.
+ */
+
+type replaceUndo struct {
+	buf       *buffer
+	lines     []line // the lines before the replacement
+	dirty     bool   // whether the buffer was modified before the replacement
+	bookmarks []int  // the bookmarks before the replacement
+	after     string // the text after the replacement
+}
+
+/* replaceSnapshot returns what undo_replace needs to put b back as it is before an edit. */
+func replaceSnapshot(b *buffer) replaceUndo {
+	u := replaceUndo{buf: b, lines: make([]line, len(b.lines)), dirty: b.dirty}
+	copy(u.lines, b.lines)
+	u.bookmarks = append([]int{}, b.bookmarks...)
+	return u
+}
+
+/* keepReplaceUndo lets undo_replace put back u, taken before an edit of its buffer, unless in secure mode, which keeps no copies of the text. */
+func (e *Editor) keepReplaceUndo(u replaceUndo) {
+	if e.secure {
+		e.replaceUndo = nil
+		return
+	}
+	u.after = u.buf.text()
+	e.replaceUndo = []replaceUndo{u}
+}
+
+/* replaceInBuffer replaces query with with in the lines of b that may be edited and returns the number of replacements. */
+func (e *Editor) replaceInBuffer(b *buffer, query, with string) int {
+	return e.replaceInRange(b, query, with, point{}, point{y: len(b.lines)})
+}
+
+/* replaceInRange is replaceInBuffer for the occurrences that lie between from and to. */
+func (e *Editor) replaceInRange(b *buffer, query, with string, from, to point) int {
+	n := 0
+	for y := from.y; y <= to.y && y < len(b.lines); y++ {
+		chars := b.lines[y].chars
+		lo, hi := 0, len(chars)
+		if y == from.y {
+			lo = from.x
+		}
+		if y == to.y && to.x < hi {
+			hi = to.x
+		}
+		s := string(chars[lo:hi])
+		if !strings.Contains(s, query) || b.lineProtected(y) {
+			continue
+		}
+		n += strings.Count(s, query)
+		s = string(chars[:lo]) + strings.ReplaceAll(s, query, with) + string(chars[hi:])
+		b.lines[y].chars = []rune(s)
+		b.lines[y].render = e.updateRow(b.lines[y].chars)
+	}
+	return n
+}
+
+func (e *Editor) replace() {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	from, to, within := e.useSelection("Replace")
+	if !within {
+		from, to = point{}, point{y: len(e.buf.lines)}
+	}
+	query, ok := e.historyPrompt(searchHistory, "Replace: ", nil, nil)
+	if !ok || query == "" {
+		return
+	}
+	with, ok := e.historyPrompt(replaceHistory, fmt.Sprintf("Replace %q with: ", query), nil, nil)
+	if !ok {
+		return
+	}
+
+	b := e.buf
+	u := replaceSnapshot(b)
+	n := e.replaceInRange(b, query, with, from, to)
+	if n == 0 {
+		e.setStatusMsg("%q not found", query)
+		e.bell()
+		return
+	}
+	b.dirty = true
+	e.keepReplaceUndo(u)
+	e.changes++
+	e.snapCursor()
+	e.setStatusMsg("Replaced %d, undo_replace undoes it", n)
+}
+
+func (e *Editor) replaceAllBuffers() {
+	if e.readonly {
+		e.setStatusMsg("buffers are read-only")
+		e.bell()
+		return
+	}
+	query, ok := e.historyPrompt(searchHistory, "Replace in all buffers: ", nil, nil)
+	if !ok || query == "" {
+		return
+	}
+	with, ok := e.historyPrompt(replaceHistory, fmt.Sprintf("Replace %q with: ", query), nil, nil)
+	if !ok {
+		return
+	}
+
+	undo := []replaceUndo{}
+	summary := []string{}
+	total := 0
+	for _, b := range e.buffers {
+		if b.readonly || b.preview != nil {
+			continue
+		}
+		u := replaceSnapshot(b)
+		n := e.replaceInBuffer(b, query, with)
+		if n == 0 {
+			continue
+		}
+		if !e.secure {
+			u.after = b.text()
+			undo = append(undo, u)
+		}
+		b.dirty = true
+		total += n
+		summary = append(summary, fmt.Sprintf("%5d  %s", n, bufferTitle(b)))
+	}
+	if total == 0 {
+		e.setStatusMsg("%q not found in any buffer", query)
+		e.bell()
+		return
+	}
+
+	e.replaceUndo = undo
+	e.changes++
+	cur := e.buf
+	for _, b := range e.buffers {
+		e.buf = b
+		e.snapCursor()
+	}
+	e.buf = cur
+	e.ShowPopup(fmt.Sprintf("Replaced %d in %d buffers, undo_replace undoes it", total, len(summary)), summary)
+}
+
+func (e *Editor) undoReplace() {
+	if e.secure {
+		e.setStatusMsg("undo_replace is off in secure mode")
+		e.bell()
+		return
+	}
+	if e.replaceUndo == nil {
+		e.setStatusMsg("Nothing to undo")
+		e.bell()
+		return
+	}
+	restored, skipped := 0, 0
+	cur := e.buf
+	for _, u := range e.replaceUndo {
+		if e.bufferIndex(u.buf) < 0 || u.buf.text() != u.after {
+			skipped++
+			continue
+		}
+		u.buf.lines = u.lines
+		u.buf.dirty = u.dirty
+		u.buf.bookmarks = u.bookmarks
+		e.buf = u.buf
+		e.snapCursor()
+		restored++
+	}
+	e.buf = cur
+	e.replaceUndo = nil
+	e.changes++
+
+	if skipped > 0 {
+		e.setStatusMsg("Undid the replacement in %d buffers, %d edited since were left alone", restored, skipped)
+		return
+	}
+	e.setStatusMsg("Undid the replacement in %d buffers", restored)
+}
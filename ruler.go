@@ -0,0 +1,82 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Color columns
+ *
+ * The color_column setting, such as "80" or "80,100", draws the given columns
+ * in the Ruler colour of the theme down every row, and the characters past the
+ * last of them in the LongLine colour, for projects that limit the length of
+ * their lines. "off" removes them.
+ */
+
+/* parseColorColumns parses the value of the color_column setting into ascending one based columns. */
+func parseColorColumns(value string) ([]int, error) {
+	if value == "" || strings.EqualFold(value, "off") {
+		return nil, nil
+	}
+	cols := []int{}
+	for _, f := range strings.Split(value, ",") {
+		c, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || c < 1 {
+			return nil, fmt.Errorf("color_column must be columns such as 80 or 80,100, or off")
+		}
+		cols = append(cols, c)
+	}
+	sort.Ints(cols)
+	return cols, nil
+}
+
+/* colorColumnMarks adds the characters on the color columns and past the last one to marks, leaving columns that are already marked alone. */
+func (e *Editor) colorColumnMarks(marks map[int]map[int]string) map[int]map[int]string {
+	if len(e.colorColumns) == 0 {
+		return marks
+	}
+	ruler, long := sgr(e.theme.Ruler), sgr(e.theme.LongLine)
+	limit := e.colorColumns[len(e.colorColumns)-1]
+
+	for y := e.buf.fileY; y < e.buf.fileY+e.termRows && y < len(e.buf.lines); y++ {
+		render := e.buf.lines[y].render
+		if len(render) < e.colorColumns[0] {
+			continue
+		}
+		if marks == nil {
+			marks = map[int]map[int]string{}
+		}
+		if marks[y] == nil {
+			marks[y] = map[int]string{}
+		}
+		mark := func(rx int, style string) {
+			if _, ok := marks[y][rx]; !ok {
+				marks[y][rx] = style
+			}
+		}
+		for _, c := range e.colorColumns {
+			if c <= len(render) {
+				mark(c-1, ruler)
+			}
+		}
+		for rx := limit; rx < len(render); rx++ {
+			mark(rx, long)
+		}
+	}
+	return marks
+}
+
+/* drawColorColumns draws the color columns of a row that lie past the used columns. */
+func (e *Editor) drawColorColumns(scrBuf *bytes.Buffer, used int) {
+	for _, c := range e.colorColumns {
+		col := c - e.buf.fileX // on the screen, one based
+		if col <= used || col > e.textCols() {
+			continue
+		}
+		fmt.Fprintf(scrBuf, "\x1b[%dG%s \x1b[m", col+e.gutterWidth(), sgr(e.theme.Ruler))
+	}
+}
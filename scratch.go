@@ -0,0 +1,34 @@
+package editor
+
+/*-----------------------------------------------------------------------------
+ * Scratch buffers
+ *
+ * Scratch buffers are not backed by any file. They are left out when buffers
+ * are autosaved or saved all at once, and ask for a file name only when the
+ * user saves them, after which they are ordinary buffers.
+ */
+
+/* newScratchBuffer adds an empty scratch buffer and makes it the current buffer. */
+func (e *Editor) newScratchBuffer() *buffer {
+	b := e.addBuffer()
+	b.lines = []line{}
+	b.scratch = true
+	return b
+}
+
+func (e *Editor) newBuffer() {
+	e.newScratchBuffer()
+	e.setStatusMsg("New scratch buffer, saving it asks for a file name")
+}
+
+// NewBuffer opens a scratch buffer holding text, such as the output of a
+// command, and makes it the current buffer. The buffer is unmodified until
+// it is edited. NewBuffer must be called on the editor's main loop.
+func (e *Editor) NewBuffer(text string) *Buffer {
+	b := e.newScratchBuffer()
+	if text != "" {
+		e.replaceRange(point{}, point{}, text)
+		b.dirty = false
+	}
+	return &Buffer{e: e, b: b}
+}
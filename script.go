@@ -0,0 +1,232 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+/*-----------------------------------------------------------------------------
+ * Scripting
+ *
+ * The init script init.lua in the configuration directory is run at startup.
+ * It can use the "editor" table to define commands and key bindings, for
+ * example:
+ *
+ *	editor.command("delete_blank_lines", function()
+ *		for i = editor.line_count(), 1, -1 do
+ *			if editor.get_line(i):match("^%s*$") then
+ *				editor.delete_line(i)
+ *			end
+ *		end
+ *	end)
+ *	editor.bind("ctrl+b", "delete_blank_lines")
+ *	editor.set("bell", "visual")
+ *
+ *	editor.hook("BufWritePost", function(ev)
+ *		editor.status("saved " .. ev.file_name)
+ *	end)
+ *
+ * Lines and columns are one based, like the L,C shown in the status bar.
+ */
+
+func (e *Editor) startScripting() {
+	dir := configDir()
+	if dir == "" {
+		return
+	}
+	path := filepath.Join(dir, "init.lua")
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+
+	L := lua.NewState()
+	L.SetGlobal("editor", L.SetFuncs(L.NewTable(), e.scriptAPI()))
+	e.lua = L
+
+	if err := L.DoFile(path); err != nil {
+		e.setStatusMsg("init.lua: %s", err)
+	}
+}
+
+func (e *Editor) stopScripting() {
+	if e.lua != nil {
+		e.lua.Close()
+		e.lua = nil
+	}
+}
+
+func (e *Editor) scriptAPI() map[string]lua.LGFunction {
+	return map[string]lua.LGFunction{
+		"line_count": func(L *lua.LState) int {
+			L.Push(lua.LNumber(len(e.buf.lines)))
+			return 1
+		},
+		"get_line": func(L *lua.LState) int {
+			row := scriptLine(L, 1, len(e.buf.lines))
+			L.Push(lua.LString(string(e.buf.lines[row].chars)))
+			return 1
+		},
+		"set_line": func(L *lua.LState) int {
+			e.scriptCheckWritable(L)
+			row := scriptLine(L, 1, len(e.buf.lines))
+			scriptCheckProtected(L, e.buf.lineProtected(row))
+			e.buf.lines[row].chars = []rune(L.CheckString(2))
+			e.buf.lines[row].render = e.updateRow(e.buf.lines[row].chars)
+			e.buf.dirty = true
+			e.changes++
+			return 0
+		},
+		"insert_line": func(L *lua.LState) int {
+			e.scriptCheckWritable(L)
+			row := scriptLine(L, 1, len(e.buf.lines)+1)
+			scriptCheckProtected(L, !e.canInsertRow(row))
+			e.insertRow(row, L.OptString(2, ""))
+			return 0
+		},
+		"delete_line": func(L *lua.LState) int {
+			e.scriptCheckWritable(L)
+			row := scriptLine(L, 1, len(e.buf.lines))
+			scriptCheckProtected(L, e.buf.lineProtected(row))
+			e.deleteRow(row)
+			e.snapCursor()
+			return 0
+		},
+		"protect": func(L *lua.LState) int {
+			last := scriptLine(L, 2, len(e.buf.lines))
+			first := scriptLine(L, 1, last+1)
+			e.buf.protected = append(e.buf.protected, lineRange{start: first, end: last})
+			return 0
+		},
+		"get_cursor": func(L *lua.LState) int {
+			L.Push(lua.LNumber(e.buf.cursor.y + 1))
+			L.Push(lua.LNumber(e.buf.cursor.x + 1))
+			return 2
+		},
+		"set_cursor": func(L *lua.LState) int {
+			e.setCursor(point{y: L.CheckInt(1) - 1, x: L.OptInt(2, 1) - 1})
+			e.snapCursor()
+			return 0
+		},
+		"file_name": func(L *lua.LState) int {
+			L.Push(lua.LString(e.buf.fileName))
+			return 1
+		},
+		"prompt": func(L *lua.LState) int {
+			msg := strings.ReplaceAll(L.CheckString(1), "%", "%%")
+			if L.GetTop() < 2 {
+				L.Push(lua.LString(e.prompt(msg + "%s")))
+				return 1
+			}
+			input, _ := e.readPrompt(msg+"%s", nil, nil, completeFrom(scriptStrings(L, 2)))
+			L.Push(lua.LString(input))
+			return 1
+		},
+		"popup": func(L *lua.LState) int {
+			e.ShowPopup(L.CheckString(1), scriptStrings(L, 2))
+			return 0
+		},
+		"confirm": func(L *lua.LState) int {
+			L.Push(lua.LBool(e.Confirm(L.CheckString(1))))
+			return 1
+		},
+		"pick": func(L *lua.LState) int {
+			i, ok := e.Pick(L.CheckString(1), scriptStrings(L, 2))
+			if !ok {
+				L.Push(lua.LNil)
+			} else {
+				L.Push(lua.LNumber(i + 1))
+			}
+			return 1
+		},
+		"status": func(L *lua.LState) int {
+			e.setStatusMsg("%s", L.CheckString(1))
+			return 0
+		},
+		"command": func(L *lua.LState) int {
+			name := L.CheckString(1)
+			fn := L.CheckFunction(2)
+			e.actionDispatch[name] = func() {
+				if err := L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}); err != nil {
+					e.setStatusMsg("%s: %s", name, err)
+				}
+			}
+			return 0
+		},
+		"set": func(L *lua.LState) int {
+			if err := e.setOption(L.CheckString(1), L.CheckString(2)); err != nil {
+				L.RaiseError("%s", err)
+			}
+			return 0
+		},
+		"bind": func(L *lua.LState) int {
+			if err := e.bindKey(L.CheckString(1), L.CheckString(2)); err != nil {
+				L.RaiseError("%s", err)
+			}
+			return 0
+		},
+		"hook": func(L *lua.LState) int {
+			h := Hook(L.CheckString(1))
+			fn := L.CheckFunction(2)
+			if !hookNames[h] {
+				L.ArgError(1, "unknown hook")
+			}
+			e.addHook(h, func(ev HookEvent) {
+				if e.lua != L {
+					return // the script has been stopped
+				}
+				t := L.NewTable()
+				t.RawSetString("hook", lua.LString(ev.Hook))
+				t.RawSetString("file_name", lua.LString(ev.FileName))
+				t.RawSetString("line", lua.LNumber(ev.Line+1))
+				t.RawSetString("col", lua.LNumber(ev.Col+1))
+				t.RawSetString("line_count", lua.LNumber(ev.LineCount))
+				if err := L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, t); err != nil {
+					e.setStatusMsg("%s hook: %s", h, err)
+				}
+			})
+			return 0
+		},
+		"new_buffer": func(L *lua.LState) int {
+			e.NewBuffer(L.OptString(1, ""))
+			return 0
+		},
+		"run": func(L *lua.LState) int {
+			L.Push(lua.LBool(e.runAction(L.CheckString(1))))
+			return 1
+		},
+	}
+}
+
+/* scriptStrings returns the strings in the table argument n. */
+func scriptStrings(L *lua.LState, n int) []string {
+	t := L.CheckTable(n)
+	strs := []string{}
+	for i := 1; i <= t.Len(); i++ {
+		strs = append(strs, lua.LVAsString(t.RawGetInt(i)))
+	}
+	return strs
+}
+
+/* scriptLine returns the zero based line for the one based line argument n, which must be at most limit. */
+func scriptLine(L *lua.LState, n int, limit int) int {
+	row := L.CheckInt(n)
+	if row < 1 || row > limit {
+		L.ArgError(n, "line out of range")
+	}
+	return row - 1
+}
+
+func scriptCheckProtected(L *lua.LState, protected bool) {
+	if protected {
+		L.RaiseError("line is protected")
+	}
+}
+
+func (e *Editor) scriptCheckWritable(L *lua.LState) {
+	if e.isReadonly() {
+		L.RaiseError("buffer is read-only")
+	}
+}
@@ -0,0 +1,143 @@
+package editor
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * Background search
+ *
+ * The lines of the buffer are scanned in a goroutine so that searching a very
+ * large file shows its progress and can be cancelled with escape. Other keys
+ * are ignored while it runs, and queued tasks wait, so nothing changes the
+ * lines under the scan.
+ *
+ * Queries can span lines: "\n" in a query stands for a line break, and a
+ * query starting with a flag group such as "(?s)" or "(?i)" is a regular
+ * expression, where ^ and $ match at the start and end of lines. Such
+ * queries are matched against the lines joined by line breaks. Once they
+ * are joined the lines are no longer read, so cancelling such a search does
+ * not wait for the matching, which finishes on its own.
+ */
+
+const searchChunk = 4096 // lines scanned between checks for cancellation
+
+/* searchPattern returns the regular expression matching query across lines, or nil for a plain query matched line by line. */
+func searchPattern(query string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(query, "(?") {
+		return regexp.Compile("(?m)" + query)
+	}
+	if strings.Contains(query, `\n`) {
+		return regexp.Compile(regexp.QuoteMeta(strings.ReplaceAll(query, `\n`, "\n")))
+	}
+	return nil, nil
+}
+
+/*
+joinedMatches returns the starts of the matches of re in lines joined by line
+breaks, and false if stop is closed first. It counts the lines joined in
+scanned and closes joined when it no longer reads the lines.
+*/
+func joinedMatches(lines []line, re *regexp.Regexp, stop <-chan struct{}, scanned *int64, joined chan<- struct{}) ([]point, bool) {
+	var sb strings.Builder
+	starts := make([]int, len(lines)) // byte offset of each line
+	for i, l := range lines {
+		if i%searchChunk == 0 {
+			select {
+			case <-stop:
+				close(joined)
+				return nil, false
+			default:
+			}
+			atomic.StoreInt64(scanned, int64(i))
+		}
+		starts[i] = sb.Len()
+		sb.WriteString(string(l.chars))
+		sb.WriteByte('\n')
+	}
+	text := sb.String()
+	atomic.StoreInt64(scanned, int64(len(lines)))
+	close(joined)
+
+	points := []point{}
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		if m[0] == m[1] || m[0] >= len(text) {
+			continue // empty matches are not found text
+		}
+		y := sort.Search(len(starts), func(i int) bool { return starts[i] > m[0] }) - 1
+		points = append(points, point{y: y, x: utf8.RuneCountInString(text[starts[y]:m[0]])})
+	}
+	return points, true
+}
+
+/* searchBuffer returns the matches of query in the current buffer, and false if the user cancelled the search. */
+func (e *Editor) searchBuffer(query string) ([]point, bool) {
+	lines := e.buf.lines
+	var scanned int64
+	stop := make(chan struct{})
+	results := make(chan []point, 1)
+	joined := make(chan struct{}) // closed when the scan no longer reads the lines
+	re, _ := searchPattern(query) // find checked the pattern
+
+	go func() {
+		if re != nil {
+			points, _ := joinedMatches(lines, re, stop, &scanned, joined)
+			results <- points
+			return
+		}
+		defer close(joined)
+		points := []point{}
+		for row, l := range lines {
+			if row%searchChunk == 0 {
+				select {
+				case <-stop:
+					results <- nil
+					return
+				default:
+				}
+				atomic.StoreInt64(&scanned, int64(row))
+			}
+			points = append(points, searchPoints(row+1, string(l.chars), query)...)
+		}
+		results <- points
+	}()
+
+	/* most searches are done before there is any point in showing progress */
+	select {
+	case points := <-results:
+		return points, true
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	defer e.setStatusMsg("")
+	for {
+		select {
+		case points := <-results:
+			return points, true
+		default:
+		}
+
+		e.setStatusMsg("Searching... %d%% (ESC to cancel)", atomic.LoadInt64(&scanned)*100/int64(len(lines)))
+		e.refreshScreen()
+
+		k, err := e.term.ReadKey()
+		if err != nil && err != ErrNoInput {
+			return <-results, true // keys can not be read, let the search finish
+		}
+		cancel := e.ctx.Err() != nil
+		if err == nil && k == '\x1b' {
+			_, more, _ := e.readEscapeByte() // an escape sequence is a key pressed too early, not a cancel
+			cancel = !more
+		}
+		if cancel {
+			close(stop)
+			<-joined
+			return nil, false
+		}
+	}
+}
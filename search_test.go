@@ -0,0 +1,58 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testLines(text string) []line {
+	var lines []line
+	for _, s := range strings.Split(text, "\n") {
+		lines = append(lines, line{chars: []rune(s)})
+	}
+	return lines
+}
+
+func TestJoinedMatches(t *testing.T) {
+	text := "func a() {\n\treturn\n}\nfunc bé() {\n}"
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{`}\nfunc`, "[{0 2}]"},
+		{`(?m)^func`, "[{0 0} {0 3}]"},
+		{`(?i)FUNC B`, "[{0 3}]"},
+		{`(?)é\(`, "[{6 3}]"},
+		{`(?s)\{.*?\}`, "[{9 0} {10 3}]"},
+		{`(?m)^`, "[]"},
+	} {
+		re, err := searchPattern(tc.query)
+		if err != nil || re == nil {
+			t.Fatalf("%q is not a pattern: %v", tc.query, err)
+		}
+		var scanned int64
+		points, ok := joinedMatches(testLines(text), re, make(chan struct{}), &scanned, make(chan struct{}))
+		if got := fmt.Sprint(points); !ok || got != tc.want {
+			t.Errorf("%q matches at %s, want %s", tc.query, got, tc.want)
+		}
+		if scanned != 5 {
+			t.Errorf("%q scanned %d lines, want 5", tc.query, scanned)
+		}
+	}
+}
+
+func TestJoinedMatchesStopped(t *testing.T) {
+	stop, joined := make(chan struct{}), make(chan struct{})
+	close(stop)
+	var scanned int64
+	re, _ := searchPattern(`(?s)a`)
+	if _, ok := joinedMatches(testLines("a\na"), re, stop, &scanned, joined); ok {
+		t.Error("a stopped search was not cancelled")
+	}
+	select {
+	case <-joined:
+	default:
+		t.Error("a stopped search still reads the lines")
+	}
+}
@@ -0,0 +1,42 @@
+package editor
+
+import "golang.org/x/sys/unix"
+
+/*-----------------------------------------------------------------------------
+ * Secure mode
+ *
+ * For editing secrets. Nothing but the file itself is written: no local
+ * history, no autosave and no audit log, and no copies of the text are kept
+ * for playback, as checkpoints or for undo_replace. Core dumps are turned off and the
+ * text of the buffers is overwritten with zeros when the editor exits, so
+ * nothing is left in memory the editor controls. Copies the Go runtime has
+ * made, such as strings handed to hooks and plugins, are out of its reach.
+ */
+
+// WithSecure turns on secure mode, for editing secrets that must not be
+// written anywhere but to the file itself.
+func WithSecure() Option {
+	return func(e *Editor) { e.secure = true }
+}
+
+/* disableCoreDumps makes sure a crash does not write the text to a core file. */
+func disableCoreDumps() error {
+	return unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0})
+}
+
+/* wipeBuffers overwrites the text of all buffers, the lines narrowing hides included, with zeros and empties them, and drops the other copies of it. */
+func (e *Editor) wipeBuffers() {
+	e.replaceUndo, e.lastRows = nil, nil
+	for _, b := range e.buffers {
+		for _, l := range b.allLines() {
+			for i := range l.chars {
+				l.chars[i] = 0
+			}
+			for i := range l.render {
+				l.render[i] = 0
+			}
+		}
+		b.lines, b.narrow = nil, nil
+		b.dirty = false
+	}
+}
@@ -0,0 +1,41 @@
+package editor
+
+import "testing"
+
+func TestWipeNarrowedBuffer(t *testing.T) {
+	e := newEditor(NewHeadless(8, 40))
+	e.addBuffer()
+	for i, s := range []string{"above", "shown", "below"} {
+		e.insertRow(i, s)
+	}
+	all := e.buf.allLines()
+	e.buf.narrow = &narrowing{above: all[:1], below: all[2:]}
+	e.buf.lines = all[1:2]
+
+	e.wipeBuffers()
+	for _, l := range all {
+		for _, r := range l.chars {
+			if r != 0 {
+				t.Fatalf("%q is left after wiping", string(l.chars))
+			}
+		}
+	}
+}
+
+func TestSecureKeepsNoUndo(t *testing.T) {
+	for _, secure := range []bool{false, true} {
+		e := newEditor(NewHeadless(8, 40))
+		e.addBuffer()
+		e.insertRow(0, "secret")
+		e.secure = secure
+		e.upcase()
+		if kept := e.replaceUndo != nil; kept == secure {
+			t.Errorf("secure %v: undo kept is %v", secure, kept)
+		}
+		e.lastRows = []string{"SECRET"}
+		e.wipeBuffers()
+		if e.replaceUndo != nil || e.lastRows != nil {
+			t.Errorf("secure %v: copies of the text are left after wiping", secure)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+)
+
+/*-----------------------------------------------------------------------------
+ * Selection
+ *
+ * The "set_mark" action, ctrl+space by default, puts the mark at the cursor,
+ * and the text between the mark and the cursor is selected and drawn in
+ * reverse video until set_mark is used again or escape is pressed. Finding
+ * and replacing offer to stay within the selection. Backspace and delete
+ * delete the selected text, and typing replaces it.
+ */
+
+const selectionStyle = "\x1b[7m"
+
+func (e *Editor) setMark() {
+	if e.buf.mark != nil {
+		e.buf.mark = nil
+		e.setStatusMsg("Mark cleared")
+		return
+	}
+	m := e.buf.cursor
+	e.buf.mark = &m
+	e.setStatusMsg("Mark set")
+}
+
+/* selection returns the start and end of the selected text of b, and whether there is any. */
+func (b *buffer) selection() (point, point, bool) {
+	if b.mark == nil {
+		return point{}, point{}, false
+	}
+	a, c := *b.mark, b.cursor
+	if c.y < a.y || (c.y == a.y && c.x < a.x) {
+		a, c = c, a
+	}
+	clamp := func(p point) point {
+		if p.y >= len(b.lines) {
+			return point{y: len(b.lines)}
+		}
+		if p.x > len(b.lines[p.y].chars) {
+			p.x = len(b.lines[p.y].chars)
+		}
+		return p
+	}
+	a, c = clamp(a), clamp(c)
+	return a, c, a != c
+}
+
+/* inSelection reports whether p is within the selection from a to c. */
+func inSelection(p, a, c point) bool {
+	if p.y < a.y || p.y > c.y {
+		return false
+	}
+	if p.y == a.y && p.x < a.x {
+		return false
+	}
+	return p.y != c.y || p.x < c.x
+}
+
+/* selectionMarks adds the selected text on the screen to marks, leaving columns that are already marked alone. */
+func (e *Editor) selectionMarks(marks map[int]map[int]string) map[int]map[int]string {
+	a, c, ok := e.buf.selection()
+	if !ok {
+		return marks
+	}
+	if marks == nil {
+		marks = map[int]map[int]string{}
+	}
+	for y := e.buf.fileY; y < e.buf.fileY+e.termRows && y < len(e.buf.lines); y++ {
+		if y < a.y || y > c.y {
+			continue
+		}
+		from, to := 0, len(e.buf.lines[y].chars)+1 // the line break is selected too
+		if y == a.y {
+			from = a.x
+		}
+		if y == c.y {
+			to = c.x
+		}
+		if marks[y] == nil {
+			marks[y] = map[int]string{}
+		}
+		l := e.buf.lines[y]
+		for x := from; x < to; x++ {
+			rx, next := e.lineRx(l, x), e.lineRx(l, x)+1
+			if x < len(l.chars) {
+				next = e.lineRx(l, x+1) // all the columns of a tab
+			}
+			for ; rx < next; rx++ {
+				if _, ok := marks[y][rx]; !ok {
+					marks[y][rx] = selectionStyle
+				}
+			}
+		}
+	}
+	return marks
+}
+
+/* useSelection asks whether to stay within the selection, if there is one, and returns its bounds if so. */
+func (e *Editor) useSelection(what string) (point, point, bool) {
+	a, c, ok := e.buf.selection()
+	if !ok || !e.Confirm(what+" only in the selection?") {
+		return point{}, point{}, false
+	}
+	return a, c, true
+}
+
+/* selectedLines returns the first and last line of the selection, leaving out the line it ends at the start of. */
+func (b *buffer) selectedLines() (int, int, bool) {
+	a, c, ok := b.selection()
+	if !ok {
+		return 0, 0, false
+	}
+	last := c.y
+	if last == len(b.lines) || (c.x == 0 && c.y > a.y) {
+		last-- // the selection ends at the start of the line after
+	}
+	return a.y, last, true
+}
+
+/* textRange returns the text of b from a up to c. */
+func (b *buffer) textRange(a, c point) string {
+	var sb strings.Builder
+	for y := a.y; y <= c.y && y < len(b.lines); y++ {
+		chars := b.lines[y].chars
+		lo, hi := 0, len(chars)
+		if y == a.y {
+			lo = a.x
+		}
+		if y == c.y {
+			hi = c.x
+		}
+		if y > a.y {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(string(chars[lo:hi]))
+	}
+	if c.y == len(b.lines) && a.y < c.y {
+		sb.WriteByte('\n') // the selection ends after the last line
+	}
+	return sb.String()
+}
+
+/* replaceSelection replaces the selected text with s, as one change undo_replace undoes, and selects the new text. */
+func (e *Editor) replaceSelection(s string) {
+	b := e.buf
+	a, c, _ := b.selection()
+	u := replaceSnapshot(b)
+	e.replaceRange(a, c, s)
+	e.keepReplaceUndo(u)
+	lines := strings.Split(s, "\n")
+	end := point{y: a.y + len(lines) - 1, x: len([]rune(lines[len(lines)-1]))}
+	if len(lines) == 1 {
+		end.x += a.x
+	}
+	e.buf.mark = &a
+	e.setCursor(end)
+	e.snapCursor()
+}
+
+/* typeOverSelection deletes the selection for a key that deletes or types text, and reports whether the key has been handled. */
+func (e *Editor) typeOverSelection(k int) bool {
+	a, c, ok := e.buf.selection()
+	if !ok {
+		return false
+	}
+	deletes := k == kBackSpace || k == kDelete || k == ctrlKey('h')
+	if !deletes && (k >= kArrowUp || !unicode.IsPrint(rune(k))) {
+		return false
+	}
+	last := c.y
+	if last == len(e.buf.lines) {
+		last--
+	}
+	if !e.canEdit(a.y, last) {
+		return true
+	}
+	e.replaceRange(a, c, "")
+	e.buf.mark = nil
+	e.setCursor(a)
+	e.snapCursor()
+	return deletes
+}
@@ -0,0 +1,98 @@
+package editor
+
+import "strings"
+
+/*-----------------------------------------------------------------------------
+ * Sentences
+ *
+ * "forward_sentence", alt+e, goes to the start of the next sentence and
+ * "backward_sentence", alt+a, to the start of the sentence the cursor is in,
+ * or of the one before if it is at its start already. A sentence starts
+ * after a ., ! or ?, and any closing quotes and brackets following it, and
+ * spaces or a line break, and at the start of a paragraph.
+ */
+
+const sentenceClosers = `)]}"'’”`
+
+/* sentenceStart reports whether a sentence starts at p. */
+func (b *buffer) sentenceStart(p point) bool {
+	if p.y >= len(b.lines) || strings.ContainsRune(" \t\n", b.charAt(p)) {
+		return false
+	}
+	q, breaks, spaces := p, 0, 0
+	for {
+		prev, ok := b.prevPoint(q)
+		if !ok {
+			return true // the start of the buffer
+		}
+		r := b.charAt(prev)
+		if !strings.ContainsRune(" \t\n", r) {
+			break
+		}
+		if r == '\n' {
+			breaks++
+		}
+		spaces++
+		q = prev
+	}
+	if breaks >= 2 {
+		return true // after a blank line
+	}
+	if spaces == 0 {
+		return false
+	}
+	for {
+		prev, ok := b.prevPoint(q)
+		if !ok {
+			return false
+		}
+		r := b.charAt(prev)
+		if strings.ContainsRune(".!?", r) {
+			return true
+		}
+		if !strings.ContainsRune(sentenceClosers, r) {
+			return false
+		}
+		q = prev
+	}
+}
+
+func (e *Editor) forwardSentence() {
+	b := e.buf
+	p := b.cursor
+	for {
+		next, ok := b.nextPoint(p)
+		if !ok {
+			if p == b.cursor {
+				e.bell()
+			}
+			b.cursor = p
+			return
+		}
+		p = next
+		if b.sentenceStart(p) {
+			b.cursor = p
+			return
+		}
+	}
+}
+
+func (e *Editor) backwardSentence() {
+	b := e.buf
+	p := b.cursor
+	for {
+		prev, ok := b.prevPoint(p)
+		if !ok {
+			if p == b.cursor {
+				e.bell()
+			}
+			b.cursor = p
+			return
+		}
+		p = prev
+		if b.sentenceStart(p) {
+			b.cursor = p
+			return
+		}
+	}
+}
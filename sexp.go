@@ -0,0 +1,245 @@
+package editor
+
+import "strings"
+
+/*-----------------------------------------------------------------------------
+ * Balanced expressions
+ *
+ * The bracket scanner finds the bracket matching the one at a point,
+ * counting (), [] and {} together so that a group only closes with its own
+ * kind of bracket. Brackets in double quoted strings count only when the
+ * starting bracket is in a string as well, so {"a": ")"} is one group.
+ *
+ * "forward_sexp", alt+), goes over the balanced expression after the cursor:
+ * a bracketed group, a string or a run of other characters up to a space or
+ * a bracket, and "backward_sexp", alt+(, over the one before it. Both go
+ * over spaces and line breaks first, and stop at the bracket closing or
+ * opening the group the cursor is in.
+ */
+
+const (
+	openBrackets  = "([{"
+	closeBrackets = ")]}"
+)
+
+/* stringMask reports for each character of chars whether it is in a double quoted string, the quotes included. */
+func stringMask(chars []rune) []bool {
+	mask := make([]bool, len(chars))
+	in := false
+	for i := 0; i < len(chars); i++ {
+		switch {
+		case chars[i] == '"':
+			mask[i] = true
+			in = !in
+		case in:
+			mask[i] = true
+			if chars[i] == '\\' && i+1 < len(chars) {
+				i++
+				mask[i] = true
+			}
+		}
+	}
+	return mask
+}
+
+/* bracketKind returns the index of the bracket r in openBrackets or closeBrackets, -1 if it is not one. */
+func bracketKind(r rune) int {
+	if i := strings.IndexRune(openBrackets, r); i >= 0 {
+		return i
+	}
+	return strings.IndexRune(closeBrackets, r)
+}
+
+/* matchBracket returns the bracket matching the one at p, scanning forward from an opening bracket and backward from a closing one. */
+func (b *buffer) matchBracket(p point) (point, bool) {
+	if p.y >= len(b.lines) || p.x >= len(b.lines[p.y].chars) {
+		return point{}, false
+	}
+	r := b.lines[p.y].chars[p.x]
+	dir, opens := 1, openBrackets
+	if strings.ContainsRune(closeBrackets, r) {
+		dir, opens = -1, closeBrackets // going backwards closing brackets open a group
+	} else if !strings.ContainsRune(openBrackets, r) {
+		return point{}, false
+	}
+	inString := stringMask(b.lines[p.y].chars)[p.x]
+
+	var stack []int // kinds of the groups not yet closed, innermost last
+	for y := p.y; y >= 0 && y < len(b.lines); y += dir {
+		chars := b.lines[y].chars
+		mask := stringMask(chars)
+		x := len(chars) - 1
+		if dir > 0 {
+			x = 0
+		}
+		if y == p.y {
+			x = p.x
+		}
+		for ; x >= 0 && x < len(chars); x += dir {
+			c := chars[x]
+			k := bracketKind(c)
+			if k < 0 || mask[x] != inString {
+				continue
+			}
+			if strings.ContainsRune(opens, c) {
+				stack = append(stack, k)
+				continue
+			}
+			if stack[len(stack)-1] != k {
+				return point{}, false // closed by the wrong kind
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return point{x: x, y: y}, true
+			}
+		}
+	}
+	return point{}, false
+}
+
+/* charAt returns the character at p, '\n' at the end of a line. */
+func (b *buffer) charAt(p point) rune {
+	if p.x < len(b.lines[p.y].chars) {
+		return b.lines[p.y].chars[p.x]
+	}
+	return '\n'
+}
+
+/* nextPoint returns the point after p, false at the end of the buffer. */
+func (b *buffer) nextPoint(p point) (point, bool) {
+	switch {
+	case p.y >= len(b.lines):
+		return p, false
+	case p.x < len(b.lines[p.y].chars):
+		return point{x: p.x + 1, y: p.y}, true
+	case p.y+1 < len(b.lines):
+		return point{y: p.y + 1}, true
+	}
+	return p, false
+}
+
+/* prevPoint returns the point before p, false at the start of the buffer. */
+func (b *buffer) prevPoint(p point) (point, bool) {
+	switch {
+	case p.y >= len(b.lines):
+		if len(b.lines) == 0 {
+			return p, false
+		}
+		y := len(b.lines) - 1
+		return point{x: len(b.lines[y].chars), y: y}, true
+	case p.x > 0:
+		return point{x: p.x - 1, y: p.y}, true
+	case p.y > 0:
+		return point{x: len(b.lines[p.y-1].chars), y: p.y - 1}, true
+	}
+	return p, false
+}
+
+/* atomChar reports whether r is part of a run of characters making an expression of its own. */
+func atomChar(r rune) bool {
+	return r != '\n' && r != ' ' && r != '\t' && r != '"' && !strings.ContainsRune(openBrackets+closeBrackets, r)
+}
+
+/* skipSpace returns the first point from p on, or before p if back is set, that is not a space, a tab or a line break. */
+func (b *buffer) skipSpace(p point, back bool) (point, bool) {
+	for {
+		q := p
+		if back {
+			var ok bool
+			if q, ok = b.prevPoint(p); !ok {
+				return p, false
+			}
+		}
+		if q.y >= len(b.lines) {
+			return p, false
+		}
+		if !strings.ContainsRune(" \t\n", b.charAt(q)) {
+			return p, true
+		}
+		var ok bool
+		if back {
+			p = q
+		} else if p, ok = b.nextPoint(p); !ok {
+			return p, false
+		}
+	}
+}
+
+/* sexpEnd returns the point after the balanced expression after p. */
+func (b *buffer) sexpEnd(p point) (point, bool) {
+	p, ok := b.skipSpace(p, false)
+	if !ok {
+		return p, false
+	}
+	r := b.charAt(p)
+	switch {
+	case strings.ContainsRune(closeBrackets, r):
+		return p, false
+	case strings.ContainsRune(openBrackets, r):
+		m, ok := b.matchBracket(p)
+		return point{x: m.x + 1, y: m.y}, ok
+	case r == '"':
+		chars := b.lines[p.y].chars
+		for x := p.x + 1; x < len(chars); x++ {
+			switch chars[x] {
+			case '\\':
+				x++
+			case '"':
+				return point{x: x + 1, y: p.y}, true
+			}
+		}
+		return p, false
+	}
+	for p.x < len(b.lines[p.y].chars) && atomChar(b.charAt(p)) {
+		p.x++
+	}
+	return p, true
+}
+
+/* sexpStart returns the start of the balanced expression before p. */
+func (b *buffer) sexpStart(p point) (point, bool) {
+	p, ok := b.skipSpace(p, true)
+	if !ok {
+		return p, false
+	}
+	q, _ := b.prevPoint(p)
+	r := b.charAt(q)
+	switch {
+	case strings.ContainsRune(openBrackets, r):
+		return p, false
+	case strings.ContainsRune(closeBrackets, r):
+		return b.matchBracket(q)
+	case r == '"':
+		chars := b.lines[q.y].chars
+		mask := stringMask(chars)
+		x := q.x - 1
+		for x >= 0 && !(chars[x] == '"' && (x == 0 || !mask[x-1])) {
+			x--
+		}
+		return point{x: x, y: q.y}, x >= 0
+	}
+	for p.x > 0 && atomChar(b.lines[p.y].chars[p.x-1]) {
+		p.x--
+	}
+	return p, true
+}
+
+func (e *Editor) forwardSexp() {
+	p, ok := e.buf.sexpEnd(e.buf.cursor)
+	if !ok {
+		e.setStatusMsg("No expression after the cursor")
+		e.bell()
+		return
+	}
+	e.buf.cursor = p
+}
+
+func (e *Editor) backwardSexp() {
+	p, ok := e.buf.sexpStart(e.buf.cursor)
+	if !ok {
+		e.setStatusMsg("No expression before the cursor")
+		e.bell()
+		return
+	}
+	e.buf.cursor = p
+}
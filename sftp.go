@@ -0,0 +1,208 @@
+package editor
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+/*-----------------------------------------------------------------------------
+ * Remote files
+ *
+ * Files named like user@host:/etc/hosts, as with scp, or sftp://user@host:port/path
+ * are read and written over SFTP. Keys are taken from the SSH agent and from
+ * ~/.ssh, and the host key must be in ~/.ssh/known_hosts. Connections are kept
+ * open until the editor exits.
+ */
+
+type remotePath struct {
+	user string
+	host string
+	port string
+	path string
+}
+
+func (rp remotePath) addr() string {
+	return net.JoinHostPort(rp.host, rp.port)
+}
+
+/* parseRemotePath splits a remote file name, reporting false for local ones. */
+func parseRemotePath(name string) (remotePath, bool) {
+	rp := remotePath{port: "22"}
+
+	if strings.HasPrefix(name, "sftp://") {
+		u, err := url.Parse(name)
+		if err != nil || u.Hostname() == "" {
+			return rp, false
+		}
+		rp.user = u.User.Username()
+		rp.host = u.Hostname()
+		if u.Port() != "" {
+			rp.port = u.Port()
+		}
+		rp.path = u.Path
+		return rp, true
+	}
+
+	if strings.Contains(name, "://") {
+		return rp, false // some other kind of URL
+	}
+
+	/* scp style, which needs a user or an absolute path to not be mistaken for a local name */
+	i := strings.Index(name, ":")
+	if i <= 0 || strings.ContainsAny(name[:i], "/\\") {
+		return rp, false
+	}
+	rp.host, rp.path = name[:i], name[i+1:]
+	if at := strings.LastIndex(rp.host, "@"); at >= 0 {
+		rp.user, rp.host = rp.host[:at], rp.host[at+1:]
+	} else if !strings.HasPrefix(rp.path, "/") {
+		return rp, false
+	}
+	if rp.host == "" || rp.path == "" {
+		return rp, false
+	}
+	return rp, true
+}
+
+type sftpConn struct {
+	ssh   *ssh.Client
+	sftp  *sftp.Client
+	agent net.Conn
+}
+
+func (c *sftpConn) close() {
+	c.sftp.Close()
+	c.ssh.Close()
+	if c.agent != nil {
+		c.agent.Close()
+	}
+}
+
+/* sftpClient returns a client connected to the host of rp, connecting if there is none. */
+func (e *Editor) sftpClient(rp remotePath) (*sftp.Client, error) {
+	if rp.user == "" {
+		if u, err := user.Current(); err == nil {
+			rp.user = u.Username
+		}
+	}
+	key := rp.user + "@" + rp.addr()
+	if c, ok := e.sftpConns[key]; ok {
+		return c.sftp, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("known hosts: %w", err)
+	}
+
+	conn := &sftpConn{}
+	auth := []ssh.AuthMethod{}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if a, err := net.Dial("unix", sock); err == nil {
+			conn.agent = a
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(a).Signers))
+		}
+	}
+	signers := []ssh.Signer{}
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		data, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		if s, err := ssh.ParsePrivateKey(data); err == nil {
+			signers = append(signers, s)
+		}
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+
+	conn.ssh, err = ssh.Dial("tcp", rp.addr(), &ssh.ClientConfig{
+		User:            rp.user,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         10 * time.Second,
+	})
+	if err != nil {
+		if conn.agent != nil {
+			conn.agent.Close()
+		}
+		return nil, err
+	}
+	conn.sftp, err = sftp.NewClient(conn.ssh)
+	if err != nil {
+		conn.ssh.Close()
+		if conn.agent != nil {
+			conn.agent.Close()
+		}
+		return nil, err
+	}
+
+	if e.sftpConns == nil {
+		e.sftpConns = map[string]*sftpConn{}
+	}
+	e.sftpConns[key] = conn
+	return conn.sftp, nil
+}
+
+func (e *Editor) closeSFTP() {
+	for key, c := range e.sftpConns {
+		c.close()
+		delete(e.sftpConns, key)
+	}
+}
+
+/* transferStatus shows what is being transferred, since it can take a while. */
+func (e *Editor) transferStatus(format string, a ...interface{}) {
+	e.setStatusMsg(format, a...)
+	e.refreshScreen()
+}
+
+/* openForReading opens the file name, which may be remote or a URL. */
+func (e *Editor) openForReading(name string) (io.ReadCloser, error) {
+	if isURL(name) {
+		return e.openURL(name)
+	}
+	rp, ok := parseRemotePath(name)
+	if !ok {
+		return os.Open(name)
+	}
+
+	e.transferStatus("fetching %s ...", name)
+	client, err := e.sftpClient(rp)
+	if err != nil {
+		return nil, err
+	}
+	return client.Open(rp.path)
+}
+
+/* createForWriting creates or truncates the file name, which may be remote. */
+func (e *Editor) createForWriting(name string) (io.WriteCloser, error) {
+	rp, ok := parseRemotePath(name)
+	if !ok {
+		return os.Create(name)
+	}
+
+	e.transferStatus("sending %s ...", name)
+	client, err := e.sftpClient(rp)
+	if err != nil {
+		return nil, err
+	}
+	return client.Create(rp.path)
+}
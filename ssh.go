@@ -0,0 +1,205 @@
+package editor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+/*-----------------------------------------------------------------------------
+ * SSH
+ */
+
+// ServeSSH accepts SSH connections on l and runs an editor of its own for
+// every session. The command given by the client, as in "ssh host notes.txt",
+// names the file to edit. Files are opened relative to the working directory
+// of the server and with its permissions, so config must only let trusted
+// users in. The editors of sessions run no plugins, no init script and no
+// control socket, and read no settings of the server user.
+func ServeSSH(l net.Listener, config *ssh.ServerConfig) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveSSHConn(conn, config)
+	}
+}
+
+func serveSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for nch := range chans {
+		if nch.ChannelType() != "session" {
+			nch.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, reqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSSHSession(ch, reqs)
+	}
+}
+
+/* sshTerminal is the terminal of an SSH session. */
+type sshTerminal struct {
+	ch      ssh.Channel
+	data    chan []byte   // input read from the channel
+	done    chan struct{} // closed when the editor of the session stops reading input
+	pending []byte        // input not yet returned by ReadKey
+	mu      sync.Mutex
+	rows    int
+	cols    int
+}
+
+func newSSHTerminal(ch ssh.Channel) *sshTerminal {
+	t := &sshTerminal{ch: ch, data: make(chan []byte), done: make(chan struct{}), rows: 24, cols: 80}
+	go func() {
+		for {
+			b := make([]byte, 256)
+			n, err := ch.Read(b)
+			if n > 0 {
+				select {
+				case t.data <- b[:n]:
+				case <-t.done:
+					return // nobody reads the input any more
+				}
+			}
+			if err != nil {
+				close(t.data)
+				return
+			}
+		}
+	}()
+	return t
+}
+
+func (t *sshTerminal) ReadKey() (byte, error) {
+	if len(t.pending) == 0 {
+		select {
+		case b, ok := <-t.data:
+			if !ok {
+				return 0, errInputClosed
+			}
+			t.pending = b
+		case <-time.After(100 * time.Millisecond):
+			return 0, ErrNoInput
+		}
+	}
+
+	k := t.pending[0]
+	t.pending = t.pending[1:]
+	return k, nil
+}
+
+func (t *sshTerminal) Write(p []byte) (int, error) {
+	return t.ch.Write(p)
+}
+
+func (t *sshTerminal) Size() (int, int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rows, t.cols, nil
+}
+
+/* RawMode does nothing, the client puts its terminal in raw mode. */
+func (t *sshTerminal) RawMode(on bool) error {
+	return nil
+}
+
+func (t *sshTerminal) resize(cols, rows uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rows, t.cols = int(rows), int(cols)
+}
+
+var errInputClosed = errors.New("input closed")
+
+func serveSSHSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	t := newSSHTerminal(ch)
+	started := false
+
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term   string
+				Cols   uint32
+				Rows   uint32
+				Width  uint32
+				Height uint32
+				Modes  string
+			}
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			t.resize(pty.Cols, pty.Rows)
+			req.Reply(true, nil)
+
+		case "window-change":
+			var win struct {
+				Cols   uint32
+				Rows   uint32
+				Width  uint32
+				Height uint32
+			}
+			if err := ssh.Unmarshal(req.Payload, &win); err == nil {
+				t.resize(win.Cols, win.Rows)
+			}
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+
+		case "shell", "exec":
+			if started {
+				req.Reply(false, nil)
+				continue
+			}
+			var fileName string
+			if req.Type == "exec" {
+				var exec struct{ Command string }
+				if err := ssh.Unmarshal(req.Payload, &exec); err != nil {
+					req.Reply(false, nil)
+					continue
+				}
+				fileName = strings.TrimSpace(exec.Command)
+			}
+			started = true
+			req.Reply(true, nil)
+			go runSSHSession(t, fileName)
+
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+func runSSHSession(t *sshTerminal, fileName string) {
+	defer t.ch.Close()
+	defer close(t.done)
+
+	status := uint32(0)
+	e := New(WithTerminal(t))
+	e.isolated = true // the server user's plugins, scripts and control socket are not the client's to use
+	if err := e.Run(context.Background(), fileName); err != nil {
+		io.WriteString(t.ch.Stderr(), err.Error()+"\r\n")
+		status = 1
+	}
+	t.ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+}
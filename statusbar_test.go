@@ -0,0 +1,43 @@
+package editor
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStatusBarNonASCII(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	name := "äöüäöüäöü.txt"
+	if err := os.WriteFile(name, []byte("text\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, cols := range []int{60, 30, 12} {
+		h := NewHeadless(6, cols)
+		h.Type("\x1b[C")
+		provider := WithStatusProvider(func(*Editor) string { return "schön ✓ überprüft" })
+		if _, err := h.Run(name, provider); err != nil {
+			t.Fatal(err)
+		}
+		var bar string
+		for _, row := range h.Screen() {
+			if strings.Contains(row, "[") {
+				bar = row
+			}
+		}
+		if !utf8.ValidString(bar) || strings.ContainsRune(bar, utf8.RuneError) {
+			t.Errorf("%d columns: the status bar %q is not valid text", cols, bar)
+		}
+		if n := utf8.RuneCountInString(bar); cols > 12 && (n != cols || !strings.HasSuffix(bar, "L1,C2")) {
+			t.Errorf("%d columns: the status bar %q is %d columns wide", cols, bar, n)
+		}
+	}
+}
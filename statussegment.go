@@ -0,0 +1,108 @@
+package editor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+/*-----------------------------------------------------------------------------
+ * Status segments
+ *
+ * Status segments are text in the status bar that goroutines update in the
+ * background, such as the git branch, the state of a language server or a
+ * clock. Setting the text of a segment asks the main loop to redraw, so it
+ * shows at once rather than with the next key press:
+ *
+ *	editor.New(editor.WithStatusUpdater(func(ctx context.Context, s *editor.StatusSegment) {
+ *		for {
+ *			s.Set(time.Now().Format("15:04"))
+ *			select {
+ *			case <-time.After(time.Minute):
+ *			case <-ctx.Done():
+ *				return
+ *			}
+ *		}
+ *	}))
+ */
+
+// StatusSegment is a piece of the status bar that can be changed from any
+// goroutine.
+type StatusSegment struct {
+	e    *Editor
+	mu   sync.Mutex
+	text string
+}
+
+// Set changes the text of the segment and redraws the screen if it changed.
+// An empty text hides the segment.
+func (s *StatusSegment) Set(text string) {
+	s.mu.Lock()
+	changed := s.text != text
+	s.text = text
+	s.mu.Unlock()
+	if changed {
+		s.e.Invalidate()
+	}
+}
+
+// Text returns the text of the segment.
+func (s *StatusSegment) Text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.text
+}
+
+// AddStatusSegment adds a segment to the status bar, after the text of the
+// status providers. It must be called before Run or on the editor's main
+// loop, but the segment can be set from anywhere.
+func (e *Editor) AddStatusSegment() *StatusSegment {
+	s := &StatusSegment{e: e}
+	e.statusProviders = append(e.statusProviders, func(*Editor) string { return s.Text() })
+	return s
+}
+
+// WithStatusUpdater adds a status segment and runs fn in a goroutine of its
+// own while the editor runs, to keep it up to date. The context is cancelled
+// when the editor exits.
+func WithStatusUpdater(fn func(ctx context.Context, s *StatusSegment)) Option {
+	return func(e *Editor) {
+		e.statusUpdaters = append(e.statusUpdaters, statusUpdater{fn: fn, seg: e.AddStatusSegment()})
+	}
+}
+
+type statusUpdater struct {
+	fn  func(context.Context, *StatusSegment)
+	seg *StatusSegment
+}
+
+// Invalidate asks the editor to redraw the screen soon. It is safe to call
+// from any goroutine and never waits; calls made before the redraw share it.
+func (e *Editor) Invalidate() {
+	if !atomic.CompareAndSwapInt32(&e.redrawQueued, 0, 1) {
+		return
+	}
+	select {
+	case e.tasks <- func() { atomic.StoreInt32(&e.redrawQueued, 0) }:
+	default:
+		atomic.StoreInt32(&e.redrawQueued, 0) // the queued tasks redraw anyway
+	}
+}
+
+func (e *Editor) startStatusUpdaters() {
+	if len(e.statusUpdaters) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	e.statusCancel = cancel
+	for _, u := range e.statusUpdaters {
+		go u.fn(ctx, u.seg)
+	}
+}
+
+func (e *Editor) stopStatusUpdaters() {
+	if e.statusCancel != nil {
+		e.statusCancel()
+		e.statusCancel = nil
+	}
+}
@@ -0,0 +1,69 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+/*-----------------------------------------------------------------------------
+ * Privileged write
+ *
+ * When a save fails because the file may not be written, the buffer can be
+ * piped through a privilege escalation command instead, "sudo tee" unless the
+ * sudo_command setting says otherwise. The file name is added as the last
+ * argument of the command.
+ */
+
+var defaultSudoCommand = []string{"sudo", "tee"}
+
+// WithSudoCommand sets the command, such as "doas tee", that the buffer is
+// piped through to save a file the user has no permission to write.
+func WithSudoCommand(command string) Option {
+	return func(e *Editor) { e.sudoCommand = strings.Fields(command) }
+}
+
+/* sudoWrite offers to save the current buffer with the sudo command and reports whether the user accepted. */
+func (e *Editor) sudoWrite() bool {
+	command := e.sudoCommand
+	if len(command) == 0 {
+		command = defaultSudoCommand
+	}
+	if !e.Confirm(fmt.Sprintf("Permission denied, save with %s?", strings.Join(command, " "))) {
+		return false
+	}
+
+	data, err := e.buf.fileData()
+	if err != nil {
+		e.setStatusMsg("error compressing: %s", err)
+		return true
+	}
+	cmd := exec.Command(command[0], append(command[1:], e.buf.fileName)...)
+	cmd.Stdin = bytes.NewReader(data) // the output of tee is not wanted
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	/* give the terminal back while the command may ask for a password */
+	e.setFocusReporting(false)
+	e.clearTerminal()
+	e.term.RawMode(false)
+	err = cmd.Run()
+	e.term.RawMode(true)
+	e.setFocusReporting(true)
+
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		e.setStatusMsg("%s: %s", command[0], msg)
+		return true
+	}
+
+	e.setStatusMsg("%d bytes written with %s", len(data), command[0])
+	e.buf.dirty = false
+	e.buf.modTime = localModTime(e.buf.fileName)
+	e.runHook(BufWritePost)
+	return true
+}
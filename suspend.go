@@ -0,0 +1,60 @@
+package editor
+
+import (
+	"os"
+	"os/signal"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+/*-----------------------------------------------------------------------------
+ * Suspend
+ *
+ * Raw mode turns off ISIG, so ctrl+z reaches the editor as a key instead of
+ * stopping it. The "suspend" action does what the terminal would have done:
+ * it restores the terminal, stops the process group with SIGTSTP and, once the
+ * shell continues it, goes back to raw mode and redraws the screen.
+ */
+
+func (e *Editor) suspend() {
+	if _, ok := e.term.(*ttyTerminal); !ok {
+		e.setStatusMsg("Can not suspend, not running on a terminal")
+		e.bell()
+		return
+	}
+
+	cont := make(chan os.Signal, 1)
+	signal.Notify(cont, unix.SIGCONT)
+	defer signal.Stop(cont)
+
+	e.autosaveBuffers()
+	e.setFocusReporting(false)
+	e.restoreTitle()
+	e.clearTerminal()
+	if err := e.term.RawMode(false); err != nil {
+		e.setStatusMsg("suspend: %s", err)
+		return
+	}
+	err := unix.Kill(0, unix.SIGTSTP)
+
+	/* Without job control the signal is ignored and nothing continues the process. */
+	if err == nil {
+		select {
+		case <-cont:
+		case <-time.After(time.Second):
+		}
+	}
+
+	if rerr := e.term.RawMode(true); rerr != nil {
+		e.setStatusMsg("suspend: %s", rerr)
+		return
+	}
+	e.setFocusReporting(true)
+	if err != nil {
+		e.setStatusMsg("suspend: %s", err)
+	}
+	e.checkResize()
+	e.clearTerminal()
+	e.refreshScreen()
+}
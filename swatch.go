@@ -0,0 +1,77 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+/*-----------------------------------------------------------------------------
+ * Colour swatches
+ *
+ * Lines with colours written as #RRGGBB or rgb(r, g, b) get a swatch in each
+ * colour after the end of the line, drawn with truecolor escapes, when there
+ * is room for it on the screen. The swatches setting turns them off.
+ */
+
+var (
+	hexColorPattern = regexp.MustCompile(`#([0-9a-fA-F]{6})\b`)
+	rgbColorPattern = regexp.MustCompile(`rgba?\(\s*(\d{1,3})[\s,]+(\d{1,3})[\s,]+(\d{1,3})\s*(?:[,/][^)]*)?\)`)
+)
+
+type rgb struct {
+	r, g, b int
+}
+
+/* findColors returns the colours written in s, in the order they appear. */
+func findColors(s string) []rgb {
+	type found struct {
+		at int
+		c  rgb
+	}
+	all := []found{}
+
+	for _, m := range hexColorPattern.FindAllStringSubmatchIndex(s, -1) {
+		v, _ := strconv.ParseUint(s[m[2]:m[3]], 16, 32)
+		all = append(all, found{m[0], rgb{int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)}})
+	}
+	for _, m := range rgbColorPattern.FindAllStringSubmatchIndex(s, -1) {
+		c := [3]int{}
+		ok := true
+		for i := range c {
+			c[i], _ = strconv.Atoi(s[m[2+2*i]:m[3+2*i]])
+			ok = ok && c[i] <= 255
+		}
+		if ok {
+			all = append(all, found{m[0], rgb{c[0], c[1], c[2]}})
+		}
+	}
+
+	/* the two patterns never overlap, so an insertion sort by position is enough */
+	for i := 1; i < len(all); i++ {
+		for j := i; j > 0 && all[j].at < all[j-1].at; j-- {
+			all[j], all[j-1] = all[j-1], all[j]
+		}
+	}
+	colors := make([]rgb, len(all))
+	for i, f := range all {
+		colors[i] = f.c
+	}
+	return colors
+}
+
+/* drawSwatches draws the colours of line y after the used columns of the screen row, as many as fit, and returns the columns now used. */
+func (e *Editor) drawSwatches(scrBuf *bytes.Buffer, y, used int) int {
+	if e.swatchesOff {
+		return used
+	}
+	for _, c := range findColors(string(e.buf.lines[y].chars)) {
+		if used+3 > e.textCols() {
+			break
+		}
+		fmt.Fprintf(scrBuf, " \x1b[38;2;%d;%d;%dm██\x1b[m%s", c.r, c.g, c.b, sgr(e.theme.Text))
+		used += 3
+	}
+	return used
+}
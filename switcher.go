@@ -0,0 +1,115 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+/*-----------------------------------------------------------------------------
+ * Buffer switcher
+ *
+ * The "switch_buffer" action lists the open buffers, modified ones marked
+ * with *, with their paths. Typing filters the list, enter switches to the
+ * highlighted buffer and d, or delete, closes it while no filter is typed.
+ */
+
+/* bufferTitle returns the name b is listed by. */
+func bufferTitle(b *buffer) string {
+	switch {
+	case b.fileName != "":
+		return filepath.Base(b.fileName)
+	case b.scratch:
+		return "Scratch"
+	}
+	return "No Name"
+}
+
+/* bufferPath returns the path of the file of b, absolute if it is local. */
+func bufferPath(b *buffer) string {
+	if _, remote := parseRemotePath(b.fileName); remote || isURL(b.fileName) || b.fileName == "" {
+		return b.fileName
+	}
+	if abs, err := filepath.Abs(b.fileName); err == nil {
+		return abs
+	}
+	return b.fileName
+}
+
+func (e *Editor) switchBuffer() {
+	var query []rune
+	var shown []*buffer // the buffers matching the query
+	o := &overlay{titleCursor: true}
+
+	filter := func(sel *buffer) {
+		q := strings.ToLower(string(query))
+		shown = shown[:0]
+		o.lines = o.lines[:0]
+		o.sel, o.top = 0, 0
+		for _, b := range e.buffers {
+			name, path := bufferTitle(b), bufferPath(b)
+			if !strings.Contains(strings.ToLower(name+" "+path), q) {
+				continue
+			}
+			mark := ' '
+			if b.dirty {
+				mark = '*'
+			}
+			if b == sel {
+				o.sel = len(shown)
+			}
+			shown = append(shown, b)
+			o.lines = append(o.lines, fmt.Sprintf("%c %-20s %s", mark, name, path))
+		}
+		o.title = fmt.Sprintf("Buffers (%d/%d): %s", len(shown), len(e.buffers), string(query))
+	}
+	filter(e.buf)
+
+	var chosen *buffer
+	o.key = func(k int) bool {
+		if o.scrollKey(k) {
+			return true
+		}
+		switch {
+		case k == '\x1b':
+			return false
+		case k == '\r':
+			if len(shown) == 0 {
+				return true
+			}
+			chosen = shown[o.sel]
+			return false
+		case len(query) == 0 && (k == 'd' || k == kDelete):
+			if len(shown) == 0 {
+				return true
+			}
+			b := shown[o.sel]
+			if b.dirty && !e.Confirm(fmt.Sprintf("Close %s and discard its changes?", bufferTitle(b))) {
+				return true
+			}
+			e.removeBuffer(b)
+			next := (*buffer)(nil)
+			if o.sel+1 < len(shown) {
+				next = shown[o.sel+1]
+			} else if o.sel > 0 {
+				next = shown[o.sel-1]
+			}
+			filter(next)
+		case k == kDelete || k == ctrlKey('h') || k == kBackSpace:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				filter(nil)
+			}
+		case unicode.IsPrint(rune(k)):
+			query = append(query, rune(k))
+			filter(nil)
+		}
+		return true
+	}
+
+	if err := e.runOverlay(o); err != nil || chosen == nil {
+		return
+	}
+	e.buf = chosen
+}
@@ -0,0 +1,97 @@
+package editor
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * File templates
+ *
+ * A buffer for a file that does not exist yet is filled from a template in
+ * templates in the configuration directory: the file named like the new file,
+ * such as "Makefile", or else the one named by its extension, such as "go" or
+ * "html". These placeholders are replaced in the template:
+ *
+ *	{{filename}}  the name of the file, such as main.go
+ *	{{name}}      the name without its extension, such as main
+ *	{{dir}}       the name of the directory of the file, as used for Go packages
+ *	{{date}}      today, as 2006-01-02
+ *	{{year}}      this year
+ *	{{user}}      the name of the user
+ *	{{cursor}}    where the cursor is put
+ *
+ * The "templates" setting turns templates off.
+ */
+
+/* templateFor returns the template for a new file name, or "" if there is none. */
+func (e *Editor) templateFor(name string) string {
+	if e.isolated || e.templatesOff || isURL(name) {
+		return ""
+	}
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	dir = filepath.Join(dir, "templates")
+	base := filepath.Base(name)
+	candidates := []string{base}
+	if ext := strings.TrimPrefix(filepath.Ext(base), "."); ext != "" {
+		candidates = append(candidates, ext)
+	}
+	for _, c := range candidates {
+		if data, err := os.ReadFile(filepath.Join(dir, c)); err == nil {
+			return string(data)
+		}
+	}
+	return ""
+}
+
+/* userName returns the full name of the user, or the login name. */
+func userName() string {
+	if u, err := user.Current(); err == nil {
+		if full, _, _ := strings.Cut(u.Name, ","); full != "" {
+			return full
+		}
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+/* expandTemplate replaces the placeholders in tmpl for the file name. */
+func expandTemplate(tmpl, name string) string {
+	base := filepath.Base(name)
+	dir := filepath.Dir(name)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	now := time.Now()
+	return strings.NewReplacer(
+		"{{filename}}", base,
+		"{{name}}", strings.TrimSuffix(base, filepath.Ext(base)),
+		"{{dir}}", filepath.Base(dir),
+		"{{date}}", now.Format("2006-01-02"),
+		"{{year}}", now.Format("2006"),
+		"{{user}}", userName(),
+	).Replace(tmpl)
+}
+
+/* applyTemplate fills the empty current buffer from the template for its file, if there is one. */
+func (e *Editor) applyTemplate() {
+	tmpl := e.templateFor(e.buf.fileName)
+	if tmpl == "" {
+		return
+	}
+	text := expandTemplate(tmpl, e.buf.fileName)
+	before, after, hasCursor := strings.Cut(text, "{{cursor}}")
+	e.replaceRange(point{}, point{}, before+after)
+	e.setCursor(point{})
+	if hasCursor {
+		lines := strings.Split(before, "\n")
+		e.setCursor(point{y: len(lines) - 1, x: len([]rune(lines[len(lines)-1]))})
+	}
+	e.snapCursor()
+}
@@ -0,0 +1,111 @@
+package editor
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+/*-----------------------------------------------------------------------------
+ * Terminal
+ */
+
+// Terminal is the terminal an editor reads keys from and draws on.
+type Terminal interface {
+	// ReadKey returns the next byte of input. It returns ErrNoInput if
+	// nothing arrives within about 100 milliseconds.
+	ReadKey() (byte, error)
+
+	// Write writes text and escape sequences to the terminal.
+	Write(p []byte) (int, error)
+
+	// Size returns the number of rows and columns of the terminal.
+	Size() (rows int, cols int, err error)
+
+	// RawMode turns raw mode on or off.
+	RawMode(on bool) error
+}
+
+// KeyWaiter is implemented by terminals that can wait for input for a given
+// time, which the editor uses to tell the escape key from the start of an
+// escape sequence.
+type KeyWaiter interface {
+	// WaitKey reports whether input is available within d.
+	WaitKey(d time.Duration) bool
+}
+
+// CellSizer is implemented by terminals that know the size of a character
+// cell in pixels, which the editor uses to fit images to the screen.
+type CellSizer interface {
+	// CellSize returns the width and height of a cell in pixels, or zeros
+	// if they are not known.
+	CellSize() (width int, height int)
+}
+
+// ErrNoInput is returned by Terminal.ReadKey when there is no input.
+var ErrNoInput = errors.New("no input")
+
+type ttyTerminal struct {
+	orgTermios unix.Termios // termios structure
+}
+
+// NewTTY returns the terminal of the process, read from stdin and written to stdout.
+func NewTTY() Terminal {
+	return &ttyTerminal{}
+}
+
+func (t *ttyTerminal) ReadKey() (byte, error) {
+	k := []byte{0}
+	n, err := os.Stdin.Read(k)
+	switch {
+	case err == io.EOF:
+		return 0, ErrNoInput
+	case err != nil:
+		return 0, err
+	case n == 0:
+		return 0, ErrNoInput
+	default:
+		return k[0], nil
+	}
+}
+
+func (t *ttyTerminal) WaitKey(d time.Duration) bool {
+	fds := []unix.PollFd{{Fd: int32(unix.Stdin), Events: unix.POLLIN}}
+	for {
+		n, err := unix.Poll(fds, int(d/time.Millisecond))
+		if err == unix.EINTR {
+			continue
+		}
+		return err == nil && n > 0
+	}
+}
+
+func (t *ttyTerminal) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (t *ttyTerminal) Size() (int, int, error) {
+	ws, err := unix.IoctlGetWinsize(unix.Stdout, unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Row), int(ws.Col), nil
+}
+
+func (t *ttyTerminal) CellSize() (int, int) {
+	ws, err := unix.IoctlGetWinsize(unix.Stdout, unix.TIOCGWINSZ)
+	if err != nil || ws.Row == 0 || ws.Col == 0 {
+		return 0, 0
+	}
+	return int(ws.Xpixel / ws.Col), int(ws.Ypixel / ws.Row)
+}
+
+func (t *ttyTerminal) RawMode(on bool) error {
+	if on {
+		return t.enableRawMode()
+	}
+	return t.disableRawMode()
+}
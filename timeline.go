@@ -0,0 +1,208 @@
+package editor
+
+import (
+	"strings"
+	"time"
+)
+
+/*-----------------------------------------------------------------------------
+ * Edit playback
+ *
+ * Every buffer keeps a timeline of its text, taken when it is opened and
+ * after every key press that changes it, up to timelineKeep versions and
+ * timelineBytes of changes. Each version is kept as the change from the one
+ * before, the first as the whole text. The "playback" action replays the
+ * timeline like a time-lapse, scrolled to where each edit was made: space
+ * pauses and resumes, left and right step back and forth, r plays
+ * backwards, + and - change the speed, home and end go to the first and last
+ * version, and escape or q goes back to the buffer. The buffer itself is not
+ * changed. Secure mode keeps no timeline.
+ */
+
+const (
+	timelineKeep     = 1000     // versions kept per buffer
+	timelineBytes    = 64 << 20 // bytes of changes kept per buffer
+	playbackInterval = 400 * time.Millisecond
+)
+
+type editFrame struct {
+	pos int    // byte offset of the change in the version before
+	del string // text the change removed
+	ins string // text the change put in its place
+	at  time.Time
+}
+
+/* textDelta returns the change turning the text a into b, leaving out what they start and end with alike. */
+func textDelta(a, b string) editFrame {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	j := 0
+	for j < len(a)-i && j < len(b)-i && a[len(a)-1-j] == b[len(b)-1-j] {
+		j++
+	}
+	return editFrame{pos: i, del: a[i : len(a)-j], ins: b[i : len(b)-j], at: time.Now()}
+}
+
+/* apply returns text with the change of f made, or undone if back is set. */
+func (f editFrame) apply(text string, back bool) string {
+	del, ins := f.del, f.ins
+	if back {
+		del, ins = ins, del
+	}
+	return text[:f.pos] + ins + text[f.pos+len(del):]
+}
+
+/* recordTimeline adds the text of the buffer to its timeline if a key press changed it. */
+func (e *Editor) recordTimeline(changes int) {
+	if e.changes == changes || e.buf.preview != nil || e.secure {
+		return
+	}
+	b := e.buf
+	text := b.text()
+	if text == b.timelineText {
+		return
+	}
+	b.timeline = append(b.timeline, textDelta(b.timelineText, text))
+	b.timelineText = text
+
+	size := 0
+	for _, f := range b.timeline {
+		size += len(f.del) + len(f.ins)
+	}
+	for len(b.timeline) > 1 && (len(b.timeline) > timelineKeep || size > timelineBytes) {
+		first, second := b.timeline[0], b.timeline[1]
+		size -= len(first.ins) + len(second.del) + len(second.ins)
+		b.timeline[1] = editFrame{ins: second.apply(first.ins, false), at: second.at}
+		size += len(b.timeline[1].ins)
+		b.timeline = b.timeline[1:]
+	}
+}
+
+/* startTimeline makes the text of the buffer just opened its first version. */
+func (e *Editor) startTimeline() {
+	if e.secure {
+		return
+	}
+	text := e.buf.text()
+	e.buf.timeline = []editFrame{{ins: text, at: time.Now()}}
+	e.buf.timelineText = text
+}
+
+/* showFrame draws version i of the timeline of orig, whose text is text. */
+func (e *Editor) showFrame(orig *buffer, i int, text string, playing bool, speed float64, back bool) {
+	f := orig.timeline[i]
+	view := *orig
+	view.lines = nil
+	view.narrow, view.preview, view.mark, view.protected, view.bookmarks = nil, nil, nil, nil, nil
+	view.dirty = true
+	e.buf = &view
+	for _, l := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		chars := []rune(l)
+		view.lines = append(view.lines, line{chars: chars, render: e.updateRow(chars)})
+	}
+	y := 0
+	if i > 0 {
+		y = strings.Count(text[:f.pos], "\n")
+	}
+	if y >= len(view.lines) {
+		y = len(view.lines) - 1
+	}
+	if y < 0 {
+		y = 0
+	}
+	view.cursor = point{y: y}
+
+	state := "paused"
+	if playing {
+		state = "playing"
+		if back {
+			state = "playing backwards"
+		}
+	}
+	e.setStatusMsg("Playback %d/%d %s, %s %gx  space, left, right, r, +, -, q",
+		i+1, len(orig.timeline), f.at.Format("15:04:05"), state, speed)
+	e.refreshScreen()
+	e.buf = orig
+}
+
+func (e *Editor) playback() {
+	orig := e.buf
+	if len(orig.timeline) < 2 {
+		e.setStatusMsg("No edits to play back")
+		e.bell()
+		return
+	}
+	defer e.setStatusMsg("")
+
+	i, text := 0, orig.timeline[0].ins
+	seek := func(j int) { // steps the text to version j
+		for ; i < j; i++ {
+			text = orig.timeline[i+1].apply(text, false)
+		}
+		for ; i > j; i-- {
+			text = orig.timeline[i].apply(text, true)
+		}
+	}
+	playing, back, speed := true, false, 1.0
+	for {
+		e.showFrame(orig, i, text, playing, speed, back)
+
+		if playing {
+			interval := time.Duration(float64(playbackInterval) / speed)
+			if w, ok := e.term.(KeyWaiter); ok && len(e.replayKeys) == 0 && !w.WaitKey(interval) {
+				switch {
+				case back && i > 0:
+					seek(i - 1)
+				case !back && i < len(orig.timeline)-1:
+					seek(i + 1)
+				default:
+					playing = false // at the end
+				}
+				continue
+			}
+		}
+
+		k, err := e.readKey()
+		if err != nil {
+			return
+		}
+		switch k {
+		case ' ':
+			playing = !playing
+			if playing && !back && i == len(orig.timeline)-1 {
+				seek(0)
+			}
+			if playing && back && i == 0 {
+				seek(len(orig.timeline) - 1)
+			}
+		case 'r':
+			back, playing = !back, true
+		case kArrowLeft:
+			playing = false
+			if i > 0 {
+				seek(i - 1)
+			}
+		case kArrowRight:
+			playing = false
+			if i < len(orig.timeline)-1 {
+				seek(i + 1)
+			}
+		case kHome:
+			seek(0)
+		case kEnd:
+			seek(len(orig.timeline) - 1)
+		case '+', '=':
+			if speed < 16 {
+				speed *= 2
+			}
+		case '-':
+			if speed > 0.25 {
+				speed /= 2
+			}
+		case '\x1b', 'q':
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package editor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTimelineDeltas(t *testing.T) {
+	versions := []string{"", "abc\n", "abXc\n", "abXc\nmore\n", "c\nmore\n", "c\nmore\n"}
+	b := &buffer{}
+	text := ""
+	for _, v := range versions {
+		if v == text {
+			continue
+		}
+		f := textDelta(text, v)
+		if got := f.apply(text, false); got != v {
+			t.Errorf("%q changed by %+v is %q, want %q", text, f, got, v)
+		}
+		if got := f.apply(v, true); got != text {
+			t.Errorf("%q with %+v undone is %q, want %q", v, f, got, text)
+		}
+		b.timeline = append(b.timeline, f)
+		text = v
+	}
+	if len(b.timeline) != 4 {
+		t.Errorf("the timeline has %d versions, want 4", len(b.timeline))
+	}
+}
+
+func TestTimelineSecure(t *testing.T) {
+	for _, secure := range []bool{false, true} {
+		h := NewHeadless(8, 40)
+		h.Type("ab")
+		e := New(WithTerminal(h))
+		e.isolated, e.secure = true, secure
+		e.Run(context.Background(), []byte("text\n"))
+
+		switch {
+		case secure && (len(e.buf.timeline) > 0 || e.buf.timelineText != ""):
+			t.Errorf("secure mode kept %d versions", len(e.buf.timeline))
+		case !secure && e.buf.timelineText != "abtext\n":
+			t.Errorf("the last version is %q", e.buf.timelineText)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+/*-----------------------------------------------------------------------------
+ * Terminal title
+ *
+ * The title of the terminal, or its tab, shows the name of the file being
+ * edited, with a * in front when it has unsaved changes. The title the
+ * terminal had is pushed on its title stack when the editor starts and popped
+ * again when it exits. The title setting turns this off.
+ */
+
+func (e *Editor) titleText() string {
+	name := "No Name"
+	if e.buf.fileName != "" {
+		name = filepath.Base(e.buf.fileName)
+	}
+	if e.buf.dirty {
+		name = "*" + name
+	}
+	return name + " — editor"
+}
+
+/* updateTitle sets the title of the terminal if it has changed since it was last set. */
+func (e *Editor) updateTitle() {
+	if e.titleOff {
+		return
+	}
+	if e.title == "" {
+		e.term.Write([]byte("\x1b[22;0t")) // save the title of the terminal
+	}
+	title := e.titleText()
+	if title == e.title {
+		return
+	}
+	e.title = title
+	fmt.Fprintf(e.term, "\x1b]0;%s\x07", title)
+}
+
+/* restoreTitle gives the terminal back the title it had before updateTitle changed it. */
+func (e *Editor) restoreTitle() {
+	if e.title == "" {
+		return
+	}
+	e.title = ""
+	e.term.Write([]byte("\x1b[23;0t"))
+}
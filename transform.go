@@ -0,0 +1,160 @@
+package editor
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * Encode and decode
+ *
+ * Actions that replace the selection with it encoded or decoded as Base64,
+ * URL query escaping or a hex dump, each as one change that leaves the new
+ * text selected and that undo_replace undoes: base64_encode, base64_decode,
+ * url_encode, url_decode, hex_dump and hex_undump. Decoding has to give
+ * UTF-8 text, since that is what buffers hold.
+ */
+
+/* transformSelection replaces the selection with what fn makes of it. */
+func (e *Editor) transformSelection(fn func(string) (string, error)) {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	a, c, ok := e.buf.selection()
+	if !ok {
+		e.setStatusMsg("Select the text first, set_mark starts a selection")
+		e.bell()
+		return
+	}
+	last := c.y
+	if last == len(e.buf.lines) {
+		last--
+	}
+	if !e.canEdit(a.y, last) {
+		return
+	}
+	out, err := fn(e.buf.textRange(a, c))
+	if err != nil {
+		e.setStatusMsg("%s", err)
+		e.bell()
+		return
+	}
+	e.replaceSelection(out)
+}
+
+/* decodedText returns data as text, if it is UTF-8. */
+func decodedText(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("the decoded bytes are not UTF-8 text")
+	}
+	return string(data), nil
+}
+
+/* hexDump writes data as lines of an offset, 16 bytes in hex and the printable ones as text. */
+func hexDump(data []byte) string {
+	var sb strings.Builder
+	for off := 0; off < len(data); off += 16 {
+		row := data[off:]
+		if len(row) > 16 {
+			row = row[:16]
+		}
+		fmt.Fprintf(&sb, "%08x ", off)
+		for i := 0; i < 16; i++ {
+			if i == 8 {
+				sb.WriteByte(' ')
+			}
+			if i < len(row) {
+				fmt.Fprintf(&sb, " %02x", row[i])
+			} else {
+				sb.WriteString("   ")
+			}
+		}
+		sb.WriteString("  |")
+		for _, b := range row {
+			if b < ' ' || b > '~' {
+				b = '.'
+			}
+			sb.WriteByte(b)
+		}
+		sb.WriteString("|\n")
+	}
+	return sb.String()
+}
+
+/* hexUndump reads the bytes of a hex dump made by hex_dump, or of plain hex digits. */
+func hexUndump(s string) ([]byte, error) {
+	var digits strings.Builder
+	for n, l := range strings.Split(s, "\n") {
+		if i := strings.Index(l, "  |"); i >= 0 {
+			l = l[:i]
+		}
+		fields := strings.Fields(l)
+		if len(fields) > 1 && len(fields[0]) == 8 {
+			fields = fields[1:] // the offset
+		}
+		for _, f := range fields {
+			if strings.Trim(f, "0123456789abcdefABCDEF") != "" {
+				return nil, fmt.Errorf("line %d: %q is not hex", n+1, f)
+			}
+			digits.WriteString(f)
+		}
+	}
+	return hex.DecodeString(digits.String())
+}
+
+func (e *Editor) base64Encode() {
+	e.transformSelection(func(s string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(s)), nil
+	})
+}
+
+func (e *Editor) base64Decode() {
+	e.transformSelection(func(s string) (string, error) {
+		s = strings.Join(strings.Fields(s), "")
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			if data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "=")); err != nil {
+				return "", fmt.Errorf("not Base64: %s", err)
+			}
+		}
+		return decodedText(data)
+	})
+}
+
+func (e *Editor) urlEncode() {
+	e.transformSelection(func(s string) (string, error) {
+		return url.QueryEscape(s), nil
+	})
+}
+
+func (e *Editor) urlDecode() {
+	e.transformSelection(func(s string) (string, error) {
+		out, err := url.QueryUnescape(s)
+		if err != nil {
+			return "", fmt.Errorf("not URL encoded: %s", err)
+		}
+		return out, nil
+	})
+}
+
+func (e *Editor) hexDumpAction() {
+	e.transformSelection(func(s string) (string, error) {
+		return hexDump([]byte(s)), nil
+	})
+}
+
+func (e *Editor) hexUndumpAction() {
+	e.transformSelection(func(s string) (string, error) {
+		data, err := hexUndump(s)
+		if err != nil {
+			return "", fmt.Errorf("not a hex dump: %s", err)
+		}
+		return decodedText(data)
+	})
+}
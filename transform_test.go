@@ -0,0 +1,19 @@
+package editor
+
+import "testing"
+
+func TestTransformSelectionUndo(t *testing.T) {
+	bindings := `{"ctrl+b": "base64_encode", "ctrl+o": "undo_replace"}`
+	for _, tc := range []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"\x00", "\x05", "\x02"}, "aGkgdGhlcmU=\n"},
+		{[]string{"\x00", "\x05", "\x02", "\x0f"}, "hi there\n"},
+		{[]string{"\x00", "\x05", "\x02", "\x02", "\x0f"}, "aGkgdGhlcmU=\n"},
+	} {
+		if text := runKeys(t, "hi there\n", bindings, tc.keys...); text != tc.want {
+			t.Errorf("%q: text is %q, want %q", tc.keys, text, tc.want)
+		}
+	}
+}
@@ -4,13 +4,13 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func enableRawMode() error {
+func (t *ttyTerminal) enableRawMode() error {
 	termios, err := unix.IoctlGetTermios(unix.Stdin, unix.TIOCGETA)
 	if err != nil {
 		return err
 	}
 
-	editor.orgTermios = *termios
+	t.orgTermios = *termios
 
 	/* Disable ctrl-S, ctrl-Q and ctrl-M. */
 	termios.Iflag = termios.Iflag &^ (unix.IXON | unix.ICRNL | unix.BRKINT | unix.INPCK | unix.ISTRIP)
@@ -38,8 +38,8 @@ func enableRawMode() error {
 	return nil
 }
 
-func disableRawMode() error {
-	if err := unix.IoctlSetTermios(unix.Stdin, unix.TIOCSETAF, &editor.orgTermios); err != nil {
+func (t *ttyTerminal) disableRawMode() error {
+	if err := unix.IoctlSetTermios(unix.Stdin, unix.TIOCSETAF, &t.orgTermios); err != nil {
 		return err
 	}
 
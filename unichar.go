@@ -0,0 +1,138 @@
+package editor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*-----------------------------------------------------------------------------
+ * Unicode characters
+ *
+ * The "insert_unicode" action inserts characters given by their code points,
+ * such as "U+00E9" or "1f600", several separated by spaces. The "what_char"
+ * action shows the code point, the UTF-8 bytes and the name of the character
+ * under the cursor. Names are read from the UnicodeData.txt of the system,
+ * where there is one, and else the script and category are shown.
+ */
+
+var unicodeDataFiles = []string{
+	"/usr/share/unicode/UnicodeData.txt",
+	"/usr/share/unicode-data/UnicodeData.txt",
+	"/usr/share/unicode/ucd/UnicodeData.txt",
+}
+
+/* parseCodePoints parses code points such as "U+00E9 1f600" into the characters. */
+func parseCodePoints(s string) ([]rune, error) {
+	rs := []rune{}
+	for _, f := range strings.Fields(s) {
+		hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(f), "u+"), "0x")
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(n)) {
+			return nil, fmt.Errorf("%s is not a code point", f)
+		}
+		rs = append(rs, rune(n))
+	}
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("a code point such as U+00E9")
+	}
+	return rs, nil
+}
+
+/* charName returns the Unicode name of r, or its script and category when the name is not known. */
+func charName(r rune) string {
+	code := fmt.Sprintf("%04X;", r)
+	for _, path := range unicodeDataFiles {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rest, ok := strings.CutPrefix(scanner.Text(), code); ok {
+				f.Close()
+				name, _, _ := strings.Cut(rest, ";")
+				if strings.HasPrefix(name, "<") { // <control> and the like have their old name as field 10
+					if fields := strings.Split(rest, ";"); len(fields) > 9 && fields[9] != "" {
+						return fields[9]
+					}
+				}
+				return name
+			}
+		}
+		f.Close()
+	}
+
+	desc := []string{}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			desc = append(desc, name)
+			break
+		}
+	}
+	for _, c := range []struct {
+		table *unicode.RangeTable
+		name  string
+	}{
+		{unicode.Upper, "uppercase letter"}, {unicode.Lower, "lowercase letter"},
+		{unicode.Letter, "letter"}, {unicode.Digit, "digit"}, {unicode.Number, "number"},
+		{unicode.Space, "space"}, {unicode.Punct, "punctuation"}, {unicode.Symbol, "symbol"},
+		{unicode.Mark, "mark"}, {unicode.Cc, "control character"},
+	} {
+		if unicode.Is(c.table, r) {
+			desc = append(desc, c.name)
+			break
+		}
+	}
+	if len(desc) == 0 {
+		return "unassigned or private use"
+	}
+	return strings.Join(desc, " ")
+}
+
+/* describeChar tells the code point, UTF-8 bytes and name of r. */
+func describeChar(r rune) string {
+	shown := strconv.QuoteRune(r)
+	buf := make([]byte, utf8.RuneLen(r))
+	utf8.EncodeRune(buf, r)
+	bytes := make([]string, len(buf))
+	for i, b := range buf {
+		bytes[i] = fmt.Sprintf("%02x", b)
+	}
+	return fmt.Sprintf("%s U+%04X %s, UTF-8 %s, decimal %d", shown, r, charName(r), strings.Join(bytes, " "), r)
+}
+
+func (e *Editor) whatChar() {
+	c := e.buf.cursor
+	if c.y >= len(e.buf.lines) {
+		e.setStatusMsg("end of the buffer")
+		return
+	}
+	chars := e.buf.lines[c.y].chars
+	if c.x >= len(chars) {
+		e.setStatusMsg("end of the line")
+		return
+	}
+	e.setStatusMsg("%s", describeChar(chars[c.x]))
+}
+
+func (e *Editor) insertUnicode() {
+	if e.isReadonly() {
+		e.setStatusMsg("buffer is read-only")
+		e.bell()
+		return
+	}
+	input, ok := e.Prompt("Insert code points (U+XXXX): ", func(s string) error {
+		_, err := parseCodePoints(s)
+		return err
+	}, nil)
+	if !ok {
+		return
+	}
+	rs, _ := parseCodePoints(input)
+	e.insertText(string(rs))
+}
@@ -0,0 +1,93 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+/*-----------------------------------------------------------------------------
+ * Auto-wrap
+ *
+ * With the auto_wrap setting "prose", lines of Markdown and plain text files
+ * that get wider than the textwidth as you type are broken at the last space
+ * that leaves the line narrow enough, and with "on" those of every file. The
+ * new line starts with the indentation and comment marker of the one broken,
+ * or for the first line of a list item with spaces up to the item's text, as
+ * fill_paragraph does it. It is off unless set.
+ */
+
+type wrapMode int
+
+const (
+	wrapOff   wrapMode = iota
+	wrapProse          // Markdown and plain text files
+	wrapOn             // every file
+)
+
+var wrapModes = map[string]wrapMode{
+	"off":   wrapOff,
+	"prose": wrapProse,
+	"on":    wrapOn,
+}
+
+func parseWrapMode(name string) (wrapMode, error) {
+	if m, ok := wrapModes[name]; ok {
+		return m, nil
+	}
+	return wrapOff, fmt.Errorf("auto_wrap must be on, off or prose")
+}
+
+/* isProse reports whether the file name holds text to be wrapped in prose mode. */
+func isProse(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".text", ".rst", ".adoc", ".org":
+		return true
+	}
+	return isMarkdown(name) || filepath.Base(name) == "COMMIT_EDITMSG"
+}
+
+func (e *Editor) wrapping() bool {
+	return e.autoWrap == wrapOn || (e.autoWrap == wrapProse && isProse(e.buf.fileName))
+}
+
+/* autoWrapLine breaks the line at the cursor before the word typed when it is wider than the textwidth. */
+func (e *Editor) autoWrapLine() {
+	y, x := e.buf.cursor.y, e.buf.cursor.x
+	if !e.wrapping() || y >= len(e.buf.lines) || len(e.buf.lines[y].render) <= e.textWidth {
+		return
+	}
+	chars := e.buf.lines[y].chars
+	l := string(chars)
+	prefix := linePrefix(l)
+	start := len([]rune(prefix))
+
+	/* the last space before the cursor that leaves the line narrow enough, else the first */
+	at := -1
+	for i := start + 1; i < x; i++ {
+		if !unicode.IsSpace(chars[i]) || unicode.IsSpace(chars[i-1]) {
+			continue
+		}
+		if at < 0 || len(e.updateRow(chars[:i])) <= e.textWidth {
+			at = i
+		}
+	}
+	if at < 0 || !e.canInsertRow(y+1) {
+		return // a single word wider than the textwidth
+	}
+	next := at
+	for next < x && unicode.IsSpace(chars[next]) {
+		next++
+	}
+
+	rest := prefix
+	if m := listItemRx.FindString(l[len(prefix):]); m != "" {
+		rest = hangingIndent(prefix, m)
+	}
+	tail := rest + string(chars[next:])
+	e.buf.lines[y].chars = chars[:at]
+	e.buf.lines[y].render = e.updateRow(e.buf.lines[y].chars)
+	e.insertRow(y+1, tail)
+	e.buf.cursor = point{x: len([]rune(rest)) + x - next, y: y + 1}
+}